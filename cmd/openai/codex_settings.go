@@ -0,0 +1,221 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/picatz/openai/codex"
+	"github.com/spf13/cobra"
+)
+
+// defaultCodexSettingsPath is where the responses REPL keeps its codex
+// settings and the ID of the last codex thread between runs.
+var defaultCodexSettingsPath = cmp.Or(os.Getenv("HOME"), os.Getenv("USERPROFILE")) + "/.openai-cli-codex.json"
+
+// codexSettings configure how @codex runs in the responses REPL.
+type codexSettings struct {
+	Model            string            `json:"model"`
+	SandboxMode      codex.SandboxMode `json:"sandbox_mode"`
+	WorkingDirectory string            `json:"working_directory,omitempty"`
+	SkipGitRepoCheck bool              `json:"skip_git_repo_check,omitempty"`
+	ThreadID         string            `json:"thread_id,omitempty"`
+}
+
+func defaultCodexSettings() codexSettings {
+	return codexSettings{
+		Model:       "gpt-5-codex",
+		SandboxMode: codex.SandboxModeReadOnly,
+	}
+}
+
+// loadCodexSettings reads settings from path, returning the defaults when the
+// file does not exist yet.
+func loadCodexSettings(path string) (codexSettings, error) {
+	settings := defaultCodexSettings()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, fmt.Errorf("failed to read codex settings: %w", err)
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, fmt.Errorf("failed to decode codex settings %q: %w", path, err)
+	}
+	return settings, nil
+}
+
+// save writes the settings to path. Full access always needs a fresh
+// confirmation, so it is persisted as read-only.
+func (s codexSettings) save(path string) error {
+	if s.SandboxMode == codex.SandboxModeDangerFullAccess {
+		s.SandboxMode = codex.SandboxModeReadOnly
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode codex settings: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write codex settings: %w", err)
+	}
+	return nil
+}
+
+func (s codexSettings) threadOptions() codex.ThreadOptions {
+	return codex.ThreadOptions{
+		Model:            s.Model,
+		SandboxMode:      s.SandboxMode,
+		WorkingDirectory: s.WorkingDirectory,
+		SkipGitRepoCheck: s.SkipGitRepoCheck,
+	}
+}
+
+// parseSandboxMode validates a sandbox mode given by the user.
+func parseSandboxMode(value string) (codex.SandboxMode, error) {
+	switch mode := codex.SandboxMode(value); mode {
+	case codex.SandboxModeReadOnly, codex.SandboxModeWorkspaceWrite, codex.SandboxModeDangerFullAccess:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown sandbox mode %q (want %s, %s or %s)", value,
+			codex.SandboxModeReadOnly, codex.SandboxModeWorkspaceWrite, codex.SandboxModeDangerFullAccess)
+	}
+}
+
+// resolveCodexDirectory makes dir absolute and checks that it is a directory.
+func resolveCodexDirectory(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory %q: %w", dir, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("failed to open directory %q: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%q is not a directory", dir)
+	}
+	return abs, nil
+}
+
+// addCodexFlags registers the flags that configure @codex in the responses REPL.
+func addCodexFlags(cmd *cobra.Command) {
+	cmd.Flags().String("codex-model", "", "model used by @codex")
+	cmd.Flags().String("codex-sandbox", "", "sandbox mode used by @codex (read-only, workspace-write or danger-full-access)")
+	cmd.Flags().String("codex-cd", "", "working directory used by @codex")
+	cmd.Flags().Bool("codex-skip-git-repo-check", false, "allow @codex to run outside a git repository")
+	cmd.Flags().String("codex-resume", "", "resume the codex thread with the given ID")
+	cmd.Flags().Bool("codex-new", false, "start a new codex thread instead of resuming the last one")
+}
+
+// codexSettingsFromFlags loads the persisted codex settings and applies the
+// flags registered by addCodexFlags on top.
+func codexSettingsFromFlags(cmd *cobra.Command) (codexSettings, error) {
+	settings, err := loadCodexSettings(defaultCodexSettingsPath)
+	if err != nil {
+		return settings, err
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("codex-model") {
+		settings.Model, _ = flags.GetString("codex-model")
+	}
+	if flags.Changed("codex-sandbox") {
+		value, _ := flags.GetString("codex-sandbox")
+		if settings.SandboxMode, err = parseSandboxMode(value); err != nil {
+			return settings, err
+		}
+	}
+	if flags.Changed("codex-cd") {
+		dir, _ := flags.GetString("codex-cd")
+		if settings.WorkingDirectory, err = resolveCodexDirectory(dir); err != nil {
+			return settings, err
+		}
+	}
+	if flags.Changed("codex-skip-git-repo-check") {
+		settings.SkipGitRepoCheck, _ = flags.GetBool("codex-skip-git-repo-check")
+	}
+	if newThread, _ := flags.GetBool("codex-new"); newThread {
+		settings.ThreadID = ""
+	}
+	if flags.Changed("codex-resume") {
+		settings.ThreadID, _ = flags.GetString("codex-resume")
+	}
+	return settings, nil
+}
+
+const codexCommandUsage = "Usage: /codex status|model <name>|sandbox <mode>|cd <dir>|skip-git-check on|off|reset|resume <thread-id>"
+
+// applyCodexCommand applies a `/codex ...` REPL command to settings and returns
+// a message for the user. confirm is asked before full access is granted.
+func applyCodexCommand(settings *codexSettings, args []string, confirm func(question string) bool) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New(codexCommandUsage)
+	}
+
+	switch args[0] {
+	case "status":
+		thread := settings.ThreadID
+		if thread == "" {
+			thread = "(new)"
+		}
+		dir := settings.WorkingDirectory
+		if dir == "" {
+			dir = "(current directory)"
+		}
+		return fmt.Sprintf("model: %s\nsandbox: %s\ndirectory: %s\nskip git repo check: %t\nthread: %s",
+			settings.Model, settings.SandboxMode, dir, settings.SkipGitRepoCheck, thread), nil
+	case "model":
+		if len(args) != 2 {
+			return "", errors.New("Usage: /codex model <name>")
+		}
+		settings.Model = args[1]
+		return "Codex model set to " + settings.Model + ".", nil
+	case "sandbox":
+		if len(args) != 2 {
+			return "", errors.New("Usage: /codex sandbox read-only|workspace-write|danger-full-access")
+		}
+		mode, err := parseSandboxMode(args[1])
+		if err != nil {
+			return "", err
+		}
+		if mode == codex.SandboxModeDangerFullAccess && !confirm("danger-full-access lets codex run any command without a sandbox.") {
+			return "Sandbox mode unchanged.", nil
+		}
+		settings.SandboxMode = mode
+		return fmt.Sprintf("Codex sandbox set to %s.", mode), nil
+	case "cd":
+		if len(args) != 2 {
+			return "", errors.New("Usage: /codex cd <dir>")
+		}
+		dir, err := resolveCodexDirectory(args[1])
+		if err != nil {
+			return "", err
+		}
+		settings.WorkingDirectory = dir
+		return "Codex working directory set to " + dir + ".", nil
+	case "skip-git-check":
+		if len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+			return "", errors.New("Usage: /codex skip-git-check on|off")
+		}
+		settings.SkipGitRepoCheck = args[1] == "on"
+		return "Codex git repository check skipping is " + args[1] + ".", nil
+	case "reset":
+		settings.ThreadID = ""
+		return "The next @codex message starts a new thread.", nil
+	case "resume":
+		if len(args) != 2 {
+			return "", errors.New("Usage: /codex resume <thread-id>")
+		}
+		settings.ThreadID = args[1]
+		return "Resuming codex thread " + settings.ThreadID + ".", nil
+	default:
+		return "", errors.New(codexCommandUsage)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/picatz/openai/codex"
+)
+
+// codexView renders a codex turn inside the responses REPL as it happens:
+// commands with their output and exit codes, file change summaries, the
+// agent's plan and per-turn token usage.
+type codexView struct {
+	bt      *bufio.Writer
+	width   int
+	verbose bool
+
+	tracker *codex.ItemTracker
+
+	// plans holds the last rendering of each todo list so unchanged plans are
+	// not redrawn.
+	plans map[string]string
+
+	// last is the ID of the item whose block was written most recently, and
+	// lastLines is how many lines that block took. A todo list that is still the
+	// last block is redrawn in place instead of printed again.
+	last      string
+	lastLines int
+}
+
+func newCodexView(bt *bufio.Writer, width int, verbose bool) *codexView {
+	return &codexView{
+		bt:      bt,
+		width:   width,
+		verbose: verbose,
+		tracker: codex.NewItemTracker(),
+		plans:   make(map[string]string),
+	}
+}
+
+// handle renders a single event and flushes the output.
+func (v *codexView) handle(event codex.ThreadEvent) {
+	defer v.bt.Flush()
+
+	switch event.Type {
+	case codex.EventTypeTurnCompleted:
+		if event.Usage != nil {
+			v.line(styleFaint.Render(fmt.Sprintf(
+				"tokens: %d input (%d cached), %d output",
+				event.Usage.InputTokens, event.Usage.CachedInputTokens, event.Usage.OutputTokens,
+			)))
+		}
+		return
+	case codex.EventTypeTurnFailed:
+		if event.Error != nil {
+			v.line(styleWarning.Render("Codex turn failed: ") + event.Error.Message)
+		}
+		return
+	case codex.EventTypeError:
+		v.line(styleWarning.Render("Codex error: ") + event.Message)
+		return
+	}
+
+	for _, delta := range v.tracker.Apply(event) {
+		v.render(delta)
+	}
+
+	// Plans change in ways other than checked entries (added or reworded
+	// steps), so they are redrawn from the tracked state on every update.
+	if todo, ok := event.Item.(*codex.TodoListItem); ok {
+		if item, ok := v.tracker.Item(todo.ID); ok {
+			v.renderTodo(item.(*codex.TodoListItem))
+		}
+	}
+}
+
+func (v *codexView) render(delta codex.ItemDelta) {
+	switch d := delta.(type) {
+	case codex.ItemStartedDelta:
+		switch item := d.Item.(type) {
+		case *codex.CommandExecutionItem:
+			v.line(styleInfo.Render("$ ") + item.Command)
+		case *codex.McpToolCallItem:
+			if v.verbose {
+				v.line(styleFaint.Render(fmt.Sprintf("tool %s/%s", item.Server, item.Tool)))
+			}
+		case *codex.WebSearchItem:
+			if v.verbose {
+				v.line(styleFaint.Render("search " + item.Query))
+			}
+		}
+	case codex.CommandOutputDelta:
+		if !v.verbose || d.Output == "" {
+			return
+		}
+		for line := range strings.SplitSeq(strings.TrimRight(d.Output, "\n"), "\n") {
+			v.line(styleFaint.Render("  " + v.truncate(line, 2)))
+		}
+	case codex.ItemCompletedDelta:
+		switch item := d.Item.(type) {
+		case *codex.AgentMessageItem:
+			s, err := renderMarkdown(strings.TrimRight(item.Text, "\n"), v.width*3/4)
+			if err != nil {
+				v.line("Codex render error: " + err.Error())
+				return
+			}
+			v.write(d.ItemID(), s)
+		case *codex.ReasoningItem:
+			if v.verbose && item.Text != "" {
+				v.line(styleFaint.Render(strings.TrimSpace(item.Text)))
+			}
+		case *codex.CommandExecutionItem:
+			v.renderExit(item)
+		case *codex.FileChangeItem:
+			v.renderFileChanges(item)
+		case *codex.ErrorItem:
+			v.line(styleWarning.Render("Codex: ") + item.Message)
+		}
+	}
+}
+
+func (v *codexView) renderExit(item *codex.CommandExecutionItem) {
+	switch {
+	case item.ExitCode != nil && *item.ExitCode == 0:
+		v.line(styleAdd.Render("✓") + styleFaint.Render(" exit 0"))
+	case item.ExitCode != nil:
+		v.line(styleDelete.Render("✗") + styleFaint.Render(fmt.Sprintf(" exit %d", *item.ExitCode)))
+	case item.Status == codex.CommandExecutionStatusFailed:
+		v.line(styleDelete.Render("✗") + styleFaint.Render(" failed"))
+	}
+}
+
+func (v *codexView) renderFileChanges(item *codex.FileChangeItem) {
+	var b strings.Builder
+	if item.Status == codex.PatchApplyStatusFailed {
+		b.WriteString(styleWarning.Render("Patch failed to apply") + "\n")
+	}
+	for _, change := range item.Changes {
+		switch change.Kind {
+		case codex.PatchChangeKindAdd:
+			b.WriteString(styleAdd.Render("A ") + stylePath.Render(change.Path) + "\n")
+		case codex.PatchChangeKindDelete:
+			b.WriteString(styleDelete.Render("D ") + stylePath.Render(change.Path) + "\n")
+		default:
+			b.WriteString(styleUpdate.Render("M ") + stylePath.Render(change.Path) + "\n")
+		}
+	}
+	v.write(item.ID, b.String())
+}
+
+// renderTodo prints the plan checklist when it changed. When the checklist is
+// the most recent block on screen it is redrawn in place.
+func (v *codexView) renderTodo(item *codex.TodoListItem) {
+	var b strings.Builder
+	b.WriteString(styleBold.Render("Plan") + "\n")
+	for _, todo := range item.Items {
+		if todo.Completed {
+			b.WriteString(styleAdd.Render("  [x] ") + styleFaint.Render(v.truncate(todo.Text, 6)) + "\n")
+		} else {
+			b.WriteString("  [ ] " + v.truncate(todo.Text, 6) + "\n")
+		}
+	}
+
+	rendered := b.String()
+	if v.plans[item.ID] == rendered {
+		return
+	}
+	v.plans[item.ID] = rendered
+
+	if v.last == item.ID && v.lastLines > 0 {
+		// Move up over the previous checklist and clear to the end of screen.
+		fmt.Fprintf(v.bt, "\033[%dA\033[0G\033[J", v.lastLines)
+	}
+	v.write(item.ID, rendered)
+}
+
+// line writes a single line that does not belong to a redrawable block.
+func (v *codexView) line(s string) {
+	v.write("", s+"\n")
+}
+
+func (v *codexView) write(id, s string) {
+	v.bt.WriteString("\033[0G")
+	v.bt.WriteString(s)
+	v.last = id
+	v.lastLines = strings.Count(s, "\n")
+}
+
+// truncate shortens plain text s so that it fits on one terminal line after
+// indent columns, which keeps in-place redraws aligned.
+func (v *codexView) truncate(s string, indent int) string {
+	limit := v.width - indent
+	if limit <= 1 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
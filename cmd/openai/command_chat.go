@@ -47,7 +47,9 @@ var chatCommand = &cobra.Command{
 		}
 		defer storageBackend.Close(cmd.Context())
 
-		chatSession, restore, err := chat.NewSession(cmd.Context(), client, chatModel, cmd.InOrStdin(), cmd.OutOrStdout(), storageBackend)
+		conversation, _ := cmd.Flags().GetString("conversation")
+
+		chatSession, restore, err := chat.NewSession(cmd.Context(), client, chatModel, conversation, cmd.InOrStdin(), cmd.OutOrStdout(), storageBackend, nil)
 		if err != nil {
 			return fmt.Errorf("failed to create chat session: %w", err)
 		}
@@ -61,6 +63,7 @@ var chatCommand = &cobra.Command{
 
 func init() {
 	chatCommand.Flags().BoolP("temporary", "t", false, "Use a temporary in-memory chat storage backend")
+	chatCommand.Flags().StringP("conversation", "c", chat.DefaultConversation, "Name of the conversation to resume or create")
 
 	rootCmd.AddCommand(
 		chatCommand,
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/picatz/openai/codex"
+	"github.com/picatz/openai/codex/server"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	codexExecCommand.Flags().StringSlice("image", nil, "attach a local image to the prompt (repeatable)")
+	codexExecCommand.Flags().StringSlice("file", nil, "attach a local file to the prompt (repeatable)")
+	codexExecCommand.Flags().StringSlice("dir", nil, "attach the text files in a directory, optionally as dir:pattern (repeatable)")
+	codexExecCommand.Flags().StringSlice("url", nil, "attach a remote text resource or image to the prompt (repeatable)")
+	codexExecCommand.Flags().String("schema", "", "path to a JSON schema file the final response must follow")
+	codexExecCommand.Flags().String("sandbox", string(codex.SandboxModeReadOnly), "sandbox mode (read-only, workspace-write or danger-full-access)")
+	codexExecCommand.Flags().String("model", "gpt-5-codex", "model to run the agent with")
+	codexExecCommand.Flags().String("resume", "", "resume the thread with the given ID")
+	codexExecCommand.Flags().String("cd", "", "working directory for the agent")
+	codexExecCommand.Flags().Bool("skip-git-repo-check", false, "allow running outside a git repository")
+	codexExecCommand.Flags().StringP("output", "o", "final", "output format (final, json or summary)")
+	codexExecCommand.Flags().Int("max-tokens", 0, "fail once the turn uses more tokens than this (0 for no limit)")
+	codexExecCommand.Flags().Float64("max-cost", 0, "fail once the estimated cost in US dollars exceeds this (0 for no limit)")
+
+	codexServeCommand.Flags().String("addr", "127.0.0.1:8787", "address to listen on")
+	codexServeCommand.Flags().String("token", os.Getenv("OPENAI_CODEX_SERVE_TOKEN"), "bearer token clients must present (defaults to $OPENAI_CODEX_SERVE_TOKEN)")
+	codexServeCommand.Flags().StringSlice("allow-origin", nil, "browser origin allowed to call the server, or * for any (repeatable)")
+	codexServeCommand.Flags().StringSlice("allow-host", nil, "host name clients may address the server by besides localhost and IP addresses, or * for any (repeatable)")
+	codexServeCommand.Flags().String("sandbox", string(codex.SandboxModeReadOnly), "sandbox mode (read-only, workspace-write or danger-full-access)")
+	codexServeCommand.Flags().String("model", "gpt-5-codex", "default model to run agents with")
+	codexServeCommand.Flags().String("cd", "", "working directory for the agents")
+	codexServeCommand.Flags().Bool("skip-git-repo-check", false, "allow running outside a git repository")
+
+	codexCommand.AddCommand(
+		codexExecCommand,
+		codexThreadsCommand,
+		codexServeCommand,
+	)
+
+	rootCmd.AddCommand(
+		codexCommand,
+	)
+}
+
+var codexCommand = &cobra.Command{
+	Use:   "codex",
+	Short: "Run Codex agents",
+}
+
+var codexExecCommand = &cobra.Command{
+	Use:   "exec [prompt]",
+	Short: "Run a single non-interactive Codex turn",
+	Long: `Run a single non-interactive Codex turn.
+
+The prompt is taken from the arguments, or read from stdin when no arguments
+are given or the only argument is "-". The command exits with a non-zero status
+when the turn fails.
+
+Output formats:
+  final    print the agent's final response (default)
+  json     print every event as JSON lines while the turn runs
+  summary  print a human readable summary of items and token usage`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+
+		output, _ := flags.GetString("output")
+		switch output {
+		case "final", "json", "summary":
+		default:
+			return fmt.Errorf("unknown output format %q (want final, json or summary)", output)
+		}
+
+		prompt, err := codexPrompt(cmd.InOrStdin(), args)
+		if err != nil {
+			return err
+		}
+
+		sandboxFlag, _ := flags.GetString("sandbox")
+		sandbox, err := parseSandboxMode(sandboxFlag)
+		if err != nil {
+			return err
+		}
+
+		var budget codex.Budget
+		budget.MaxTokens, _ = flags.GetInt("max-tokens")
+		budget.MaxCost, _ = flags.GetFloat64("max-cost")
+
+		threadOptions := codex.ThreadOptions{
+			SandboxMode: sandbox,
+			UsageLedger: codex.NewUsageLedger(nil, budget),
+		}
+		threadOptions.Model, _ = flags.GetString("model")
+		threadOptions.SkipGitRepoCheck, _ = flags.GetBool("skip-git-repo-check")
+		if dir, _ := flags.GetString("cd"); dir != "" {
+			if threadOptions.WorkingDirectory, err = resolveCodexDirectory(dir); err != nil {
+				return err
+			}
+		}
+
+		var turnOptions codex.TurnOptions
+		if schemaPath, _ := flags.GetString("schema"); schemaPath != "" {
+			data, err := os.ReadFile(schemaPath)
+			if err != nil {
+				return fmt.Errorf("failed to read schema: %w", err)
+			}
+			var schema map[string]any
+			if err := json.Unmarshal(data, &schema); err != nil {
+				return fmt.Errorf("failed to decode schema %q: %w", schemaPath, err)
+			}
+			turnOptions.OutputSchema = schema
+			turnOptions.ValidateOutput = true
+		}
+
+		parts := []codex.UserInput{codex.TextPart(prompt)}
+		images, _ := flags.GetStringSlice("image")
+		for _, image := range images {
+			parts = append(parts, codex.LocalImagePart(image))
+		}
+		files, _ := flags.GetStringSlice("file")
+		for _, file := range files {
+			parts = append(parts, codex.LocalFilePart(file))
+		}
+		dirs, _ := flags.GetStringSlice("dir")
+		for _, dir := range dirs {
+			dir, pattern, _ := strings.Cut(dir, ":")
+			parts = append(parts, codex.DirectoryPart(dir, pattern))
+		}
+		urls, _ := flags.GetStringSlice("url")
+		for _, url := range urls {
+			parts = append(parts, codex.URLPart(url))
+		}
+
+		// Print every event as the turn runs for the json output format.
+		out := cmd.OutOrStdout()
+		if output == "json" {
+			encoder := json.NewEncoder(out)
+			threadOptions.Middleware = append(threadOptions.Middleware, func(ctx context.Context, event codex.ThreadEvent) (codex.ThreadEvent, bool, error) {
+				if err := encoder.Encode(event); err != nil {
+					return event, true, fmt.Errorf("failed to write event: %w", err)
+				}
+				return event, true, nil
+			})
+		}
+
+		options, closeStore := codexRecordingOptions(cmd)
+		defer closeStore()
+
+		codexClient, err := codex.New(options)
+		if err != nil {
+			return fmt.Errorf("failed to create codex client: %w", err)
+		}
+
+		var thread *codex.Thread
+		if resume, _ := flags.GetString("resume"); resume != "" {
+			thread = codexClient.ResumeThread(resume, threadOptions)
+		} else {
+			thread = codexClient.StartThread(threadOptions)
+		}
+
+		turn, err := thread.Run(cmd.Context(), codex.ComposeInput(parts...), &turnOptions)
+		var validationErr *codex.OutputValidationError
+		if errors.As(err, &validationErr) {
+			for _, violation := range validationErr.Violations {
+				fmt.Fprintf(cmd.ErrOrStderr(), "schema violation: %s\n", violation)
+			}
+			return err
+		}
+		// A turn that crossed the budget still completed, so its output is
+		// printed before failing.
+		completed := errors.Is(err, codex.ErrBudgetExceeded) && turn.Usage != nil
+		if err != nil && !completed {
+			return fmt.Errorf("codex turn failed: %w", err)
+		}
+
+		switch output {
+		case "final":
+			fmt.Fprintln(out, strings.TrimRight(turn.FinalResponse, "\n"))
+		case "summary":
+			writeCodexSummary(out, thread.ID(), turn, thread.Usage().Totals())
+		}
+		return err
+	},
+}
+
+var codexThreadsCommand = &cobra.Command{
+	Use:   "threads [query]",
+	Short: "List recorded Codex threads, optionally matching a query",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		threadStore, err := codex.OpenThreadStore(defaultCodexThreadStorePath)
+		if err != nil {
+			return err
+		}
+		defer threadStore.Close(cmd.Context())
+
+		var records []codex.ThreadRecord
+		if len(args) > 0 {
+			records, err = threadStore.Search(cmd.Context(), strings.Join(args, " "))
+		} else {
+			records, err = threadStore.List(cmd.Context())
+		}
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		for _, record := range records {
+			fmt.Fprintf(out, "%s  %s  turns=%d tokens=%d\n", record.ID, record.UpdatedAt.Local().Format(time.DateTime),
+				len(record.Turns), record.Usage.InputTokens+record.Usage.OutputTokens)
+			if record.WorkingDirectory != "" {
+				fmt.Fprintf(out, "  dir: %s\n", record.WorkingDirectory)
+			}
+			fmt.Fprintf(out, "  prompt: %s\n", firstLine(record.Prompt))
+		}
+		return nil
+	},
+}
+
+var codexServeCommand = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve Codex turns over HTTP with SSE and WebSocket event streams",
+	Long: `Serve Codex turns over HTTP with SSE and WebSocket event streams.
+
+Routes:
+  POST   /turns             start a turn: {"prompt": "...", "thread_id": "...", "model": "..."}
+  GET    /turns             list turns
+  GET    /turns/{id}        show the status of a turn
+  DELETE /turns/{id}        cancel a running turn
+  GET    /turns/{id}/events stream events as Server-Sent Events
+  GET    /turns/{id}/ws     stream events over a WebSocket
+
+The server listens on localhost by default. Set --token before exposing it on
+other interfaces, since every client can run agents in the served directory.
+
+Requests from browser origins other than the server's own and --allow-origin,
+and requests addressed to host names other than localhost, the --addr host and
+--allow-host, are refused.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+
+		sandboxFlag, _ := flags.GetString("sandbox")
+		sandbox, err := parseSandboxMode(sandboxFlag)
+		if err != nil {
+			return err
+		}
+
+		options := server.Options{ThreadOptions: codex.ThreadOptions{SandboxMode: sandbox}}
+		options.ThreadOptions.Model, _ = flags.GetString("model")
+		options.ThreadOptions.SkipGitRepoCheck, _ = flags.GetBool("skip-git-repo-check")
+		if dir, _ := flags.GetString("cd"); dir != "" {
+			if options.ThreadOptions.WorkingDirectory, err = resolveCodexDirectory(dir); err != nil {
+				return err
+			}
+		}
+		options.Token, _ = flags.GetString("token")
+		options.AllowedOrigins, _ = flags.GetStringSlice("allow-origin")
+		options.AllowedHosts, _ = flags.GetStringSlice("allow-host")
+
+		addr, _ := flags.GetString("addr")
+		if host, _, err := net.SplitHostPort(addr); err == nil && host != "" {
+			options.AllowedHosts = append(options.AllowedHosts, host)
+		}
+
+		clientOptions, closeStore := codexRecordingOptions(cmd)
+		defer closeStore()
+
+		codexClient, err := codex.New(clientOptions)
+		if err != nil {
+			return fmt.Errorf("failed to create codex client: %w", err)
+		}
+
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+
+		handler := server.New(codexClient, options)
+		httpServer := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+		go func() {
+			<-cmd.Context().Done()
+			handler.Close()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = httpServer.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Fprintf(cmd.ErrOrStderr(), "serving codex on http://%s\n", listener.Addr())
+		if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to serve: %w", err)
+		}
+		return nil
+	},
+}
+
+// defaultCodexThreadStorePath is where `openai codex` records its threads.
+var defaultCodexThreadStorePath = cmp.Or(os.Getenv("HOME"), os.Getenv("USERPROFILE")) + "/.openai-cli-codex-threads"
+
+// codexRecordingOptions returns client options that record threads in the
+// default thread store. Recording is best-effort: the store is locked by the
+// process that opened it, so when another run holds it, or a turn can't be
+// recorded, a warning is printed and turns run without being recorded.
+//
+// The returned function closes the store, if it was opened.
+func codexRecordingOptions(cmd *cobra.Command) (codex.Options, func()) {
+	stderr := cmd.ErrOrStderr()
+
+	threadStore, err := codex.OpenThreadStore(defaultCodexThreadStorePath)
+	if err != nil {
+		fmt.Fprintf(stderr, "warning: threads will not be recorded: %s\n", err)
+		return codex.Options{}, func() {}
+	}
+
+	options := codex.Options{
+		ThreadStore: threadStore,
+		ThreadStoreErrorHandler: func(ctx context.Context, threadID string, err error) {
+			fmt.Fprintf(stderr, "warning: failed to record thread %s: %s\n", threadID, err)
+		},
+	}
+	return options, func() { _ = threadStore.Close(context.WithoutCancel(cmd.Context())) }
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+// codexPrompt returns the prompt given as arguments, or reads it from stdin.
+func codexPrompt(stdin io.Reader, args []string) (string, error) {
+	if len(args) > 0 && !(len(args) == 1 && args[0] == "-") {
+		return strings.Join(args, " "), nil
+	}
+
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt from stdin: %w", err)
+	}
+	prompt := strings.TrimSpace(string(data))
+	if prompt == "" {
+		return "", errors.New("no prompt given as arguments or on stdin")
+	}
+	return prompt, nil
+}
+
+// writeCodexSummary prints the completed items of a turn and its token usage.
+func writeCodexSummary(w io.Writer, threadID string, turn codex.Turn, totals codex.UsageTotals) {
+	if threadID != "" {
+		fmt.Fprintf(w, "thread: %s\n\n", threadID)
+	}
+
+	for _, item := range turn.Items {
+		switch item := item.(type) {
+		case *codex.AgentMessageItem:
+			fmt.Fprintf(w, "message:\n%s\n", indentLines(strings.TrimRight(item.Text, "\n")))
+		case *codex.ReasoningItem:
+			fmt.Fprintf(w, "reasoning:\n%s\n", indentLines(strings.TrimRight(item.Text, "\n")))
+		case *codex.CommandExecutionItem:
+			status := string(item.Status)
+			if item.ExitCode != nil {
+				status = fmt.Sprintf("exit %d", *item.ExitCode)
+			}
+			fmt.Fprintf(w, "command: %s (%s)\n", item.Command, status)
+		case *codex.FileChangeItem:
+			fmt.Fprintf(w, "file changes (%s):\n", item.Status)
+			for _, change := range item.Changes {
+				marker := "M"
+				switch change.Kind {
+				case codex.PatchChangeKindAdd:
+					marker = "A"
+				case codex.PatchChangeKindDelete:
+					marker = "D"
+				}
+				fmt.Fprintf(w, "  %s %s\n", marker, change.Path)
+			}
+		case *codex.McpToolCallItem:
+			fmt.Fprintf(w, "tool: %s/%s (%s)\n", item.Server, item.Tool, item.Status)
+		case *codex.WebSearchItem:
+			fmt.Fprintf(w, "web search: %s\n", item.Query)
+		case *codex.TodoListItem:
+			fmt.Fprintln(w, "plan:")
+			for _, todo := range item.Items {
+				check := " "
+				if todo.Completed {
+					check = "x"
+				}
+				fmt.Fprintf(w, "  [%s] %s\n", check, todo.Text)
+			}
+		case *codex.ErrorItem:
+			fmt.Fprintf(w, "error: %s\n", item.Message)
+		default:
+			fmt.Fprintf(w, "%s\n", item.ItemType())
+		}
+	}
+
+	if turn.Usage != nil {
+		fmt.Fprintf(w, "\ntokens: %d input (%d cached), %d output\n",
+			turn.Usage.InputTokens, turn.Usage.CachedInputTokens, turn.Usage.OutputTokens)
+	}
+	if totals.Cost > 0 {
+		fmt.Fprintf(w, "estimated cost: $%.4f\n", totals.Cost)
+	}
+}
+
+func indentLines(s string) string {
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
@@ -25,6 +25,10 @@ const (
 )
 
 func init() {
+	addCodexFlags(rootCmd)
+	addCodexFlags(responsesCommand)
+	addCodexFlags(responsesChatCommand)
+
 	responsesCommand.AddCommand(
 		responsesChatCommand,
 		responsesGetCommand,
@@ -40,7 +44,11 @@ var responsesCommand = &cobra.Command{
 	Use:   "responses",
 	Short: "Manage the OpenAI Responses API",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		startResponsesChat(cmd.Context(), client, chatModel)
+		codexConfig, err := codexSettingsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		startResponsesChat(cmd.Context(), client, chatModel, codexConfig)
 
 		return nil
 	},
@@ -50,7 +58,11 @@ var responsesChatCommand = &cobra.Command{
 	Use:   "chat",
 	Short: "Chat with the OpenAI Responses API",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		startResponsesChat(cmd.Context(), client, chatModel)
+		codexConfig, err := codexSettingsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		startResponsesChat(cmd.Context(), client, chatModel, codexConfig)
 
 		return nil
 	},
@@ -98,7 +110,7 @@ var responsesDeleteCommand = &cobra.Command{
 	},
 }
 
-func startResponsesChat(ctx context.Context, client *openai.Client, model string) error {
+func startResponsesChat(ctx context.Context, client *openai.Client, model string, codexConfig codexSettings) error {
 	// Set the terminal to raw mode.
 	fd := int(os.Stdout.Fd())
 	oldState, err := term.MakeRaw(fd)
@@ -158,7 +170,7 @@ func startResponsesChat(ctx context.Context, client *openai.Client, model string
 			return line, pos, false
 		}
 
-		for _, cmd := range []string{"exit", "clear", "delete", "copy", "tokens", "help"} {
+		for _, cmd := range []string{"exit", "clear", "delete", "copy", "tokens", "verbose", "/codex", "help"} {
 			if strings.HasPrefix(cmd, line) {
 				return cmd, len(cmd), true
 			}
@@ -256,9 +268,26 @@ func startResponsesChat(ctx context.Context, client *openai.Client, model string
 		lastMessage string
 		totalTokens int
 
-		codexThreadID string
+		codexClient  *codex.Client
+		codexThread  *codex.Thread
+		codexVerbose bool
 	)
 
+	// confirm asks the user to type "yes" before a risky change is applied.
+	confirm := func(question string) bool {
+		bt.WriteString("\033[0G" + styleWarning.Render(question) + " Type " + styleBold.Render("yes") + " to confirm: ")
+		bt.Flush()
+		answer, err := t.ReadLine()
+		return err == nil && strings.TrimSpace(answer) == "yes"
+	}
+
+	if codexConfig.SandboxMode == codex.SandboxModeDangerFullAccess &&
+		!confirm("@codex is configured with danger-full-access, which runs commands without a sandbox.") {
+		codexConfig.SandboxMode = codex.SandboxModeReadOnly
+		bt.WriteString("Codex sandbox set to " + string(codexConfig.SandboxMode) + ".\n")
+		bt.Flush()
+	}
+
 	for {
 		// Move to left edge.
 		bt.WriteString("\033[0G")
@@ -301,6 +330,17 @@ func startResponsesChat(ctx context.Context, client *openai.Client, model string
 			continue
 		}
 
+		if strings.TrimSpace(input) == "verbose" {
+			codexVerbose = !codexVerbose
+			if codexVerbose {
+				bt.WriteString("Codex verbose output enabled.\n")
+			} else {
+				bt.WriteString("Codex verbose output disabled.\n")
+			}
+			bt.Flush()
+			continue
+		}
+
 		if strings.TrimSpace(input) == "copy" {
 			if err := writeClipboard(lastMessage); err != nil {
 				bt.WriteString("Clipboard error: " + err.Error() + "\n")
@@ -346,75 +386,55 @@ func startResponsesChat(ctx context.Context, client *openai.Client, model string
 				bt.Flush()
 				continue
 			}
+		case "/codex":
+			message, err := applyCodexCommand(&codexConfig, fields[1:], confirm)
+			if err != nil {
+				bt.WriteString(err.Error() + "\n")
+				bt.Flush()
+				continue
+			}
+			codexThread = nil
+			if err := codexConfig.save(defaultCodexSettingsPath); err != nil {
+				bt.WriteString(styleWarning.Render("Codex settings not saved: ") + err.Error() + "\n")
+			}
+			bt.WriteString(message + "\n")
+			bt.Flush()
+			continue
 		case "@codex":
-			for event, err := range codex.Run(ctx, codex.Args{
-				Input:       strings.Join(fields[1:], " "),
-				Model:       "gpt-5-codex",
-				SandboxMode: codex.SandboxModeReadOnly,
-				ThreadID:    codexThreadID,
-			}) {
-				if err != nil {
-					bt.WriteString("Codex error: " + err.Error() + "\n")
-					bt.Flush()
-					break
-				}
-				if event == nil {
-					break
-				}
-				switch event.Type {
-				case codex.EventTypeThreadStarted:
-					// bt.WriteString("\t" + styleFaint.Render(event.String()) + "\n")
-					// bt.WriteString("\033[0G")
-					// bt.Flush()
-					codexThreadID = event.ThreadID
-				case codex.EventTypeItemCompleted:
-					if item, ok := event.Item.(*codex.AgentMessageItem); ok {
-						s, err := renderMarkdown(strings.TrimRight(item.Text, "\n"), termWidth*3/4)
-						if err != nil {
-							bt.WriteString("Codex render error: " + err.Error() + "\n")
-							bt.Flush()
-							break
-						}
-						bt.WriteString(s)
-						// bt.WriteString("\033[0G")
+			if codexThread == nil {
+				if codexClient == nil {
+					if codexClient, err = codex.New(codex.Options{}); err != nil {
+						bt.WriteString("Codex error: " + err.Error() + "\n")
 						bt.Flush()
+						continue
 					}
-				case codex.EventTypeTurnCompleted:
-					// if event.Usage != nil {
-					// 	bt.WriteString("\033[0G")
-					// 	bt.WriteString(styleFaint.Render(fmt.Sprintf("\t%s\n", event.String())))
-					// 	bt.WriteString("\033[0G")
-					// 	bt.Flush()
-					// }
-				case codex.EventTypeTurnFailed:
-					if event.Error != nil {
-						bt.WriteString("Codex turn failed: " + event.Error.Message + "\n")
-						bt.Flush()
-						bt.WriteString("\033[0G")
-					}
-				case codex.EventTypeError:
-					bt.WriteString("Codex error: " + event.Message + "\n")
-					bt.WriteString("\033[0G")
+				}
+				if codexConfig.ThreadID != "" {
+					codexThread = codexClient.ResumeThread(codexConfig.ThreadID, codexConfig.threadOptions())
+				} else {
+					codexThread = codexClient.StartThread(codexConfig.threadOptions())
+				}
+			}
+
+			view := newCodexView(bt, termWidth, codexVerbose)
+			streamed, err := codexThread.RunStreamedText(ctx, strings.Join(fields[1:], " "), nil)
+			if err == nil {
+				for event := range streamed.Events {
+					view.handle(event)
+				}
+				err = streamed.Wait()
+			}
+			if err != nil {
+				bt.WriteString("\033[0G")
+				bt.WriteString("Codex error: " + err.Error() + "\n")
+				bt.Flush()
+			}
+
+			if id := codexThread.ID(); id != "" && id != codexConfig.ThreadID {
+				codexConfig.ThreadID = id
+				if err := codexConfig.save(defaultCodexSettingsPath); err != nil {
+					bt.WriteString(styleWarning.Render("Codex settings not saved: ") + err.Error() + "\n")
 					bt.Flush()
-				case codex.EventTypeItemStarted, codex.EventTypeItemUpdated:
-					// if commandExecItem, ok := event.Item.(*codex.CommandExecutionItem); ok {
-					// 	commandExecMarkdownBlock := "```bash\n" + commandExecItem.Command + "\n```"
-					// 	s, err := renderMarkdown(commandExecMarkdownBlock, termWidth*3/4)
-					// 	if err != nil {
-					// 		bt.WriteString("Codex render error: " + err.Error() + "\n")
-					// 	}
-					// 	bt.WriteString("\t" + s)
-					// 	bt.WriteString("\033[0G")
-					// 	bt.Flush()
-					// } else {
-					// 	bt.WriteString("\t" + styleFaint.Render(event.String()+"\n"))
-					// 	bt.WriteString("\033[0G")
-					// 	bt.Flush()
-					// }
-				default:
-					// bt.WriteString("\t" + styleFaint.Render(event.String()) + "\n")
-					// bt.WriteString("\033[0G")
-					// bt.Flush()
 				}
 			}
 			continue
@@ -570,6 +590,7 @@ func printResponsesChatHelp(bt *bufio.Writer) {
 	bt.WriteString("- " + styleFaint.Render("delete") + " to delete previous response (up to given number).\n")
 	bt.WriteString("- " + styleFaint.Render("copy") + " to copy last response to the clipboard.\n")
 	bt.WriteString("- " + styleFaint.Render("tokens") + " to show token usage.\n")
+	bt.WriteString("- " + styleFaint.Render("verbose") + " to toggle Codex command output and reasoning.\n")
 	bt.WriteString("- " + styleFaint.Render("help") + " to show this help.\n")
 	bt.WriteString("- " + styleFaint.Render("exit") + " to quit.\n\n")
 	bt.WriteString("Use " + styleInfo.Render("<clipboard>") + " to include clipboard content in a message.\n")
@@ -577,6 +598,7 @@ func printResponsesChatHelp(bt *bufio.Writer) {
 	bt.WriteString("\tUse " + styleKBD.Render("[TAB]") + " to cycle file paths forward and " + styleKBD.Render("\u2190/\u2192") + " (Alt+Left/Right) to cycle backward or forward.\n")
 	bt.WriteString("Use " + styleInfo.Render("#url:") + stylePath.Render("path") + " to include URL content in a message.\n")
 	bt.WriteString("Use " + styleAI.Render("@codex") + " to use Codex for code-related questions.\n")
+	bt.WriteString("\tUse " + styleFaint.Render("/codex status") + " to show its settings, or " + styleFaint.Render("/codex model|sandbox|cd|skip-git-check|reset|resume") + " to change them.\n")
 	bt.WriteString("\n")
 	bt.Flush()
 }
@@ -17,6 +17,10 @@ var rootCmd = &cobra.Command{
 			option.WithAPIKey(os.Getenv("OPENAI_API_KEY")),
 			option.WithHTTPClient(http.DefaultClient),
 		)
-		return startResponsesChat(cmd.Context(), &c, chatModel)
+		codexConfig, err := codexSettingsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		return startResponsesChat(cmd.Context(), &c, chatModel, codexConfig)
 	},
 }
@@ -10,4 +10,7 @@ var (
 	stylePath    = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD75F"))
 	styleAI      = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#6BCB77"))
 	styleKBD     = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF9CAC"))
+	styleAdd     = lipgloss.NewStyle().Foreground(lipgloss.Color("#6BCB77")) // Green
+	styleUpdate  = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD75F")) // Yellow
+	styleDelete  = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B")) // Red
 )
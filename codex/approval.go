@@ -0,0 +1,129 @@
+package codex
+
+import (
+	"context"
+	"fmt"
+)
+
+// ApprovalKind identifies the action an approval request refers to.
+type ApprovalKind string
+
+const (
+	ApprovalKindCommandExecution ApprovalKind = "command_execution"
+	ApprovalKindFileChange       ApprovalKind = "file_change"
+)
+
+// ApprovalDecision is the outcome returned by an ApprovalHandler.
+type ApprovalDecision string
+
+const (
+	ApprovalDecisionApprove ApprovalDecision = "approve"
+	ApprovalDecisionDeny    ApprovalDecision = "deny"
+)
+
+// ApprovalRequest describes an action proposed by the agent.
+type ApprovalRequest struct {
+	// Kind identifies whether the request is for a command or a file change.
+	Kind ApprovalKind
+	// ThreadID is the thread the action belongs to, when already assigned.
+	ThreadID string
+	// ItemID identifies the thread item that reported the action.
+	ItemID string
+	// Command is the shell command for ApprovalKindCommandExecution requests.
+	Command string
+	// Changes lists the affected paths for ApprovalKindFileChange requests.
+	Changes []FileUpdateChange
+	// Item is the thread item as first reported by the CLI.
+	Item ThreadItem
+}
+
+// ApprovalHandler decides whether an action proposed by the agent may proceed.
+//
+// `codex exec` has no channel for answering approval prompts, so the handler
+// acts as a gate on the event stream: it is called once per item, the first
+// time a command execution or file change is reported. Approving lets the turn
+// continue. Denying, or returning an error, stops the CLI and ends the turn
+// with an *ApprovalDeniedError or the returned error. Commands are reported
+// when they start and file changes once they have been applied, so a denial
+// prevents further work but cannot undo what was already done; combine the
+// handler with a restrictive SandboxMode for hard guarantees.
+type ApprovalHandler func(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error)
+
+// ApprovalDeniedError is returned when an ApprovalHandler denies an action.
+type ApprovalDeniedError struct {
+	// Request is the request that was denied.
+	Request ApprovalRequest
+}
+
+func (e *ApprovalDeniedError) Error() string {
+	switch e.Request.Kind {
+	case ApprovalKindCommandExecution:
+		return fmt.Sprintf("approval denied for command %q", e.Request.Command)
+	case ApprovalKindFileChange:
+		return fmt.Sprintf("approval denied for %d file change(s)", len(e.Request.Changes))
+	default:
+		return "approval denied"
+	}
+}
+
+// approvalGate invokes an ApprovalHandler for the items of a single turn.
+type approvalGate struct {
+	handler ApprovalHandler
+	seen    map[string]bool
+}
+
+func newApprovalGate(options ThreadOptions) *approvalGate {
+	if options.ApprovalHandler == nil {
+		return nil
+	}
+	switch options.ApprovalMode {
+	case ApprovalModeOnRequest, ApprovalModeUntrusted:
+		return &approvalGate{handler: options.ApprovalHandler, seen: make(map[string]bool)}
+	default:
+		return nil
+	}
+}
+
+// review returns a non-nil error when the event reports an action that must
+// not proceed.
+func (g *approvalGate) review(ctx context.Context, threadID string, event ThreadEvent) error {
+	if g == nil || event.Item == nil {
+		return nil
+	}
+
+	switch event.Type {
+	case EventTypeItemStarted, EventTypeItemUpdated, EventTypeItemCompleted:
+	default:
+		return nil
+	}
+
+	request := ApprovalRequest{ThreadID: threadID, Item: event.Item}
+	switch item := event.Item.(type) {
+	case *CommandExecutionItem:
+		request.Kind = ApprovalKindCommandExecution
+		request.ItemID = item.ID
+		request.Command = item.Command
+	case *FileChangeItem:
+		request.Kind = ApprovalKindFileChange
+		request.ItemID = item.ID
+		request.Changes = item.Changes
+	default:
+		return nil
+	}
+
+	if request.ItemID != "" {
+		if g.seen[request.ItemID] {
+			return nil
+		}
+		g.seen[request.ItemID] = true
+	}
+
+	decision, err := g.handler(ctx, request)
+	if err != nil {
+		return fmt.Errorf("approval handler: %w", err)
+	}
+	if decision != ApprovalDecisionApprove {
+		return &ApprovalDeniedError{Request: request}
+	}
+	return nil
+}
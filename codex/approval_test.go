@@ -0,0 +1,76 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuildCommandArgsApprovalMode(t *testing.T) {
+	args := buildCommandArgs(Args{ApprovalMode: ApprovalModeOnRequest})
+	if !slices.Contains(args, `approval_policy="on-request"`) {
+		t.Fatalf("expected approval policy override in %q", args)
+	}
+}
+
+func TestNewApprovalGateRequiresInteractiveMode(t *testing.T) {
+	handler := func(context.Context, ApprovalRequest) (ApprovalDecision, error) {
+		return ApprovalDecisionApprove, nil
+	}
+
+	if gate := newApprovalGate(ThreadOptions{ApprovalMode: ApprovalModeNever, ApprovalHandler: handler}); gate != nil {
+		t.Fatal("expected no gate for the never approval mode")
+	}
+	if gate := newApprovalGate(ThreadOptions{ApprovalMode: ApprovalModeOnRequest}); gate != nil {
+		t.Fatal("expected no gate without a handler")
+	}
+	if gate := newApprovalGate(ThreadOptions{ApprovalMode: ApprovalModeUntrusted, ApprovalHandler: handler}); gate == nil {
+		t.Fatal("expected a gate for the untrusted approval mode")
+	}
+}
+
+func TestApprovalGateReview(t *testing.T) {
+	var requests []ApprovalRequest
+	gate := newApprovalGate(ThreadOptions{
+		ApprovalMode: ApprovalModeOnRequest,
+		ApprovalHandler: func(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+			requests = append(requests, request)
+			if request.Kind == ApprovalKindCommandExecution && strings.HasPrefix(request.Command, "rm ") {
+				return ApprovalDecisionDeny, nil
+			}
+			return ApprovalDecisionApprove, nil
+		},
+	})
+
+	ctx := t.Context()
+
+	read := &CommandExecutionItem{ID: "cmd_1", Command: "cat README.md", Status: CommandExecutionStatusInProgress}
+	if err := gate.review(ctx, "thread_1", ThreadEvent{Type: EventTypeItemStarted, Item: read}); err != nil {
+		t.Fatalf("expected read command to be approved, got %v", err)
+	}
+	if err := gate.review(ctx, "thread_1", ThreadEvent{Type: EventTypeItemCompleted, Item: read}); err != nil {
+		t.Fatalf("expected completed item to be ignored, got %v", err)
+	}
+
+	message := &AgentMessageItem{ID: "msg_1", Text: "hi"}
+	if err := gate.review(ctx, "thread_1", ThreadEvent{Type: EventTypeItemCompleted, Item: message}); err != nil {
+		t.Fatalf("expected agent messages to be ignored, got %v", err)
+	}
+
+	remove := &CommandExecutionItem{ID: "cmd_2", Command: "rm -rf /", Status: CommandExecutionStatusInProgress}
+	err := gate.review(ctx, "thread_1", ThreadEvent{Type: EventTypeItemStarted, Item: remove})
+
+	var denied *ApprovalDeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("expected ApprovalDeniedError, got %v", err)
+	}
+	if denied.Request.ItemID != "cmd_2" || denied.Request.ThreadID != "thread_1" {
+		t.Fatalf("unexpected denied request: %+v", denied.Request)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("expected handler to be called once per item, got %d calls", len(requests))
+	}
+}
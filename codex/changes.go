@@ -0,0 +1,457 @@
+package codex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// ChangeSet holds the changes a turn made to the files of a git working tree,
+// as captured when ThreadOptions.CaptureChanges is set. Changes that were
+// already present before the turn are not included.
+type ChangeSet struct {
+	// Repository is the root of the working tree.
+	Repository string
+	// Files lists the changed files, sorted by path.
+	Files []FileDiff
+}
+
+// FileDiff is the change a turn made to a single file.
+type FileDiff struct {
+	// Path is the slash separated path of the file relative to the repository.
+	Path string
+	// Kind reports whether the file was added, deleted or updated.
+	Kind PatchChangeKind
+	// Binary is true when either version of the file is binary, in which case
+	// Diff only notes that the file changed.
+	Binary bool
+	// Diff is the unified diff of the change, in git format.
+	Diff string
+
+	before fileState
+	after  fileState
+}
+
+// Diff returns the unified diff of every changed file.
+func (c *ChangeSet) Diff() string {
+	var b strings.Builder
+	for _, file := range c.Files {
+		b.WriteString(file.Diff)
+	}
+	return b.String()
+}
+
+// CommitToBranch creates branch at the current HEAD, checks it out keeping
+// the working tree as is, and commits the captured changes to it. Changes
+// that were staged before the turn are committed too. When author is nil it
+// is read from the git configuration.
+func (c *ChangeSet) CommitToBranch(branch, message string, author *object.Signature) (plumbing.Hash, error) {
+	if len(c.Files) == 0 {
+		return plumbing.ZeroHash, errors.New("commit changes: no changes to commit")
+	}
+
+	repo, err := git.PlainOpen(c.Repository)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("commit changes: %w", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("commit changes: %w", err)
+	}
+
+	err = worktree.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Create: true,
+		Keep:   true,
+	})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("commit changes: create branch %q: %w", branch, err)
+	}
+
+	for _, file := range c.Files {
+		if file.after.exists {
+			_, err = worktree.Add(file.Path)
+		} else {
+			_, err = worktree.Remove(file.Path)
+		}
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("commit changes: stage %s: %w", file.Path, err)
+		}
+	}
+
+	hash, err := worktree.Commit(message, &git.CommitOptions{Author: author})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("commit changes: %w", err)
+	}
+	return hash, nil
+}
+
+// Revert restores every changed file to its content before the turn. Commits
+// made by the agent during the turn are not undone.
+func (c *ChangeSet) Revert() error {
+	var errs []error
+	for _, file := range c.Files {
+		name := filepath.Join(c.Repository, filepath.FromSlash(file.Path))
+		if !file.before.exists {
+			if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.WriteFile(name, file.before.content, file.before.perm()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("revert changes: %w", err)
+	}
+	return nil
+}
+
+// fileState is the content of a file at a point in time.
+type fileState struct {
+	exists  bool
+	content []byte
+	mode    fs.FileMode
+}
+
+func (s fileState) perm() fs.FileMode {
+	if s.mode == 0 {
+		return 0o644
+	}
+	return s.mode.Perm()
+}
+
+func (s fileState) equal(other fileState) bool {
+	return s.exists == other.exists && bytes.Equal(s.content, other.content)
+}
+
+// changeSnapshot records the state of a working tree before a turn, so the
+// changes made by the turn can be computed afterwards.
+type changeSnapshot struct {
+	repo *git.Repository
+	root string
+	head plumbing.Hash
+	// dirty holds the content of the files that differed from HEAD.
+	dirty map[string]fileState
+}
+
+func snapshotChanges(dir string) (*changeSnapshot, error) {
+	if dir == "" {
+		dir = "."
+	}
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("capture changes in %s: %w", dir, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("capture changes in %s: %w", dir, err)
+	}
+
+	snapshot := &changeSnapshot{
+		repo:  repo,
+		root:  worktree.Filesystem.Root(),
+		dirty: make(map[string]fileState),
+	}
+	if snapshot.head, err = headHash(repo); err != nil {
+		return nil, fmt.Errorf("capture changes in %s: %w", dir, err)
+	}
+
+	paths, err := dirtyPaths(worktree)
+	if err != nil {
+		return nil, fmt.Errorf("capture changes in %s: %w", dir, err)
+	}
+	for _, path := range paths {
+		state, err := snapshot.read(path)
+		if err != nil {
+			return nil, fmt.Errorf("capture changes in %s: %w", dir, err)
+		}
+		snapshot.dirty[path] = state
+	}
+	return snapshot, nil
+}
+
+// changes compares the working tree with the snapshot.
+func (s *changeSnapshot) changes() (*ChangeSet, error) {
+	worktree, err := s.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("capture changes: %w", err)
+	}
+
+	candidates := make(map[string]bool, len(s.dirty))
+	for path := range s.dirty {
+		candidates[path] = true
+	}
+
+	paths, err := dirtyPaths(worktree)
+	if err != nil {
+		return nil, fmt.Errorf("capture changes: %w", err)
+	}
+	for _, path := range paths {
+		candidates[path] = true
+	}
+
+	// Files committed by the agent no longer show up as dirty, so the trees of
+	// the old and new HEAD are compared as well.
+	head, err := headHash(s.repo)
+	if err != nil {
+		return nil, fmt.Errorf("capture changes: %w", err)
+	}
+	if head != s.head {
+		committed, err := s.committedPaths(head)
+		if err != nil {
+			return nil, fmt.Errorf("capture changes: %w", err)
+		}
+		for _, path := range committed {
+			candidates[path] = true
+		}
+	}
+
+	changes := &ChangeSet{Repository: s.root}
+	for path := range candidates {
+		before, err := s.before(path)
+		if err != nil {
+			return nil, fmt.Errorf("capture changes: %w", err)
+		}
+		after, err := s.read(path)
+		if err != nil {
+			return nil, fmt.Errorf("capture changes: %w", err)
+		}
+		if before.equal(after) {
+			continue
+		}
+		file, err := newFileDiff(path, before, after)
+		if err != nil {
+			return nil, fmt.Errorf("capture changes: diff %s: %w", path, err)
+		}
+		changes.Files = append(changes.Files, file)
+	}
+
+	sort.Slice(changes.Files, func(i, j int) bool {
+		return changes.Files[i].Path < changes.Files[j].Path
+	})
+	return changes, nil
+}
+
+// before returns the state of path when the snapshot was taken.
+func (s *changeSnapshot) before(path string) (fileState, error) {
+	if state, ok := s.dirty[path]; ok {
+		return state, nil
+	}
+	if s.head.IsZero() {
+		return fileState{}, nil
+	}
+
+	commit, err := s.repo.CommitObject(s.head)
+	if err != nil {
+		return fileState{}, err
+	}
+	file, err := commit.File(path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return fileState{}, nil
+	}
+	if err != nil {
+		return fileState{}, err
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return fileState{}, err
+	}
+	mode, err := file.Mode.ToOSFileMode()
+	if err != nil {
+		mode = 0o644
+	}
+	return fileState{exists: true, content: []byte(content), mode: mode}, nil
+}
+
+// read returns the current state of path in the working tree.
+func (s *changeSnapshot) read(path string) (fileState, error) {
+	name := filepath.Join(s.root, filepath.FromSlash(path))
+	info, err := os.Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fileState{}, nil
+	}
+	if err != nil {
+		return fileState{}, err
+	}
+	if info.IsDir() {
+		return fileState{}, nil
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{exists: true, content: content, mode: info.Mode()}, nil
+}
+
+func (s *changeSnapshot) committedPaths(head plumbing.Hash) ([]string, error) {
+	newTree, err := commitTree(s.repo, head)
+	if err != nil {
+		return nil, err
+	}
+	oldTree, err := commitTree(s.repo, s.head)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(oldTree, newTree)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" {
+				paths = append(paths, name)
+			}
+		}
+	}
+	return paths, nil
+}
+
+func commitTree(repo *git.Repository, hash plumbing.Hash) (*object.Tree, error) {
+	if hash.IsZero() {
+		return nil, nil
+	}
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// headHash returns the commit HEAD points to, or the zero hash when the
+// repository has no commits yet.
+func headHash(repo *git.Repository) (plumbing.Hash, error) {
+	ref, err := repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return ref.Hash(), nil
+}
+
+func dirtyPaths(worktree *git.Worktree) ([]string, error) {
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for path, file := range status {
+		if file.Staging != git.Unmodified || file.Worktree != git.Unmodified {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+func newFileDiff(path string, before, after fileState) (FileDiff, error) {
+	file := FileDiff{
+		Path:   path,
+		Kind:   PatchChangeKindUpdate,
+		Binary: (before.exists && isBinary(before.content)) || (after.exists && isBinary(after.content)),
+		before: before,
+		after:  after,
+	}
+	switch {
+	case !before.exists:
+		file.Kind = PatchChangeKindAdd
+	case !after.exists:
+		file.Kind = PatchChangeKindDelete
+	}
+
+	patch := &filePatch{binary: file.Binary}
+	if before.exists {
+		patch.from = newPatchFile(path, before)
+	}
+	if after.exists {
+		patch.to = newPatchFile(path, after)
+	}
+	if !file.Binary {
+		for _, d := range diff.Do(string(before.content), string(after.content)) {
+			operation := fdiff.Equal
+			switch d.Type {
+			case diffmatchpatch.DiffInsert:
+				operation = fdiff.Add
+			case diffmatchpatch.DiffDelete:
+				operation = fdiff.Delete
+			}
+			patch.chunks = append(patch.chunks, patchChunk{content: d.Text, op: operation})
+		}
+	}
+
+	var b strings.Builder
+	if err := fdiff.NewUnifiedEncoder(&b, fdiff.DefaultContextLines).Encode(unifiedPatch{patch}); err != nil {
+		return FileDiff{}, err
+	}
+	file.Diff = b.String()
+	return file, nil
+}
+
+// The types below adapt captured file states to the patch interfaces of
+// go-git's unified diff encoder.
+
+type unifiedPatch []fdiff.FilePatch
+
+func (p unifiedPatch) FilePatches() []fdiff.FilePatch { return p }
+func (p unifiedPatch) Message() string                { return "" }
+
+type filePatch struct {
+	binary   bool
+	from, to fdiff.File
+	chunks   []fdiff.Chunk
+}
+
+func (p *filePatch) IsBinary() bool               { return p.binary }
+func (p *filePatch) Files() (from, to fdiff.File) { return p.from, p.to }
+func (p *filePatch) Chunks() []fdiff.Chunk        { return p.chunks }
+
+type patchFile struct {
+	hash plumbing.Hash
+	mode filemode.FileMode
+	path string
+}
+
+func newPatchFile(path string, state fileState) *patchFile {
+	mode, err := filemode.NewFromOSFileMode(state.mode)
+	if err != nil || mode == filemode.Empty {
+		mode = filemode.Regular
+	}
+	return &patchFile{
+		hash: plumbing.ComputeHash(plumbing.BlobObject, state.content),
+		mode: mode,
+		path: path,
+	}
+}
+
+func (f *patchFile) Hash() plumbing.Hash     { return f.hash }
+func (f *patchFile) Mode() filemode.FileMode { return f.mode }
+func (f *patchFile) Path() string            { return f.path }
+
+type patchChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c patchChunk) Content() string       { return c.content }
+func (c patchChunk) Type() fdiff.Operation { return c.op }
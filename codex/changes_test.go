@@ -0,0 +1,178 @@
+package codex
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var testSignature = &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(0, 0)}
+
+// initChangesRepo creates a repository with a committed main.go and
+// README.md, and an uncommitted edit to notes.txt.
+func initChangesRepo(t *testing.T) (string, *git.Repository) {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("PlainInit returned error: %v", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeRepoFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	writeRepoFile(t, dir, "README.md", "# demo\n")
+	writeRepoFile(t, dir, "notes.txt", "draft\n")
+	for _, name := range []string{"main.go", "README.md", "notes.txt"} {
+		if _, err := worktree.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := worktree.Commit("initial", &git.CommitOptions{Author: testSignature}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeRepoFile(t, dir, "notes.txt", "draft, edited by hand\n")
+	return dir, repo
+}
+
+func writeRepoFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// editingExecutor simulates an agent that edits main.go, adds util.go and
+// deletes README.md.
+func editingExecutor(dir string) ReaderExecutor {
+	return func(ctx context.Context, args Args) (io.Reader, error) {
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n\trun()\n}\n"), 0o644); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "util.go"), []byte("package main\n\nfunc run() {}\n"), 0o644); err != nil {
+			return nil, err
+		}
+		if err := os.Remove(filepath.Join(dir, "README.md")); err != nil {
+			return nil, err
+		}
+		return strings.NewReader(`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`), nil
+	}
+}
+
+func runCapturingTurn(t *testing.T, dir string) Turn {
+	t.Helper()
+
+	client, err := New(Options{Executor: editingExecutor(dir)})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	thread := client.StartThread(ThreadOptions{WorkingDirectory: dir, CaptureChanges: true})
+	turn, err := thread.RunText(t.Context(), "wire up run", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if turn.Changes == nil {
+		t.Fatal("expected changes to be captured")
+	}
+	return turn
+}
+
+func TestCaptureChanges(t *testing.T) {
+	dir, _ := initChangesRepo(t)
+	turn := runCapturingTurn(t, dir)
+
+	var kinds []string
+	for _, file := range turn.Changes.Files {
+		kinds = append(kinds, file.Path+":"+string(file.Kind))
+	}
+	if got := strings.Join(kinds, " "); got != "README.md:delete main.go:update util.go:add" {
+		t.Fatalf("unexpected changed files: %s", got)
+	}
+
+	mainDiff := turn.Changes.Files[1].Diff
+	for _, want := range []string{
+		"diff --git a/main.go b/main.go",
+		"-func main() {}",
+		"+func main() {",
+		"+\trun()",
+	} {
+		if !strings.Contains(mainDiff, want) {
+			t.Fatalf("expected main.go diff to contain %q, got:\n%s", want, mainDiff)
+		}
+	}
+	if diff := turn.Changes.Diff(); !strings.Contains(diff, "new file mode 100644") || !strings.Contains(diff, "deleted file mode 100644") {
+		t.Fatalf("expected added and deleted files in the diff, got:\n%s", diff)
+	}
+
+	if err := turn.Changes.Revert(); err != nil {
+		t.Fatalf("Revert returned error: %v", err)
+	}
+	for name, want := range map[string]string{
+		"main.go":   "package main\n\nfunc main() {}\n",
+		"README.md": "# demo\n",
+		"notes.txt": "draft, edited by hand\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != want {
+			t.Fatalf("expected %s to be restored to %q, got %q (%v)", name, want, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "util.go")); !os.IsNotExist(err) {
+		t.Fatalf("expected util.go to be removed, got err=%v", err)
+	}
+}
+
+func TestChangeSetCommitToBranch(t *testing.T) {
+	dir, repo := initChangesRepo(t)
+	turn := runCapturingTurn(t, dir)
+
+	hash, err := turn.Changes.CommitToBranch("agent/run", "Wire up run", testSignature)
+	if err != nil {
+		t.Fatalf("CommitToBranch returned error: %v", err)
+	}
+
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName("agent/run"), true)
+	if err != nil || ref.Hash() != hash {
+		t.Fatalf("expected branch to point at %s, got %v (%v)", hash, ref, err)
+	}
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := commit.File("util.go"); err != nil {
+		t.Fatalf("expected util.go to be committed: %v", err)
+	}
+	if _, err := commit.File("README.md"); err == nil {
+		t.Fatal("expected README.md to be deleted in the commit")
+	}
+	notes, err := commit.File("notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := notes.Contents(); content != "draft\n" {
+		t.Fatalf("expected the pre-existing edit to stay uncommitted, got %q", content)
+	}
+}
+
+func TestCaptureChangesRequiresRepository(t *testing.T) {
+	client, err := New(Options{Executor: NewReaderExecutor(strings.NewReader(""))})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	thread := client.StartThread(ThreadOptions{WorkingDirectory: t.TempDir(), CaptureChanges: true})
+	if _, err := thread.RunText(t.Context(), "hello", nil); err == nil || !strings.Contains(err.Error(), "capture changes") {
+		t.Fatalf("expected an error outside a repository, got %v", err)
+	}
+}
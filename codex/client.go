@@ -0,0 +1,74 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Client is the entry point for running codex agents. It mirrors the Codex
+// class exported by the TypeScript SDK.
+type Client struct {
+	executor Executor
+	options  Options
+}
+
+// New creates a Client from the provided options. Unless Options.Executor is
+// set, the codex binary is resolved once, using Options.CodexPathOverride when
+// set and PATH otherwise.
+func New(options Options) (*Client, error) {
+	executor := options.Executor
+	if executor == nil {
+		exec, err := NewExec(options.CodexPathOverride)
+		if err != nil {
+			return nil, err
+		}
+		executor = exec
+	}
+	return &Client{executor: executor, options: options}, nil
+}
+
+// StartThread starts a new conversation with an agent. The thread ID is
+// assigned by the CLI during the first turn and is available from Thread.ID
+// afterwards.
+func (c *Client) StartThread(options ThreadOptions) *Thread {
+	return c.newThread("", options)
+}
+
+// ResumeThread resumes a conversation with an agent based on a thread ID
+// returned by a previous run. Threads are persisted by the CLI under
+// ~/.codex/sessions.
+func (c *Client) ResumeThread(id string, options ThreadOptions) *Thread {
+	return c.newThread(id, options)
+}
+
+// ResumeStoredThread resumes a thread recorded in Options.ThreadStore with
+// the options of its most recent turn. It returns an error wrapping
+// ErrThreadNotFound when the store has no record of the thread.
+func (c *Client) ResumeStoredThread(ctx context.Context, id string) (*Thread, error) {
+	if c.options.ThreadStore == nil {
+		return nil, errors.New("resume stored thread: client has no thread store")
+	}
+	record, found, err := c.options.ThreadStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("resume stored thread %q: %w", id, ErrThreadNotFound)
+	}
+	return c.newThread(record.ID, record.threadOptions()), nil
+}
+
+func (c *Client) newThread(id string, options ThreadOptions) *Thread {
+	ledger := options.UsageLedger
+	if ledger == nil {
+		ledger = NewUsageLedger(nil, Budget{})
+	}
+	return &Thread{
+		executor:      c.executor,
+		options:       c.options,
+		threadOptions: options,
+		ledger:        ledger,
+		id:            id,
+	}
+}
@@ -0,0 +1,42 @@
+package codex
+
+import "testing"
+
+func TestNewUsesPathOverride(t *testing.T) {
+	client, err := New(Options{CodexPathOverride: "/opt/codex/bin/codex"})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	exec, ok := client.executor.(*Exec)
+	if !ok {
+		t.Fatalf("expected a local Exec, got %T", client.executor)
+	}
+	if exec.path != "/opt/codex/bin/codex" {
+		t.Fatalf("expected exec path override to be used, got %q", exec.path)
+	}
+}
+
+func TestClientStartAndResumeThread(t *testing.T) {
+	client, err := New(Options{CodexPathOverride: "codex", APIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	options := ThreadOptions{Model: "gpt-5-codex", SandboxMode: SandboxModeReadOnly}
+
+	started := client.StartThread(options)
+	if started.ID() != "" {
+		t.Fatalf("expected new thread to have no ID, got %q", started.ID())
+	}
+	if started.threadOptions.Model != options.Model || started.threadOptions.SandboxMode != options.SandboxMode {
+		t.Fatalf("unexpected thread options: %+v", started.threadOptions)
+	}
+	if started.options.APIKey != "test-key" {
+		t.Fatal("expected client options to be propagated to the thread")
+	}
+
+	resumed := client.ResumeThread("thread_123", options)
+	if resumed.ID() != "thread_123" {
+		t.Fatalf("expected resumed thread ID %q, got %q", "thread_123", resumed.ID())
+	}
+}
@@ -0,0 +1,17 @@
+// Package codextest provides helpers for testing code built on the codex
+// package without network access or credentials.
+//
+// A Fake is a stand-in codex executable that replays a scripted sequence of
+// JSONL lines, optionally followed by stderr output and a non-zero exit code.
+// Scripts are written by hand with Script and Step, or loaded from golden
+// transcripts captured from the real CLI with Record:
+//
+//	fake := codextest.NewFake(t, codextest.Events(
+//		codex.ThreadEvent{Type: codex.EventTypeThreadStarted, ThreadID: "thread_1"},
+//		codex.ThreadEvent{Type: codex.EventTypeTurnFailed, Error: &codex.ThreadError{Message: "boom"}},
+//	))
+//
+//	client, err := codex.New(codex.Options{CodexPathOverride: fake.Path()})
+//	...
+//	_, err = client.StartThread(codex.ThreadOptions{}).RunText(ctx, "hi", nil)
+package codextest
@@ -0,0 +1,110 @@
+package codextest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/picatz/openai/codex"
+)
+
+var _ codex.Executor = (*Executor)(nil)
+
+// Executor is an in-process codex.Executor that replays scripts without
+// starting a process, so it works on every platform. Like Fake, the nth run
+// replays scripts[n-1] and the last script is reused once they run out.
+type Executor struct {
+	mu      sync.Mutex
+	scripts []Script
+	runs    []codex.Args
+}
+
+// NewExecutor creates an Executor for the given scripts.
+func NewExecutor(scripts ...Script) *Executor {
+	if len(scripts) == 0 {
+		scripts = []Script{{}}
+	}
+	return &Executor{scripts: scripts}
+}
+
+// Client returns a codex.Client that runs turns on the executor. The executor
+// in options is replaced.
+func (e *Executor) Client(options codex.Options) *codex.Client {
+	options.Executor = e
+	client, err := codex.New(options)
+	if err != nil {
+		// New only fails when resolving a local binary, which an executor skips.
+		panic(fmt.Sprintf("codextest: create client: %v", err))
+	}
+	return client
+}
+
+// Runs returns the arguments of every run so far, in order.
+func (e *Executor) Runs() []codex.Args {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	runs := make([]codex.Args, len(e.runs))
+	copy(runs, e.runs)
+	return runs
+}
+
+// Run replays the next script.
+func (e *Executor) Run(ctx context.Context, args codex.Args) (*codex.ExecStream, error) {
+	e.mu.Lock()
+	script := e.scripts[min(len(e.runs), len(e.scripts)-1)]
+	e.runs = append(e.runs, args)
+	e.mu.Unlock()
+
+	lines, err := script.lines()
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		defer close(done)
+		for i, line := range lines {
+			if delay := script.Steps[i].Delay; delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					pw.CloseWithError(ctx.Err())
+					done <- ctx.Err()
+					return
+				}
+			}
+			if _, err := io.WriteString(pw, line+"\n"); err != nil {
+				done <- nil
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	wait := func() error {
+		select {
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			pw.CloseWithError(ctx.Err())
+			return ctx.Err()
+		}
+
+		if script.ExitCode != 0 {
+			return &codex.ExecError{ExitCode: script.ExitCode, Stderr: strings.TrimSpace(script.Stderr)}
+		}
+		return nil
+	}
+
+	return codex.NewExecStream(pr, wait), nil
+}
@@ -0,0 +1,167 @@
+//go:build unix
+
+package codextest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/picatz/openai/codex"
+)
+
+// Fake is a fake codex executable backed by a POSIX shell script. Each
+// invocation records its arguments and stdin, then replays a Script.
+type Fake struct {
+	dir  string
+	path string
+}
+
+// Invocation describes a single run of a Fake.
+type Invocation struct {
+	// Args are the command line arguments, excluding the program name.
+	Args []string
+	// Input is everything written to the fake's stdin, i.e. the prompt.
+	Input string
+}
+
+// NewFake writes a fake codex executable into a temporary directory owned by
+// tb. The nth invocation replays scripts[n-1]; once the scripts are exhausted
+// the last one is replayed again. With no scripts the fake prints nothing and
+// exits successfully.
+func NewFake(tb testing.TB, scripts ...Script) *Fake {
+	tb.Helper()
+
+	if len(scripts) == 0 {
+		scripts = []Script{{}}
+	}
+
+	dir := tb.TempDir()
+
+	var sh strings.Builder
+	sh.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&sh, "dir=%s\n", shellQuote(dir))
+	sh.WriteString(`n=$(( $(cat "$dir/count" 2>/dev/null || echo 0) + 1 ))` + "\n")
+	sh.WriteString(`echo "$n" > "$dir/count"` + "\n")
+	sh.WriteString(`printf '%s\0' "$@" > "$dir/args-$n"` + "\n")
+	sh.WriteString(`cat > "$dir/stdin-$n"` + "\n")
+	sh.WriteString(`case "$n" in` + "\n")
+
+	for i, script := range scripts {
+		lines, err := script.lines()
+		if err != nil {
+			tb.Fatalf("codextest: script %d: %v", i, err)
+		}
+
+		scriptDir := filepath.Join(dir, "script-"+strconv.Itoa(i))
+		if err := os.Mkdir(scriptDir, 0o755); err != nil {
+			tb.Fatalf("codextest: %v", err)
+		}
+
+		if i == len(scripts)-1 {
+			sh.WriteString("*)\n")
+		} else {
+			fmt.Fprintf(&sh, "%d)\n", i+1)
+		}
+
+		for j, line := range lines {
+			lineFile := filepath.Join(scriptDir, "line-"+strconv.Itoa(j))
+			if err := os.WriteFile(lineFile, []byte(line+"\n"), 0o644); err != nil {
+				tb.Fatalf("codextest: %v", err)
+			}
+			if delay := script.Steps[j].Delay; delay > 0 {
+				// GNU and BSD sleep take fractions of a second, but POSIX
+				// sleep only takes whole seconds, so fall back to those.
+				fmt.Fprintf(&sh, "\tsleep %s 2>/dev/null || sleep %d\n",
+					strconv.FormatFloat(delay.Seconds(), 'f', -1, 64), int64(math.Ceil(delay.Seconds())))
+			}
+			fmt.Fprintf(&sh, "\tcat %s\n", shellQuote(lineFile))
+		}
+
+		if script.Stderr != "" {
+			stderrFile := filepath.Join(scriptDir, "stderr")
+			if err := os.WriteFile(stderrFile, []byte(script.Stderr), 0o644); err != nil {
+				tb.Fatalf("codextest: %v", err)
+			}
+			fmt.Fprintf(&sh, "\tcat %s >&2\n", shellQuote(stderrFile))
+		}
+
+		fmt.Fprintf(&sh, "\texit %d\n\t;;\n", script.ExitCode)
+	}
+	sh.WriteString("esac\n")
+
+	path := filepath.Join(dir, "codex")
+	if err := os.WriteFile(path, []byte(sh.String()), 0o755); err != nil {
+		tb.Fatalf("codextest: write fake codex: %v", err)
+	}
+
+	return &Fake{dir: dir, path: path}
+}
+
+// Replay creates a Fake that replays the transcript stored at path.
+func Replay(tb testing.TB, path string) *Fake {
+	tb.Helper()
+
+	script, err := LoadTranscript(path)
+	if err != nil {
+		tb.Fatalf("codextest: %v", err)
+	}
+	return NewFake(tb, script)
+}
+
+// Path returns the location of the fake executable, suitable for
+// codex.Options.CodexPathOverride or codex.NewExec.
+func (f *Fake) Path() string {
+	return f.path
+}
+
+// Client returns a codex.Client that runs the fake. The path override in
+// options is replaced.
+func (f *Fake) Client(tb testing.TB, options codex.Options) *codex.Client {
+	tb.Helper()
+
+	options.CodexPathOverride = f.path
+	client, err := codex.New(options)
+	if err != nil {
+		tb.Fatalf("codextest: create client: %v", err)
+	}
+	return client
+}
+
+// Invocations returns the recorded runs of the fake in order.
+func (f *Fake) Invocations() []Invocation {
+	data, err := os.ReadFile(filepath.Join(f.dir, "count"))
+	if err != nil {
+		return nil
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil
+	}
+
+	invocations := make([]Invocation, 0, count)
+	for n := 1; n <= count; n++ {
+		var invocation Invocation
+
+		if args, err := os.ReadFile(filepath.Join(f.dir, "args-"+strconv.Itoa(n))); err == nil && len(args) > 0 {
+			for arg := range bytes.SplitSeq(bytes.TrimSuffix(args, []byte{0}), []byte{0}) {
+				invocation.Args = append(invocation.Args, string(arg))
+			}
+		}
+		if input, err := os.ReadFile(filepath.Join(f.dir, "stdin-"+strconv.Itoa(n))); err == nil {
+			invocation.Input = string(input)
+		}
+
+		invocations = append(invocations, invocation)
+	}
+	return invocations
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
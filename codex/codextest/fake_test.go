@@ -0,0 +1,199 @@
+//go:build unix
+
+package codextest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/picatz/openai/codex"
+	"github.com/picatz/openai/codex/codextest"
+)
+
+func TestFakeRun(t *testing.T) {
+	fake := codextest.NewFake(t, codextest.Script{
+		Steps: []codextest.Step{
+			{Event: codex.ThreadEvent{Type: codex.EventTypeThreadStarted, ThreadID: "thread_1"}},
+			{Event: codex.ThreadEvent{Type: codex.EventTypeTurnStarted}},
+			{Event: codex.ThreadEvent{Type: codex.EventTypeItemCompleted, Item: &codex.AgentMessageItem{ID: "msg_1", Text: "hello"}}, Delay: 10 * time.Millisecond},
+			{Event: codex.ThreadEvent{Type: codex.EventTypeTurnCompleted, Usage: &codex.Usage{InputTokens: 5, OutputTokens: 1}}},
+		},
+	})
+
+	thread := fake.Client(t, codex.Options{}).StartThread(codex.ThreadOptions{Model: "gpt-5-codex"})
+
+	turn, err := thread.RunText(t.Context(), "say hello", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if turn.FinalResponse != "hello" {
+		t.Fatalf("expected final response %q, got %q", "hello", turn.FinalResponse)
+	}
+	if turn.Usage == nil || turn.Usage.InputTokens != 5 {
+		t.Fatalf("unexpected usage: %+v", turn.Usage)
+	}
+	if thread.ID() != "thread_1" {
+		t.Fatalf("expected thread ID to be tracked, got %q", thread.ID())
+	}
+
+	if _, err := thread.RunText(t.Context(), "again", nil); err != nil {
+		t.Fatalf("second Run returned error: %v", err)
+	}
+
+	invocations := fake.Invocations()
+	if len(invocations) != 2 {
+		t.Fatalf("expected 2 invocations, got %d", len(invocations))
+	}
+	if invocations[0].Input != "say hello" {
+		t.Fatalf("unexpected prompt %q", invocations[0].Input)
+	}
+	if !slices.Contains(invocations[0].Args, "gpt-5-codex") {
+		t.Fatalf("expected model argument, got %q", invocations[0].Args)
+	}
+	if !slices.Equal(invocations[1].Args[len(invocations[1].Args)-2:], []string{"resume", "thread_1"}) {
+		t.Fatalf("expected second run to resume the thread, got %q", invocations[1].Args)
+	}
+}
+
+func TestFakeTurnFailed(t *testing.T) {
+	fake := codextest.NewFake(t, codextest.Events(
+		codex.ThreadEvent{Type: codex.EventTypeThreadStarted, ThreadID: "thread_1"},
+		codex.ThreadEvent{Type: codex.EventTypeTurnFailed, Error: &codex.ThreadError{Message: "rate limited"}},
+	))
+
+	_, err := fake.Client(t, codex.Options{}).StartThread(codex.ThreadOptions{}).RunText(t.Context(), "hi", nil)
+	var turnErr *codex.TurnFailedError
+	if !errors.As(err, &turnErr) || turnErr.ThreadError.Message != "rate limited" {
+		t.Fatalf("expected turn failure, got %v", err)
+	}
+}
+
+func TestFakeMalformedLine(t *testing.T) {
+	fake := codextest.NewFake(t, codextest.Script{
+		Steps: []codextest.Step{
+			{Event: codex.ThreadEvent{Type: codex.EventTypeThreadStarted, ThreadID: "thread_1"}},
+			{Raw: "{not json"},
+		},
+	})
+
+	_, err := fake.Client(t, codex.Options{}).StartThread(codex.ThreadOptions{}).RunText(t.Context(), "hi", nil)
+	var protocolErr *codex.ProtocolError
+	if !errors.As(err, &protocolErr) || string(protocolErr.Line) != "{not json" {
+		t.Fatalf("expected protocol error, got %v", err)
+	}
+}
+
+func TestFakeExitCode(t *testing.T) {
+	fake := codextest.NewFake(t, codextest.Script{Stderr: "not logged in", ExitCode: 2})
+
+	_, err := fake.Client(t, codex.Options{}).StartThread(codex.ThreadOptions{}).RunText(t.Context(), "hi", nil)
+	var execErr *codex.ExecError
+	if !errors.As(err, &execErr) || execErr.ExitCode != 2 || execErr.Stderr != "not logged in" {
+		t.Fatalf("expected exit error with stderr, got %v", err)
+	}
+	if !errors.Is(err, codex.ErrAuth) {
+		t.Fatalf("expected auth failure, got %v", err)
+	}
+}
+
+func TestFakeApprovalDenied(t *testing.T) {
+	fake := codextest.NewFake(t, codextest.Script{
+		Steps: []codextest.Step{
+			{Event: codex.ThreadEvent{Type: codex.EventTypeThreadStarted, ThreadID: "thread_1"}},
+			{Event: codex.ThreadEvent{Type: codex.EventTypeItemStarted, Item: &codex.CommandExecutionItem{ID: "cmd_1", Command: "rm -rf build", Status: codex.CommandExecutionStatusInProgress}}},
+			{Event: codex.ThreadEvent{Type: codex.EventTypeTurnCompleted, Usage: &codex.Usage{}}, Delay: 5 * time.Second},
+		},
+	})
+
+	thread := fake.Client(t, codex.Options{}).StartThread(codex.ThreadOptions{
+		ApprovalMode: codex.ApprovalModeOnRequest,
+		ApprovalHandler: codex.ApprovalHandler(func(ctx context.Context, request codex.ApprovalRequest) (codex.ApprovalDecision, error) {
+			return codex.ApprovalDecisionDeny, nil
+		}),
+	})
+
+	start := time.Now()
+	_, err := thread.RunText(t.Context(), "clean up", nil)
+
+	var denied *codex.ApprovalDeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("expected ApprovalDeniedError, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Fatalf("expected the CLI to be stopped after denial, took %s", elapsed)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	source := codextest.NewFake(t, codextest.Script{
+		Steps: []codextest.Step{
+			{Event: codex.ThreadEvent{Type: codex.EventTypeThreadStarted, ThreadID: "thread_1"}},
+			{Event: codex.ThreadEvent{Type: codex.EventTypeItemCompleted, Item: &codex.AgentMessageItem{ID: "msg_1", Text: "recorded"}}},
+			{Event: codex.ThreadEvent{Type: codex.EventTypeTurnCompleted, Usage: &codex.Usage{OutputTokens: 3}}},
+		},
+	})
+
+	exec, err := codex.NewExec(source.Path())
+	if err != nil {
+		t.Fatalf("NewExec returned error: %v", err)
+	}
+
+	var transcript bytes.Buffer
+	events, err := codextest.Record(t.Context(), exec, codex.Args{Input: "record me"}, &transcript)
+	if err != nil {
+		t.Fatalf("Record returned error: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 recorded events, got %d", len(events))
+	}
+
+	script, err := codextest.ReadTranscript(&transcript)
+	if err != nil {
+		t.Fatalf("ReadTranscript returned error: %v", err)
+	}
+
+	replay := codextest.NewFake(t, script)
+	turn, err := replay.Client(t, codex.Options{}).StartThread(codex.ThreadOptions{}).RunText(t.Context(), "replay", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if turn.FinalResponse != "recorded" {
+		t.Fatalf("expected replayed final response, got %q", turn.FinalResponse)
+	}
+}
+
+func TestExecutor(t *testing.T) {
+	executor := codextest.NewExecutor(
+		codextest.Events(
+			codex.ThreadEvent{Type: codex.EventTypeThreadStarted, ThreadID: "thread_1"},
+			codex.ThreadEvent{Type: codex.EventTypeItemCompleted, Item: &codex.AgentMessageItem{ID: "msg_1", Text: "first"}},
+			codex.ThreadEvent{Type: codex.EventTypeTurnCompleted, Usage: &codex.Usage{}},
+		),
+		codextest.Script{Stderr: "boom", ExitCode: 1},
+	)
+
+	thread := executor.Client(codex.Options{}).StartThread(codex.ThreadOptions{Model: "gpt-5-codex"})
+
+	turn, err := thread.RunText(t.Context(), "first", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if turn.FinalResponse != "first" {
+		t.Fatalf("unexpected final response %q", turn.FinalResponse)
+	}
+
+	_, err = thread.RunText(t.Context(), "second", nil)
+	var execErr *codex.ExecError
+	if !errors.As(err, &execErr) || execErr.Stderr != "boom" {
+		t.Fatalf("expected scripted failure, got %v", err)
+	}
+
+	runs := executor.Runs()
+	if len(runs) != 2 || runs[0].Model != "gpt-5-codex" || runs[1].ThreadID != "thread_1" {
+		t.Fatalf("unexpected runs: %+v", runs)
+	}
+}
@@ -0,0 +1,79 @@
+package codextest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/picatz/openai/codex"
+)
+
+// Record runs codex with the given arguments and copies every line it writes
+// to stdout into w, producing a transcript that ReadTranscript can replay.
+// The decoded events are returned as well; lines that fail to decode are
+// still recorded but reported in the returned error.
+func Record(ctx context.Context, exec *codex.Exec, args codex.Args, w io.Writer) ([]codex.ThreadEvent, error) {
+	stream, err := exec.Run(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var (
+		events    []codex.ThreadEvent
+		decodeErr error
+	)
+
+	reader := bufio.NewReader(stream.Stdout())
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			if _, err := w.Write(append(trimmed, '\n')); err != nil {
+				return events, fmt.Errorf("write transcript: %w", err)
+			}
+
+			var event codex.ThreadEvent
+			if err := json.Unmarshal(trimmed, &event); err != nil {
+				decodeErr = errors.Join(decodeErr, fmt.Errorf("decode %q: %w", trimmed, err))
+			} else {
+				events = append(events, event)
+			}
+		}
+		if readErr != nil {
+			if !errors.Is(readErr, io.EOF) {
+				return events, fmt.Errorf("read codex output: %w", readErr)
+			}
+			break
+		}
+	}
+
+	if err := stream.Wait(); err != nil {
+		return events, errors.Join(decodeErr, err)
+	}
+	return events, decodeErr
+}
+
+// RecordFile is like Record but writes the transcript to path, creating parent
+// directories as needed. It is typically used to refresh golden files.
+func RecordFile(ctx context.Context, exec *codex.Exec, args codex.Args, path string) ([]codex.ThreadEvent, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create transcript directory: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create transcript: %w", err)
+	}
+
+	events, err := Record(ctx, exec, args, f)
+	if closeErr := f.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("close transcript: %w", closeErr)
+	}
+	return events, err
+}
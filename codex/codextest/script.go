@@ -0,0 +1,99 @@
+package codextest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/picatz/openai/codex"
+)
+
+// Step is a single line written to stdout by a fake codex run.
+type Step struct {
+	// Event is encoded as JSON when Raw is empty.
+	Event codex.ThreadEvent
+	// Raw is written verbatim instead of Event, which allows scripting
+	// malformed output.
+	Raw string
+	// Delay is waited before the line is written. A Fake rounds it up to
+	// whole seconds where sleep doesn't take fractions of a second, as
+	// only POSIX requires.
+	Delay time.Duration
+}
+
+// Script describes the behavior of a single fake codex invocation.
+type Script struct {
+	// Steps are written to stdout in order, one line each.
+	Steps []Step
+	// Stderr is written to stderr after all steps.
+	Stderr string
+	// ExitCode is the status the fake exits with.
+	ExitCode int
+}
+
+// Events creates a Script that emits the given events without delays and
+// exits successfully.
+func Events(events ...codex.ThreadEvent) Script {
+	steps := make([]Step, len(events))
+	for i, event := range events {
+		steps[i] = Step{Event: event}
+	}
+	return Script{Steps: steps}
+}
+
+// lines renders each step as a single line without the trailing newline.
+func (s Script) lines() ([]string, error) {
+	lines := make([]string, len(s.Steps))
+	for i, step := range s.Steps {
+		if step.Raw != "" {
+			if strings.ContainsAny(step.Raw, "\r\n") {
+				return nil, fmt.Errorf("step %d: raw line must not contain newlines", i)
+			}
+			lines[i] = step.Raw
+			continue
+		}
+		data, err := json.Marshal(step.Event)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: encode event: %w", i, err)
+		}
+		lines[i] = string(data)
+	}
+	return lines, nil
+}
+
+// ReadTranscript parses a JSONL transcript into a Script. Lines are kept
+// verbatim, so transcripts that contain malformed lines replay faithfully.
+// Blank lines are skipped.
+func ReadTranscript(r io.Reader) (Script, error) {
+	var script Script
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		script.Steps = append(script.Steps, Step{Raw: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return Script{}, fmt.Errorf("read transcript: %w", err)
+	}
+	return script, nil
+}
+
+// LoadTranscript reads a JSONL transcript file, such as one written by
+// RecordFile, into a Script.
+func LoadTranscript(path string) (Script, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Script{}, fmt.Errorf("open transcript: %w", err)
+	}
+	defer f.Close()
+
+	return ReadTranscript(f)
+}
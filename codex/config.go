@@ -0,0 +1,166 @@
+package codex
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Feature names an optional codex CLI feature toggled with --enable/--disable.
+// Any feature known to the installed CLI may be used, not only the constants
+// declared here.
+type Feature string
+
+const (
+	FeatureUnifiedExec        Feature = "unified_exec"
+	FeatureStreamableShell    Feature = "streamable_shell"
+	FeatureRmcpClient         Feature = "rmcp_client"
+	FeatureApplyPatchFreeform Feature = "apply_patch_freeform"
+	FeatureViewImageTool      Feature = "view_image_tool"
+	FeatureWebSearchRequest   Feature = "web_search_request"
+)
+
+// Features is a set of feature flags. A true value enables the feature and a
+// false value disables it; features absent from the set keep the CLI default.
+type Features map[Feature]bool
+
+func (f Features) flags() (enable, disable []string) {
+	for _, feature := range slices.Sorted(maps.Keys(f)) {
+		if feature == "" {
+			continue
+		}
+		if f[feature] {
+			enable = append(enable, string(feature))
+		} else {
+			disable = append(disable, string(feature))
+		}
+	}
+	return enable, disable
+}
+
+// ConfigOverrides are codex configuration values rendered as --config key=value
+// pairs. Keys may use dotted paths such as "model_providers.local.base_url".
+// Values are encoded as TOML: strings, booleans, integers, floats, slices and
+// string-keyed maps are supported.
+type ConfigOverrides map[string]any
+
+// mergeConfigOverrides layers the given overrides, with later values winning.
+func mergeConfigOverrides(layers ...ConfigOverrides) ConfigOverrides {
+	var merged ConfigOverrides
+	for _, layer := range layers {
+		if len(layer) == 0 {
+			continue
+		}
+		if merged == nil {
+			merged = make(ConfigOverrides, len(layer))
+		}
+		maps.Copy(merged, layer)
+	}
+	return merged
+}
+
+// render returns the overrides as sorted key=value strings.
+func (c ConfigOverrides) render() ([]string, error) {
+	rendered := make([]string, 0, len(c))
+	for _, key := range slices.Sorted(maps.Keys(c)) {
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("config override key must not be empty")
+		}
+		value, err := tomlValue(reflect.ValueOf(c[key]))
+		if err != nil {
+			return nil, fmt.Errorf("config override %q: %w", key, err)
+		}
+		rendered = append(rendered, key+"="+value)
+	}
+	return rendered, nil
+}
+
+func tomlValue(v reflect.Value) (string, error) {
+	if !v.IsValid() {
+		return "", fmt.Errorf("nil values cannot be represented in TOML")
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return "", fmt.Errorf("nil values cannot be represented in TOML")
+		}
+		return tomlValue(v.Elem())
+	case reflect.String:
+		return tomlString(v.String()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		s := strconv.FormatFloat(v.Float(), 'f', -1, 64)
+		if !strings.ContainsAny(s, ".eEn") {
+			s += ".0"
+		}
+		return s, nil
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, 0, v.Len())
+		for i := range v.Len() {
+			part, err := tomlValue(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return "", fmt.Errorf("map keys must be strings, got %s", v.Type().Key())
+		}
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		slices.Sort(keys)
+
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			part, err := tomlValue(v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())))
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, tomlString(key)+" = "+part)
+		}
+		return "{" + strings.Join(parts, ", ") + "}", nil
+	default:
+		return "", fmt.Errorf("unsupported value type %s", v.Type())
+	}
+}
+
+// tomlString renders s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
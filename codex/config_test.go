@@ -0,0 +1,82 @@
+package codex
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigOverridesRender(t *testing.T) {
+	overrides := ConfigOverrides{
+		"model_reasoning_effort":             "high",
+		"sandbox_workspace_write.network":    true,
+		"model_context_window":               200000,
+		"tools.temperature":                  0.5,
+		"shell_environment_policy.include":   []string{"PATH", "HOME"},
+		"model_providers.local":              map[string]any{"name": "Local", "base_url": "http://localhost:11434/v1"},
+		"notice.message":                     "say \"hi\"\n",
+		"shell_environment_policy.inherit_n": uint8(3),
+	}
+
+	rendered, err := overrides.render()
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+
+	expected := []string{
+		`model_context_window=200000`,
+		`model_providers.local={"base_url" = "http://localhost:11434/v1", "name" = "Local"}`,
+		`model_reasoning_effort="high"`,
+		`notice.message="say \"hi\"\n"`,
+		`sandbox_workspace_write.network=true`,
+		`shell_environment_policy.include=["PATH", "HOME"]`,
+		`shell_environment_policy.inherit_n=3`,
+		`tools.temperature=0.5`,
+	}
+	if !reflect.DeepEqual(expected, rendered) {
+		t.Fatalf("unexpected rendered overrides:\n%q", rendered)
+	}
+}
+
+func TestConfigOverridesRenderRejectsNil(t *testing.T) {
+	if _, err := (ConfigOverrides{"model": nil}).render(); err == nil {
+		t.Fatal("expected an error for nil override values")
+	}
+}
+
+func TestThreadExecArgs(t *testing.T) {
+	thread := &Thread{
+		options: Options{APIKey: "key"},
+		threadOptions: ThreadOptions{
+			Model:           "gpt-5-codex",
+			FullAuto:        true,
+			IncludePlanTool: true,
+			ConfigFile:      "profile.toml",
+			Features:        Features{FeatureWebSearchRequest: true, FeatureUnifiedExec: false},
+			ConfigOverrides: ConfigOverrides{"model_reasoning_effort": "low", "hide_agent_reasoning": true},
+		},
+	}
+
+	args, err := thread.execArgs("prompt", nil, "", &TurnOptions{
+		ConfigOverrides:   ConfigOverrides{"model_reasoning_effort": "high"},
+		OutputLastMessage: "last.txt",
+	})
+	if err != nil {
+		t.Fatalf("execArgs returned error: %v", err)
+	}
+
+	expected := []string{
+		"exec", "--json",
+		"--model", "gpt-5-codex",
+		"--output-last-message", "last.txt",
+		"--enable", "web_search_request",
+		"--disable", "unified_exec",
+		"--config", "hide_agent_reasoning=true",
+		"--config", `model_reasoning_effort="high"`,
+		"--config", "profile.toml",
+		"--full-auto",
+		"--include-plan-tool",
+	}
+	if got := buildCommandArgs(args); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("unexpected command args:\n%q", got)
+	}
+}
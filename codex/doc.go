@@ -5,5 +5,22 @@
 // that this SDK is unofficial and not endorsed by OpenAI, but it aims to
 // provide a similar level of functionality and ease of use.
 //
+// A Client starts or resumes threads, and each thread runs one or more turns:
+//
+//	client, err := codex.New(codex.Options{})
+//	if err != nil {
+//		// handle error
+//	}
+//
+//	thread := client.StartThread(codex.ThreadOptions{Model: "gpt-5-codex"})
+//	turn, err := thread.RunText(ctx, "Summarize this repository.", nil)
+//	if err != nil {
+//		// handle error
+//	}
+//	fmt.Println(turn.FinalResponse)
+//
+//	// Later, continue the same conversation.
+//	thread = client.ResumeThread(thread.ID(), codex.ThreadOptions{})
+//
 // https://github.com/openai/codex/tree/main/sdk/typescript/src
 package codex
@@ -0,0 +1,107 @@
+package codex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrCodexNotFound is matched by errors.Is when the codex binary cannot be
+	// found or started.
+	ErrCodexNotFound = errors.New("codex binary not found")
+	// ErrAuth is matched by errors.Is for an *ExecError or *TurnFailedError
+	// that reports missing or rejected credentials.
+	ErrAuth = errors.New("codex authentication failed")
+)
+
+// ExecError is returned when the codex process exits unsuccessfully.
+type ExecError struct {
+	// ExitCode is the process exit code, or -1 when it was killed by a signal.
+	ExitCode int
+	// Stderr holds the trimmed standard error output of the process.
+	Stderr string
+	// Err is the underlying error reported when waiting for the process.
+	Err error
+}
+
+func (e *ExecError) Error() string {
+	var reason string
+	switch {
+	case e.Err != nil:
+		reason = e.Err.Error()
+	default:
+		reason = fmt.Sprintf("exit status %d", e.ExitCode)
+	}
+	if e.Stderr != "" {
+		return fmt.Sprintf("codex exec failed: %s: %s", reason, e.Stderr)
+	}
+	return "codex exec failed: " + reason
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrAuth and stderr describes an
+// authentication failure.
+func (e *ExecError) Is(target error) bool {
+	return target == ErrAuth && isAuthFailure(e.Stderr)
+}
+
+// TurnFailedError is returned when the CLI reports turn.failed.
+type TurnFailedError struct {
+	// ThreadError is the failure reported by the CLI.
+	ThreadError ThreadError
+	// Items holds the items completed before the turn failed.
+	Items []ThreadItem
+}
+
+func (e *TurnFailedError) Error() string {
+	return e.ThreadError.Message
+}
+
+// Is reports whether target is ErrAuth and the failure describes an
+// authentication problem.
+func (e *TurnFailedError) Is(target error) bool {
+	return target == ErrAuth && isAuthFailure(e.ThreadError.Message)
+}
+
+// ProtocolError is returned when the CLI emits a line that is not a valid
+// JSON event.
+type ProtocolError struct {
+	// Line holds the raw bytes of the offending line.
+	Line []byte
+	// Err is the decoding error.
+	Err error
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("parse codex event: %v", e.Err)
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// authFailureMarkers are lowercase fragments of the messages the CLI and the
+// API print when credentials are missing or rejected.
+var authFailureMarkers = []string{
+	"unauthorized",
+	"invalid api key",
+	"incorrect api key",
+	"invalid_api_key",
+	"not logged in",
+	"login required",
+	"codex login",
+}
+
+func isAuthFailure(message string) bool {
+	message = strings.ToLower(message)
+	for _, marker := range authFailureMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package codex
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewExecMissingBinary(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	if _, err := NewExec(""); !errors.Is(err, ErrCodexNotFound) {
+		t.Fatalf("expected ErrCodexNotFound, got %v", err)
+	}
+
+	exec, err := NewExec(t.TempDir() + "/codex")
+	if err != nil {
+		t.Fatalf("NewExec returned error: %v", err)
+	}
+	if _, err := exec.Run(t.Context(), Args{}); !errors.Is(err, ErrCodexNotFound) {
+		t.Fatalf("expected ErrCodexNotFound, got %v", err)
+	}
+}
+
+func TestErrorsMatchAuthFailures(t *testing.T) {
+	tests := []struct {
+		err  error
+		auth bool
+	}{
+		{&ExecError{ExitCode: 1, Stderr: "Error: Not logged in. Run `codex login`."}, true},
+		{&ExecError{ExitCode: 1, Stderr: "sandbox denied"}, false},
+		{&TurnFailedError{ThreadError: ThreadError{Message: "401 Unauthorized: Incorrect API key provided"}}, true},
+		{&TurnFailedError{ThreadError: ThreadError{Message: "rate limited"}}, false},
+	}
+	for _, test := range tests {
+		if got := errors.Is(test.err, ErrAuth); got != test.auth {
+			t.Errorf("errors.Is(%q, ErrAuth) = %t, want %t", test.err, got, test.auth)
+		}
+	}
+}
+
+func TestExecErrorMessage(t *testing.T) {
+	err := &ExecError{ExitCode: 2, Stderr: "boom"}
+	if got, want := err.Error(), "codex exec failed: exit status 2: boom"; got != want {
+		t.Fatalf("unexpected message %q, want %q", got, want)
+	}
+}
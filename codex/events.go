@@ -107,6 +107,35 @@ func itemSummary(item ThreadItem) string {
 	}
 }
 
+// MarshalJSON encodes the event in the JSONL format emitted by codex exec, so
+// events built in Go round-trip through UnmarshalJSON.
+func (e ThreadEvent) MarshalJSON() ([]byte, error) {
+	var item json.RawMessage
+	if e.Item != nil {
+		data, err := MarshalThreadItem(e.Item)
+		if err != nil {
+			return nil, fmt.Errorf("encode thread item: %w", err)
+		}
+		item = data
+	}
+
+	return json.Marshal(struct {
+		Type     EventType       `json:"type"`
+		ThreadID string          `json:"thread_id,omitempty"`
+		Usage    *Usage          `json:"usage,omitempty"`
+		Error    *ThreadError    `json:"error,omitempty"`
+		Item     json.RawMessage `json:"item,omitempty"`
+		Message  string          `json:"message,omitempty"`
+	}{
+		Type:     e.Type,
+		ThreadID: e.ThreadID,
+		Usage:    e.Usage,
+		Error:    e.Error,
+		Item:     item,
+		Message:  e.Message,
+	})
+}
+
 // UnmarshalJSON customizes decoding to handle the polymorphic item payload.
 func (e *ThreadEvent) UnmarshalJSON(data []byte) error {
 	var aux struct {
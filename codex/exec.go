@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"iter"
 	"os"
 	"os/exec"
@@ -28,14 +29,22 @@ type Args struct {
 	Images            []string
 	Model             string
 	SandboxMode       SandboxMode
+	ApprovalMode      ApprovalMode
 	WorkingDirectory  string
 	SkipGitRepoCheck  bool
 	OutputSchemaFile  string
 	OutputLastMessage string
 	Enable            []string
+	Disable           []string
+	ConfigOverrides   []string
 	ConfigFile        string
 	FullAuto          bool
 	IncludePlanTool   bool
+
+	// hostFiles are the temporary files the thread wrote for this run, such
+	// as the output schema and downloaded images, which only exist on the
+	// host running the SDK.
+	hostFiles []string
 }
 
 type Exec struct {
@@ -85,7 +94,7 @@ func (s *ExecStream) Close() error {
 	return s.closeErr
 }
 
-func (e *Exec) Run(ctx context.Context, args Args) (*ExecStream, error) {
+func buildCommandArgs(args Args) []string {
 	commandArgs := []string{"exec", "--json"}
 
 	if args.Model != "" {
@@ -96,6 +105,10 @@ func (e *Exec) Run(ctx context.Context, args Args) (*ExecStream, error) {
 		commandArgs = append(commandArgs, "--sandbox", string(args.SandboxMode))
 	}
 
+	if args.ApprovalMode != "" {
+		commandArgs = append(commandArgs, "--config", fmt.Sprintf("approval_policy=%q", args.ApprovalMode))
+	}
+
 	if args.WorkingDirectory != "" {
 		commandArgs = append(commandArgs, "--cd", args.WorkingDirectory)
 	}
@@ -124,6 +137,18 @@ func (e *Exec) Run(ctx context.Context, args Args) (*ExecStream, error) {
 		}
 	}
 
+	for _, feature := range args.Disable {
+		if feature != "" {
+			commandArgs = append(commandArgs, "--disable", feature)
+		}
+	}
+
+	for _, override := range args.ConfigOverrides {
+		if override != "" {
+			commandArgs = append(commandArgs, "--config", override)
+		}
+	}
+
 	if args.ConfigFile != "" {
 		commandArgs = append(commandArgs, "--config", args.ConfigFile)
 	}
@@ -140,7 +165,17 @@ func (e *Exec) Run(ctx context.Context, args Args) (*ExecStream, error) {
 		commandArgs = append(commandArgs, "resume", args.ThreadID)
 	}
 
-	cmd := exec.CommandContext(ctx, e.path, commandArgs...)
+	return commandArgs
+}
+
+func (e *Exec) Run(ctx context.Context, args Args) (*ExecStream, error) {
+	return runCommand(ctx, e.path, buildCommandArgs(args), args)
+}
+
+// runCommand starts name with the given arguments, writes args.Input to its
+// stdin and streams its stdout.
+func runCommand(ctx context.Context, name string, commandArgs []string, args Args) (*ExecStream, error) {
+	cmd := exec.CommandContext(ctx, name, commandArgs...)
 
 	env := buildEnvironment(args.BaseURL, args.APIKey)
 	cmd.Env = env
@@ -161,6 +196,9 @@ func (e *Exec) Run(ctx context.Context, args Args) (*ExecStream, error) {
 	}
 
 	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("start codex exec: %w: %w", ErrCodexNotFound, err)
+		}
 		return nil, fmt.Errorf("start codex exec: %w", err)
 	}
 
@@ -190,11 +228,11 @@ func (e *Exec) Run(ctx context.Context, args Args) (*ExecStream, error) {
 		if err != nil {
 			var exitErr *exec.ExitError
 			if errors.As(err, &exitErr) {
-				stderrText := strings.TrimSpace(stderrBuf.String())
-				if stderrText != "" {
-					return fmt.Errorf("codex exec failed: %s: %s", exitErr, stderrText)
+				return &ExecError{
+					ExitCode: exitErr.ExitCode(),
+					Stderr:   strings.TrimSpace(stderrBuf.String()),
+					Err:      err,
 				}
-				return fmt.Errorf("codex exec failed: %w", err)
 			}
 			return err
 		}
@@ -234,7 +272,7 @@ func buildEnvironment(baseURL, apiKey string) []string {
 func findCodexPath() (string, error) {
 	codexPath, err := exec.LookPath("codex")
 	if err != nil {
-		return "", fmt.Errorf("find codex binary: %w", err)
+		return "", fmt.Errorf("find codex binary: %w: %w", ErrCodexNotFound, err)
 	}
 	return codexPath, nil
 }
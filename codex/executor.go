@@ -0,0 +1,136 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Executor runs a single `codex exec` invocation and streams its JSONL output.
+// Threads use an Executor for every turn, which allows the CLI to run
+// somewhere other than a local subprocess.
+type Executor interface {
+	Run(ctx context.Context, args Args) (*ExecStream, error)
+}
+
+var (
+	_ Executor = (*Exec)(nil)
+	_ Executor = (*ContainerExec)(nil)
+	_ Executor = ReaderExecutor(nil)
+)
+
+// NewExecStream creates an ExecStream for custom Executor implementations.
+// stdout yields the JSONL events and wait blocks until the run has finished,
+// returning its terminal error. A nil wait function reports success.
+func NewExecStream(stdout io.ReadCloser, wait func() error) *ExecStream {
+	return &ExecStream{stdout: stdout, waitFn: wait}
+}
+
+// ContainerExec runs the codex CLI through a command prefix, such as
+// `docker exec -i <container>` or `ssh <host>`, so the agent runs inside an
+// isolated sandbox.
+//
+// The codex arguments are appended to the prefix as-is. Paths such as
+// ThreadOptions.WorkingDirectory and local images must therefore be valid
+// where the CLI runs. The API key and base URL are set in the environment of
+// the prefix command; the prefix is responsible for forwarding them, e.g.
+// with `docker exec -e CODEX_API_KEY -e OPENAI_BASE_URL`.
+//
+// Runs that need temporary files written on the host are rejected, since the
+// CLI can't read them: this covers TurnOptions.OutputSchema (and so RunTyped),
+// and images read from file, directory or URL input parts.
+type ContainerExec struct {
+	prefix    []string
+	codexPath string
+}
+
+// NewContainerExec creates a ContainerExec that runs codexPath through the
+// given command prefix. An empty codexPath defaults to "codex".
+func NewContainerExec(prefix []string, codexPath string) (*ContainerExec, error) {
+	if len(prefix) == 0 || prefix[0] == "" {
+		return nil, errors.New("container exec command prefix must not be empty")
+	}
+	if codexPath == "" {
+		codexPath = "codex"
+	}
+	return &ContainerExec{prefix: slices.Clone(prefix), codexPath: codexPath}, nil
+}
+
+// Run starts the prefixed codex command.
+func (e *ContainerExec) Run(ctx context.Context, args Args) (*ExecStream, error) {
+	if len(args.hostFiles) > 0 {
+		return nil, fmt.Errorf("container exec: %s is a temporary file on the host, which the codex CLI cannot read; output schemas and images from file, directory or URL inputs are not supported", args.hostFiles[0])
+	}
+	commandArgs := slices.Concat(e.prefix[1:], []string{e.codexPath}, buildCommandArgs(args))
+	return runCommand(ctx, e.prefix[0], commandArgs, args)
+}
+
+// ReaderExecutor serves JSONL events from an io.Reader instead of running
+// the CLI, e.g. to replay a transcript or consume events relayed over the
+// network. The function is called once per run with the arguments the CLI
+// would have received. If the returned reader is an io.Closer it is closed
+// when the run ends or its context is cancelled.
+type ReaderExecutor func(ctx context.Context, args Args) (io.Reader, error)
+
+// NewReaderExecutor creates a ReaderExecutor that serves r to the first run.
+// Later runs fail, since the reader has already been consumed.
+func NewReaderExecutor(r io.Reader) ReaderExecutor {
+	var once sync.Once
+	return func(ctx context.Context, args Args) (io.Reader, error) {
+		var served io.Reader
+		once.Do(func() { served = r })
+		if served == nil {
+			return nil, errors.New("reader executor: reader already consumed")
+		}
+		return served, nil
+	}
+}
+
+// Run opens the reader for args and streams it until EOF or until ctx is
+// cancelled.
+func (f ReaderExecutor) Run(ctx context.Context, args Args) (*ExecStream, error) {
+	r, err := f(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("open reader: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := io.Copy(pw, r)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	stop := context.AfterFunc(ctx, func() {
+		pw.CloseWithError(ctx.Err())
+		if closer, ok := r.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	})
+
+	wait := func() error {
+		var copyErr error
+		select {
+		case copyErr = <-done:
+		case <-ctx.Done():
+		}
+		stop()
+		if closer, ok := r.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if copyErr != nil && !errors.Is(copyErr, io.ErrClosedPipe) {
+			return fmt.Errorf("read events: %w", copyErr)
+		}
+		return nil
+	}
+
+	return NewExecStream(pr, wait), nil
+}
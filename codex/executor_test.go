@@ -0,0 +1,92 @@
+package codex
+
+import (
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReaderExecutor(t *testing.T) {
+	transcript := strings.Join([]string{
+		`{"type":"thread.started","thread_id":"thread_1"}`,
+		`{"type":"item.completed","item":{"id":"msg_1","type":"agent_message","text":"from reader"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	}, "\n")
+
+	client, err := New(Options{Executor: NewReaderExecutor(strings.NewReader(transcript))})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	thread := client.StartThread(ThreadOptions{})
+	turn, err := thread.RunText(t.Context(), "hi", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if turn.FinalResponse != "from reader" {
+		t.Fatalf("unexpected final response %q", turn.FinalResponse)
+	}
+	if thread.ID() != "thread_1" {
+		t.Fatalf("expected thread ID to be tracked, got %q", thread.ID())
+	}
+
+	if _, err := thread.RunText(t.Context(), "again", nil); err == nil {
+		t.Fatal("expected second run to fail once the reader is consumed")
+	}
+}
+
+func TestContainerExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	if _, err := NewContainerExec(nil, ""); err == nil {
+		t.Fatal("expected an error for an empty prefix")
+	}
+
+	// The prefix stands in for `docker exec`: it echoes the codex path and
+	// model it was handed back as a thread.started event.
+	exec, err := NewContainerExec([]string{
+		"sh", "-c", `cat >/dev/null; printf '{"type":"thread.started","thread_id":"%s:%s"}\n' "$1" "$5"`, "sh",
+	}, "/usr/local/bin/codex")
+	if err != nil {
+		t.Fatalf("NewContainerExec returned error: %v", err)
+	}
+
+	stream, err := exec.Run(t.Context(), Args{Input: "hi", Model: "gpt-5-codex"})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	var ids []string
+	for event, err := range EventStream(t.Context(), stream) {
+		if err != nil {
+			t.Fatalf("EventStream returned error: %v", err)
+		}
+		ids = append(ids, event.ThreadID)
+	}
+
+	if !slices.Equal(ids, []string{"/usr/local/bin/codex:gpt-5-codex"}) {
+		t.Fatalf("unexpected events: %q", ids)
+	}
+}
+
+func TestContainerExec_hostFiles(t *testing.T) {
+	exec, err := NewContainerExec([]string{"docker", "exec", "-i", "sandbox"}, "")
+	if err != nil {
+		t.Fatalf("NewContainerExec returned error: %v", err)
+	}
+
+	client, err := New(Options{Executor: exec})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	_, err = client.StartThread(ThreadOptions{}).RunText(t.Context(), "hi", &TurnOptions{
+		OutputSchema: map[string]any{"type": "object", "properties": map[string]any{}, "additionalProperties": false},
+	})
+	if err == nil || !strings.Contains(err.Error(), "temporary file on the host") {
+		t.Fatalf("expected output schemas to be rejected, got %v", err)
+	}
+}
@@ -9,7 +9,7 @@ import (
 
 // Input represents the user-provided content for a single agent turn.
 // Use TextInput to send a plain string prompt, or ComposeInput with individual
-// parts when mixing text and local images.
+// parts when mixing text, local images, files, directories and URLs.
 type Input struct {
 	// Prompt is the base textual prompt sent to the CLI.
 	Prompt string
@@ -36,8 +36,15 @@ type UserInput struct {
 	Type InputType `json:"type"`
 	// Text contains the textual prompt for InputTypeText entries.
 	Text string `json:"text,omitempty"`
-	// Path contains the local filesystem path for InputTypeLocalImage entries.
+	// Path contains the local filesystem path for InputTypeLocalImage,
+	// InputTypeLocalFile and InputTypeDirectory entries.
 	Path string `json:"path,omitempty"`
+	// Pattern optionally filters the files of an InputTypeDirectory entry. It
+	// uses path.Match syntax against paths relative to the directory; a pattern
+	// without a slash matches file names at any depth.
+	Pattern string `json:"pattern,omitempty"`
+	// URL contains the http or https address for InputTypeURL entries.
+	URL string `json:"url,omitempty"`
 }
 
 // InputType enumerates the supported user input kinds.
@@ -46,6 +53,14 @@ type InputType string
 const (
 	InputTypeText       InputType = "text"
 	InputTypeLocalImage InputType = "local_image"
+	// InputTypeLocalFile inlines a text file into the prompt. Image files are
+	// passed to the CLI as images instead.
+	InputTypeLocalFile InputType = "local_file"
+	// InputTypeDirectory inlines a snapshot of the text files in a directory.
+	InputTypeDirectory InputType = "directory"
+	// InputTypeURL fetches a remote resource. Images are downloaded to a
+	// temporary file and passed to the CLI as images; text is inlined.
+	InputTypeURL InputType = "url"
 )
 
 // TextPart constructs a textual user input segment.
@@ -58,6 +73,22 @@ func LocalImagePart(path string) UserInput {
 	return UserInput{Type: InputTypeLocalImage, Path: path}
 }
 
+// LocalFilePart constructs a user input segment that inlines the file at path.
+func LocalFilePart(path string) UserInput {
+	return UserInput{Type: InputTypeLocalFile, Path: path}
+}
+
+// DirectoryPart constructs a user input segment that inlines the text files
+// below dir matching pattern. An empty pattern includes every file.
+func DirectoryPart(dir, pattern string) UserInput {
+	return UserInput{Type: InputTypeDirectory, Path: dir, Pattern: pattern}
+}
+
+// URLPart constructs a user input segment that fetches url.
+func URLPart(url string) UserInput {
+	return UserInput{Type: InputTypeURL, URL: url}
+}
+
 func normalizeInput(input Input) (string, []string, error) {
 	if len(input.Parts) == 0 {
 		return input.Prompt, nil, nil
@@ -0,0 +1,402 @@
+package codex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Default limits applied to file, directory and URL inputs.
+const (
+	DefaultMaxInputFileBytes  = 256 << 10
+	DefaultMaxInputTotalBytes = 1 << 20
+	DefaultMaxInputFiles      = 200
+)
+
+// InputOptions limit how file, directory and URL inputs are expanded before a
+// turn. Zero values use the defaults.
+type InputOptions struct {
+	// MaxFileBytes caps the size of a single file or download. Defaults to
+	// DefaultMaxInputFileBytes.
+	MaxFileBytes int64
+	// MaxTotalBytes caps the text inlined into the prompt across all parts.
+	// Defaults to DefaultMaxInputTotalBytes.
+	MaxTotalBytes int64
+	// MaxFiles caps the number of files included from directories. Defaults to
+	// DefaultMaxInputFiles.
+	MaxFiles int
+	// HTTPClient fetches URL inputs. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+func (o *InputOptions) withDefaults() InputOptions {
+	var opts InputOptions
+	if o != nil {
+		opts = *o
+	}
+	if opts.MaxFileBytes <= 0 {
+		opts.MaxFileBytes = DefaultMaxInputFileBytes
+	}
+	if opts.MaxTotalBytes <= 0 {
+		opts.MaxTotalBytes = DefaultMaxInputTotalBytes
+	}
+	if opts.MaxFiles <= 0 {
+		opts.MaxFiles = DefaultMaxInputFiles
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	return opts
+}
+
+// imageExtensions are the file extensions passed to the CLI as images.
+var imageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+}
+
+// inputFiles tracks temporary files created while materializing input, and
+// is cleaned up like outputSchemaFile once the turn ends.
+type inputFiles struct {
+	dir string
+}
+
+func (f *inputFiles) Cleanup() error {
+	if f == nil || f.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(f.dir)
+}
+
+// contains reports whether path is one of the temporary files.
+func (f *inputFiles) contains(path string) bool {
+	return f != nil && f.dir != "" && strings.HasPrefix(path, f.dir+string(filepath.Separator))
+}
+
+func (f *inputFiles) create(name string) (*os.File, error) {
+	if f.dir == "" {
+		dir, err := os.MkdirTemp("", "codex-input-")
+		if err != nil {
+			return nil, err
+		}
+		f.dir = dir
+	}
+	return os.CreateTemp(f.dir, "*-"+name)
+}
+
+// materializeInput expands file, directory and URL parts into text and local
+// image parts that normalizeInput understands. Downloaded images are written
+// to temporary files that the caller must clean up.
+func materializeInput(ctx context.Context, input Input, options *InputOptions) (Input, *inputFiles, error) {
+	files := &inputFiles{}
+
+	expandable := false
+	for _, part := range input.Parts {
+		switch part.Type {
+		case InputTypeLocalFile, InputTypeDirectory, InputTypeURL:
+			expandable = true
+		}
+	}
+	if !expandable {
+		return input, files, nil
+	}
+
+	m := &materializer{options: options.withDefaults(), files: files}
+	parts := make([]UserInput, 0, len(input.Parts))
+	for idx, part := range input.Parts {
+		var (
+			expanded []UserInput
+			err      error
+		)
+		switch part.Type {
+		case InputTypeLocalFile:
+			expanded, err = m.localFile(part.Path)
+		case InputTypeDirectory:
+			expanded, err = m.directory(part.Path, part.Pattern)
+		case InputTypeURL:
+			expanded, err = m.url(ctx, part.URL)
+		default:
+			expanded = []UserInput{part}
+		}
+		if err != nil {
+			_ = files.Cleanup()
+			return Input{}, nil, fmt.Errorf("input part %d: %w", idx, err)
+		}
+		parts = append(parts, expanded...)
+	}
+
+	return Input{Prompt: input.Prompt, Parts: parts}, files, nil
+}
+
+type materializer struct {
+	options InputOptions
+	files   *inputFiles
+	total   int64
+}
+
+// inline accounts for text added to the prompt and enforces MaxTotalBytes.
+func (m *materializer) inline(text string) (UserInput, error) {
+	m.total += int64(len(text))
+	if m.total > m.options.MaxTotalBytes {
+		return UserInput{}, fmt.Errorf("inlined input exceeds %d bytes", m.options.MaxTotalBytes)
+	}
+	return TextPart(text), nil
+}
+
+func (m *materializer) localFile(name string) ([]UserInput, error) {
+	if name == "" {
+		return nil, errors.New("local file path must be set")
+	}
+	if imageExtensions[strings.ToLower(filepath.Ext(name))] {
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() > m.options.MaxFileBytes {
+			return nil, fmt.Errorf("%s exceeds %d bytes: %w", name, m.options.MaxFileBytes, errFileTooLarge)
+		}
+		return []UserInput{LocalImagePart(name)}, nil
+	}
+
+	data, err := readLimited(name, m.options.MaxFileBytes)
+	if err != nil {
+		return nil, err
+	}
+	if isBinary(data) {
+		return nil, fmt.Errorf("%s is a binary file", name)
+	}
+
+	part, err := m.inline(fileBlock(name, data))
+	if err != nil {
+		return nil, err
+	}
+	return []UserInput{part}, nil
+}
+
+func (m *materializer) directory(root, pattern string) ([]UserInput, error) {
+	if root == "" {
+		return nil, errors.New("directory path must be set")
+	}
+	if pattern != "" {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	var (
+		b       strings.Builder
+		count   int
+		skipped []string
+	)
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" && name != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		if pattern != "" && !matchPattern(pattern, filepath.ToSlash(rel)) {
+			return nil
+		}
+
+		if count >= m.options.MaxFiles {
+			return fmt.Errorf("directory %s has more than %d matching files", root, m.options.MaxFiles)
+		}
+		count++
+
+		data, err := readLimited(name, m.options.MaxFileBytes)
+		if errors.Is(err, errFileTooLarge) {
+			skipped = append(skipped, skippedBlock(name, "too large"))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if isBinary(data) {
+			skipped = append(skipped, skippedBlock(name, "binary"))
+			return nil
+		}
+
+		b.WriteString(fileBlock(name, data))
+		b.WriteString("\n")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range skipped {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	text := strings.TrimSuffix(b.String(), "\n")
+	if text == "" {
+		return nil, fmt.Errorf("directory %s has no matching files", root)
+	}
+	part, err := m.inline(text)
+	if err != nil {
+		return nil, err
+	}
+	return []UserInput{part}, nil
+}
+
+func (m *materializer) url(ctx context.Context, rawURL string) ([]UserInput, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("invalid URL %q: only http and https are supported", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request for %s: %w", rawURL, err)
+	}
+	resp, err := m.options.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("fetch %s: %s", rawURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, m.options.MaxFileBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", rawURL, err)
+	}
+	if int64(len(data)) > m.options.MaxFileBytes {
+		return nil, fmt.Errorf("%s exceeds %d bytes", rawURL, m.options.MaxFileBytes)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+
+	if strings.HasPrefix(mediaType, "image/") {
+		ext := ".img"
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			ext = exts[0]
+		}
+		f, err := m.files.create("image" + ext)
+		if err != nil {
+			return nil, fmt.Errorf("store image from %s: %w", rawURL, err)
+		}
+		_, writeErr := f.Write(data)
+		closeErr := f.Close()
+		if err := errors.Join(writeErr, closeErr); err != nil {
+			return nil, fmt.Errorf("store image from %s: %w", rawURL, err)
+		}
+		return []UserInput{LocalImagePart(f.Name())}, nil
+	}
+
+	if isBinary(data) {
+		return nil, fmt.Errorf("%s returned binary content of type %q", rawURL, mediaType)
+	}
+
+	part, err := m.inline(delimited("url", fmt.Sprintf(" href=%q", rawURL), string(data)))
+	if err != nil {
+		return nil, err
+	}
+	return []UserInput{part}, nil
+}
+
+var errFileTooLarge = errors.New("file too large")
+
+func readLimited(name string, limit int64) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s exceeds %d bytes: %w", name, limit, errFileTooLarge)
+	}
+	return data, nil
+}
+
+// isBinary reports whether data looks like binary rather than text content.
+// Only the first 8000 bytes are inspected.
+func isBinary(data []byte) bool {
+	sample := data
+	if len(sample) > 8000 {
+		sample = sample[:8000]
+		// Drop a rune cut in half by the end of the sample.
+		for i := 1; i <= utf8.UTFMax && i <= len(sample); i++ {
+			if utf8.RuneStart(sample[len(sample)-i]) {
+				if !utf8.FullRune(sample[len(sample)-i:]) {
+					sample = sample[:len(sample)-i]
+				}
+				break
+			}
+		}
+	}
+	return bytes.IndexByte(sample, 0) >= 0 || !utf8.Valid(sample)
+}
+
+// fileBlock wraps file contents in delimiters that name the file.
+func fileBlock(name string, data []byte) string {
+	return delimited("file", fmt.Sprintf(" path=%q", filepath.ToSlash(name)), string(data))
+}
+
+// delimited wraps contents in an element named tag, with the given
+// attributes. The closing delimiter is </tag>, unless contents contain it:
+// then it is numbered, as in </tag-1>, so that it doesn't appear in contents,
+// and named by an end attribute. Contents can't close the element early and
+// pass for input of their own.
+func delimited(tag, attrs, contents string) string {
+	end := "</" + tag + ">"
+	for i := 1; strings.Contains(contents, end); i++ {
+		end = fmt.Sprintf("</%s-%d>", tag, i)
+	}
+	if end != "</"+tag+">" {
+		attrs += fmt.Sprintf(" end=%q", end)
+	}
+	return fmt.Sprintf("<%s%s>\n%s\n%s", tag, attrs, strings.TrimSuffix(contents, "\n"), end)
+}
+
+func skippedBlock(name, reason string) string {
+	return fmt.Sprintf("<file path=%q skipped=%q/>", filepath.ToSlash(name), reason)
+}
+
+// matchPattern matches a slash separated relative path against a glob. A
+// pattern without a slash matches against the base name at any depth.
+func matchPattern(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	ok, _ := path.Match(pattern, rel)
+	return ok
+}
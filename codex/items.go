@@ -224,3 +224,35 @@ func UnmarshalThreadItem(data []byte) (ThreadItem, error) {
 		return &UnknownThreadItem{Type: discriminator.Type, Raw: json.RawMessage(data)}, nil
 	}
 }
+
+// MarshalThreadItem encodes a thread item as JSON. The type discriminator is
+// filled in from ItemType when the item's Type field is empty, and unknown
+// items are encoded from their raw payload.
+func MarshalThreadItem(item ThreadItem) ([]byte, error) {
+	if unknown, ok := item.(*UnknownThreadItem); ok {
+		if len(unknown.Raw) > 0 {
+			return unknown.Raw, nil
+		}
+		return json.Marshal(map[string]ItemType{"type": unknown.Type})
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if typ, ok := fields["type"]; ok && string(typ) != `""` {
+		return data, nil
+	}
+
+	typ, err := json.Marshal(item.ItemType())
+	if err != nil {
+		return nil, err
+	}
+	fields["type"] = typ
+	return json.Marshal(fields)
+}
@@ -55,3 +55,28 @@ func TestThreadEventUnmarshal(t *testing.T) {
 		t.Fatalf("expected AgentMessageItem, got %T", event.Item)
 	}
 }
+
+func TestThreadEventMarshalRoundTrip(t *testing.T) {
+	exitCode := 0
+	events := []ThreadEvent{
+		{Type: EventTypeThreadStarted, ThreadID: "thread_1"},
+		{Type: EventTypeItemCompleted, Item: &CommandExecutionItem{ID: "cmd_1", Command: "ls", ExitCode: &exitCode, Status: CommandExecutionStatusCompleted}},
+		{Type: EventTypeItemCompleted, Item: &UnknownThreadItem{Type: "new_item", Raw: json.RawMessage(`{"type":"new_item","value":42}`)}},
+		{Type: EventTypeTurnCompleted, Usage: &Usage{InputTokens: 10, OutputTokens: 2}},
+	}
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("json.Marshal returned error: %v", err)
+		}
+
+		var decoded ThreadEvent
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("json.Unmarshal returned error for %s: %v", data, err)
+		}
+		if decoded.String() != event.String() {
+			t.Fatalf("expected %q after round trip, got %q", event.String(), decoded.String())
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package codex
+
+import (
+	"context"
+	"regexp"
+)
+
+// Middleware observes or transforms the events of a turn before they reach
+// the caller. It returns the event to pass on, which may be modified, and
+// whether to keep it; dropped events are not recorded or delivered. A non-nil
+// error aborts the turn: the CLI is stopped and the error is returned from
+// Run or StreamedTurn.Wait.
+//
+// Middleware runs for Run and RunStreamed alike, after approval review and
+// usage accounting, which always see the events emitted by the CLI.
+type Middleware func(ctx context.Context, event ThreadEvent) (ThreadEvent, bool, error)
+
+// Hooks are typed callbacks for the events of a turn. Each hook receives the
+// type of the event, such as EventTypeItemStarted or EventTypeItemCompleted,
+// together with its item. Hooks run after ThreadOptions.Middleware, so they
+// observe transformed events.
+type Hooks struct {
+	// OnCommand is called for command execution items.
+	OnCommand func(ctx context.Context, event EventType, item *CommandExecutionItem)
+	// OnFileChange is called for file change items.
+	OnFileChange func(ctx context.Context, event EventType, item *FileChangeItem)
+	// OnReasoning is called for reasoning items.
+	OnReasoning func(ctx context.Context, event EventType, item *ReasoningItem)
+	// OnError is called when the turn fails or the CLI reports an error.
+	OnError func(ctx context.Context, err ThreadError)
+}
+
+// Middleware returns the hooks as a Middleware that keeps every event.
+func (h Hooks) Middleware() Middleware {
+	return func(ctx context.Context, event ThreadEvent) (ThreadEvent, bool, error) {
+		switch item := event.Item.(type) {
+		case *CommandExecutionItem:
+			if h.OnCommand != nil {
+				h.OnCommand(ctx, event.Type, item)
+			}
+		case *FileChangeItem:
+			if h.OnFileChange != nil {
+				h.OnFileChange(ctx, event.Type, item)
+			}
+		case *ReasoningItem:
+			if h.OnReasoning != nil {
+				h.OnReasoning(ctx, event.Type, item)
+			}
+		}
+
+		if h.OnError != nil {
+			switch event.Type {
+			case EventTypeTurnFailed:
+				err := ThreadError{Message: "turn failed"}
+				if event.Error != nil {
+					err = *event.Error
+				}
+				h.OnError(ctx, err)
+			case EventTypeError:
+				h.OnError(ctx, ThreadError{Message: event.Message})
+			}
+		}
+		return event, true, nil
+	}
+}
+
+func (h Hooks) empty() bool {
+	return h.OnCommand == nil && h.OnFileChange == nil && h.OnReasoning == nil && h.OnError == nil
+}
+
+// RedactCommandOutput returns a Middleware that replaces every match of the
+// patterns in the output of command execution items with replacement.
+func RedactCommandOutput(replacement string, patterns ...*regexp.Regexp) Middleware {
+	return func(ctx context.Context, event ThreadEvent) (ThreadEvent, bool, error) {
+		if item, ok := event.Item.(*CommandExecutionItem); ok {
+			redacted := *item
+			for _, pattern := range patterns {
+				redacted.AggregatedOutput = pattern.ReplaceAllString(redacted.AggregatedOutput, replacement)
+			}
+			event.Item = &redacted
+		}
+		return event, true, nil
+	}
+}
+
+// middlewareChain combines the thread's middleware and hooks.
+func middlewareChain(options ThreadOptions) []Middleware {
+	chain := options.Middleware
+	if !options.Hooks.empty() {
+		chain = append(chain[:len(chain):len(chain)], options.Hooks.Middleware())
+	}
+	return chain
+}
+
+// applyMiddleware runs event through chain, stopping at the first middleware
+// that drops it or fails.
+func applyMiddleware(ctx context.Context, chain []Middleware, event ThreadEvent) (ThreadEvent, bool, error) {
+	for _, middleware := range chain {
+		var (
+			keep bool
+			err  error
+		)
+		event, keep, err = middleware(ctx, event)
+		if err != nil || !keep {
+			return event, false, err
+		}
+	}
+	return event, true, nil
+}
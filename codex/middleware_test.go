@@ -0,0 +1,136 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var middlewareTranscript = []string{
+	`{"type":"thread.started","thread_id":"thread_1"}`,
+	`{"type":"item.completed","item":{"id":"rs_1","type":"reasoning","text":"checking the environment"}}`,
+	`{"type":"item.started","item":{"id":"cmd_1","type":"command_execution","command":"env","aggregated_output":"","status":"in_progress"}}`,
+	`{"type":"item.completed","item":{"id":"cmd_1","type":"command_execution","command":"env","aggregated_output":"API_TOKEN=sk-secret123\nHOME=/root","exit_code":0,"status":"completed"}}`,
+	`{"type":"item.completed","item":{"id":"fc_1","type":"file_change","changes":[{"path":"main.go","kind":"update"}],"status":"completed"}}`,
+	`{"type":"item.completed","item":{"id":"cmd_2","type":"command_execution","command":"rm -rf /","aggregated_output":"","status":"in_progress"}}`,
+	`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+}
+
+func TestMiddlewareAndHooks(t *testing.T) {
+	transcript := append(middlewareTranscript[:5:5], middlewareTranscript[6])
+	client, err := New(Options{Executor: NewReaderExecutor(strings.NewReader(strings.Join(transcript, "\n")))})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	var calls []string
+	thread := client.StartThread(ThreadOptions{
+		Middleware: []Middleware{
+			RedactCommandOutput("[REDACTED]", regexp.MustCompile(`sk-[A-Za-z0-9]+`)),
+			func(ctx context.Context, event ThreadEvent) (ThreadEvent, bool, error) {
+				// Drop reasoning from the results.
+				_, reasoning := event.Item.(*ReasoningItem)
+				return event, !reasoning, nil
+			},
+		},
+		Hooks: Hooks{
+			OnCommand: func(ctx context.Context, event EventType, item *CommandExecutionItem) {
+				calls = append(calls, string(event)+" "+item.Command+" "+item.AggregatedOutput)
+			},
+			OnFileChange: func(ctx context.Context, event EventType, item *FileChangeItem) {
+				calls = append(calls, string(event)+" "+item.Changes[0].Path)
+			},
+			OnReasoning: func(ctx context.Context, event EventType, item *ReasoningItem) {
+				calls = append(calls, "reasoning")
+			},
+		},
+	})
+
+	turn, err := thread.RunText(t.Context(), "inspect", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	want := []string{
+		"item.started env ",
+		"item.completed env API_TOKEN=[REDACTED]\nHOME=/root",
+		"item.completed main.go",
+	}
+	if strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected hook calls: %q", calls)
+	}
+	if len(turn.Items) != 2 {
+		t.Fatalf("expected reasoning to be dropped, got %d items", len(turn.Items))
+	}
+	if cmd := turn.Items[0].(*CommandExecutionItem); strings.Contains(cmd.AggregatedOutput, "sk-secret123") {
+		t.Fatalf("expected the secret to be redacted, got %q", cmd.AggregatedOutput)
+	}
+}
+
+func TestMiddlewareAbortsStreamedTurn(t *testing.T) {
+	errForbidden := errors.New("forbidden command")
+
+	client, err := New(Options{Executor: NewReaderExecutor(strings.NewReader(strings.Join(middlewareTranscript, "\n")))})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	var failures []string
+	thread := client.StartThread(ThreadOptions{
+		Middleware: []Middleware{
+			func(ctx context.Context, event ThreadEvent) (ThreadEvent, bool, error) {
+				if cmd, ok := event.Item.(*CommandExecutionItem); ok && strings.HasPrefix(cmd.Command, "rm ") {
+					return event, false, errForbidden
+				}
+				return event, true, nil
+			},
+		},
+		Hooks: Hooks{
+			OnError: func(ctx context.Context, err ThreadError) { failures = append(failures, err.Message) },
+		},
+	})
+
+	streamed, err := thread.RunStreamedText(t.Context(), "clean up", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	var types []string
+	for event := range streamed.Events {
+		types = append(types, string(event.Type))
+	}
+
+	if err := streamed.Wait(); !errors.Is(err, errForbidden) {
+		t.Fatalf("expected the middleware error, got %v", err)
+	}
+	if len(types) != 5 || types[len(types)-1] != string(EventTypeItemCompleted) {
+		t.Fatalf("expected the stream to stop before the forbidden command, got %v", types)
+	}
+	if len(failures) != 0 {
+		t.Fatalf("unexpected OnError calls: %v", failures)
+	}
+}
+
+func TestHooksOnError(t *testing.T) {
+	client, err := New(Options{Executor: NewReaderExecutor(strings.NewReader(strings.Join([]string{
+		`{"type":"thread.started","thread_id":"thread_1"}`,
+		`{"type":"error","message":"reconnecting"}`,
+		`{"type":"turn.failed","error":{"message":"model overloaded"}}`,
+	}, "\n")))})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	var failures []string
+	thread := client.StartThread(ThreadOptions{Hooks: Hooks{
+		OnError: func(ctx context.Context, err ThreadError) { failures = append(failures, err.Message) },
+	}})
+
+	if _, err := thread.RunText(t.Context(), "hi", nil); err == nil {
+		t.Fatal("expected the turn to fail")
+	}
+	if strings.Join(failures, "|") != "reconnecting|model overloaded" {
+		t.Fatalf("unexpected OnError calls: %v", failures)
+	}
+}
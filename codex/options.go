@@ -1,5 +1,7 @@
 package codex
 
+import "context"
+
 // Options configure a Codex client.
 type Options struct {
 	// CodexPathOverride points to a specific codex binary. When empty the SDK searches
@@ -11,6 +13,16 @@ type Options struct {
 	// APIKey overrides the API key used by the codex CLI. When empty, the CLI falls
 	// back to the CODEX_API_KEY environment variable.
 	APIKey string
+	// Executor runs the CLI for every turn. When nil, a local Exec is created
+	// using CodexPathOverride.
+	Executor Executor
+	// ThreadStore, when set, records every turn run by the client's threads so
+	// they can be listed, searched and resumed later.
+	ThreadStore *ThreadStore
+	// ThreadStoreErrorHandler, when set, is called when a turn can't be
+	// recorded in ThreadStore. Recording is best-effort: the error never
+	// changes the result of the turn, and is dropped when this is nil.
+	ThreadStoreErrorHandler func(ctx context.Context, threadID string, err error)
 }
 
 // ApprovalMode mirrors the codex CLI approval modes.
@@ -38,15 +50,67 @@ type ThreadOptions struct {
 	Model string
 	// SandboxMode controls the filesystem sandbox granted to the agent.
 	SandboxMode SandboxMode
+	// ApprovalMode sets the CLI approval policy for commands proposed by the agent.
+	ApprovalMode ApprovalMode
+	// ApprovalHandler reviews each command execution and file change reported by
+	// the agent when ApprovalMode is ApprovalModeOnRequest or ApprovalModeUntrusted.
+	// See ApprovalHandler for the guarantees it provides.
+	ApprovalHandler ApprovalHandler
 	// WorkingDirectory sets the directory provided to --cd when launching the CLI.
 	WorkingDirectory string
 	// SkipGitRepoCheck mirrors --skip-git-repo-check on the CLI.
 	SkipGitRepoCheck bool
+	// FullAuto mirrors --full-auto on the CLI, a low-friction preset for
+	// sandboxed automatic execution.
+	FullAuto bool
+	// IncludePlanTool mirrors --include-plan-tool on the CLI so the agent reports
+	// its plan as TodoListItem updates.
+	IncludePlanTool bool
+	// Features enables or disables optional CLI features for every turn.
+	Features Features
+	// ConfigOverrides are applied to every turn as --config key=value pairs.
+	ConfigOverrides ConfigOverrides
+	// ConfigFile is passed to the CLI's --config flag for every turn.
+	ConfigFile string
+	// UsageLedger accumulates the thread's token usage and enforces its budget.
+	// Share a ledger between threads to account for them together. When nil,
+	// the thread gets its own ledger using DefaultPriceTable and no budget.
+	UsageLedger *UsageLedger
+	// RetryPolicy, when set, retries turns run with Thread.Run that fail
+	// transiently by resuming the thread.
+	RetryPolicy *RetryPolicy
+	// Middleware observes, transforms or drops the events of every turn, in
+	// order. See Middleware for where it runs.
+	Middleware []Middleware
+	// Hooks are typed callbacks invoked for the events of every turn after
+	// Middleware.
+	Hooks Hooks
+	// CaptureChanges attaches the changes each turn run with Thread.Run made to
+	// the git repository containing WorkingDirectory to Turn.Changes.
+	CaptureChanges bool
 }
 
 // TurnOptions configure a single turn when running the agent.
 type TurnOptions struct {
 	// OutputSchema describes the expected JSON structure when requesting structured output.
-	// The value must marshal to a JSON object; validation occurs before each run.
+	// The value must marshal to a JSON object accepted by LintSchema, which is
+	// checked before each run.
 	OutputSchema any
+	// ValidateOutput makes Thread.Run validate the final response against
+	// OutputSchema, failing with an *OutputValidationError when it does not
+	// conform.
+	ValidateOutput bool
+	// RepairAttempts is the number of follow-up turns Thread.Run sends asking
+	// the agent to fix a final response that does not conform to OutputSchema.
+	// A positive value implies ValidateOutput.
+	RepairAttempts int
+	// ConfigOverrides are applied to this turn only, taking precedence over
+	// ThreadOptions.ConfigOverrides with the same key.
+	ConfigOverrides ConfigOverrides
+	// OutputLastMessage mirrors --output-last-message on the CLI, writing the
+	// agent's final message to the given file.
+	OutputLastMessage string
+	// InputOptions limit how file, directory and URL input parts are expanded.
+	// When nil, the defaults described on InputOptions apply.
+	InputOptions *InputOptions
 }
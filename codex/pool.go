@@ -0,0 +1,325 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultPoolConcurrency is the number of tasks a Pool runs at once when
+// PoolOptions.Concurrency is not set.
+const DefaultPoolConcurrency = 4
+
+// PoolTask is a unit of work run by a Pool on its own thread.
+type PoolTask struct {
+	// Name identifies the task in events and results, and names its worktree
+	// and branch. Names must be unique within a run. Defaults to "task-<index>".
+	Name string
+	// Input is the prompt sent to the task's thread.
+	Input Input
+	// ThreadOptions configure the task's thread. When the pool creates
+	// worktrees, WorkingDirectory is replaced by the task's worktree.
+	ThreadOptions ThreadOptions
+	// TurnOptions configure the task's turn.
+	TurnOptions *TurnOptions
+	// ThreadID, when set, resumes an existing thread instead of starting one.
+	ThreadID string
+}
+
+// WorktreeOptions make a Pool run every task in its own git worktree, so
+// agents working on the same repository do not interfere with each other or
+// with the main checkout.
+type WorktreeOptions struct {
+	// Repository is the repository worktrees are created from. When empty, each
+	// task's ThreadOptions.WorkingDirectory is used, which allows running the
+	// same prompt across many repositories.
+	Repository string
+	// Ref is the commit worktrees are checked out at. Defaults to HEAD.
+	Ref string
+	// BranchPrefix, when set, creates a branch named BranchPrefix plus the task
+	// name in every worktree. Otherwise worktrees use a detached HEAD.
+	BranchPrefix string
+	// Dir is the directory worktrees are created in. Defaults to a new
+	// temporary directory for every run.
+	Dir string
+	// Cleanup removes the worktrees once their task finishes, and the
+	// temporary directory created when Dir is empty once every task finished.
+	// Worktrees are kept by default so the agents' changes can be reviewed.
+	Cleanup bool
+}
+
+// PoolOptions configure a Pool.
+type PoolOptions struct {
+	// Concurrency caps the number of tasks running at once. Defaults to
+	// DefaultPoolConcurrency.
+	Concurrency int
+	// Worktree, when set, runs every task in its own git worktree.
+	Worktree *WorktreeOptions
+}
+
+// PoolEvent is a thread event tagged with the task that produced it.
+type PoolEvent struct {
+	// Task is the name of the task.
+	Task string
+	// Index is the position of the task in the slice passed to the pool.
+	Index int
+	// Event is the event emitted by the task's thread.
+	Event ThreadEvent
+}
+
+// PoolResult is the outcome of a single task.
+type PoolResult struct {
+	// Task is the name of the task.
+	Task string
+	// Index is the position of the task in the slice passed to the pool.
+	Index int
+	// ThreadID is the ID of the task's thread, when one was assigned.
+	ThreadID string
+	// WorkingDirectory is the directory the task ran in, such as its worktree.
+	// With WorktreeOptions.Cleanup the worktree no longer exists once the
+	// result is returned.
+	WorkingDirectory string
+	// Turn is the result of the task's turn. When the task failed it holds the
+	// items completed before the failure.
+	Turn Turn
+	// Err is the error that ended the task, if any.
+	Err error
+}
+
+// Pool runs tasks on separate threads in parallel, with a concurrency limit.
+type Pool struct {
+	client  *Client
+	options PoolOptions
+
+	// gitMu serializes worktree changes, which all lock the same repository.
+	gitMu sync.Mutex
+}
+
+// NewPool creates a Pool that starts threads with client.
+func NewPool(client *Client, options PoolOptions) *Pool {
+	return &Pool{client: client, options: options}
+}
+
+// PoolStream streams the events of a running pool.
+type PoolStream struct {
+	// Events yields the events of every task as they are produced. Callers
+	// must drain it before calling Wait.
+	Events <-chan PoolEvent
+
+	done    <-chan struct{}
+	results []PoolResult
+}
+
+// Wait blocks until every task finished and returns their results in task
+// order. The error joins the errors of the failed tasks; results are returned
+// for every task even when some of them failed.
+func (s *PoolStream) Wait() ([]PoolResult, error) {
+	<-s.done
+
+	var errs []error
+	for _, result := range s.results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("task %s: %w", result.Task, result.Err))
+		}
+	}
+	return s.results, errors.Join(errs...)
+}
+
+// Run runs tasks and returns their results once all of them finished. See
+// PoolStream.Wait for how failures are reported.
+func (p *Pool) Run(ctx context.Context, tasks []PoolTask) ([]PoolResult, error) {
+	stream, err := p.RunStreamed(ctx, tasks)
+	if err != nil {
+		return nil, err
+	}
+	for range stream.Events {
+	}
+	return stream.Wait()
+}
+
+// RunStreamed starts tasks and streams their events. Callers should drain
+// Events and then invoke Wait to collect the results.
+func (p *Pool) RunStreamed(ctx context.Context, tasks []PoolTask) (*PoolStream, error) {
+	names, err := poolTaskNames(tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		worktreeDir   string
+		removeTempDir bool
+	)
+	if p.options.Worktree != nil {
+		worktreeDir = p.options.Worktree.Dir
+		if worktreeDir == "" {
+			worktreeDir, err = os.MkdirTemp("", "codex-pool-")
+			if err != nil {
+				return nil, fmt.Errorf("create worktree directory: %w", err)
+			}
+			removeTempDir = p.options.Worktree.Cleanup
+		}
+	}
+
+	concurrency := p.options.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultPoolConcurrency
+	}
+
+	events := make(chan PoolEvent)
+	done := make(chan struct{})
+	stream := &PoolStream{Events: events, done: done, results: make([]PoolResult, len(tasks))}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for idx, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				stream.results[idx] = PoolResult{Task: names[idx], Index: idx, Err: ctx.Err()}
+				return
+			}
+			defer func() { <-sem }()
+
+			stream.results[idx] = p.runTask(ctx, idx, names[idx], task, worktreeDir, events)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		if removeTempDir {
+			// Only remove the directory when it is empty, i.e. every
+			// worktree in it was removed.
+			_ = os.Remove(worktreeDir)
+		}
+		close(events)
+		close(done)
+	}()
+
+	return stream, nil
+}
+
+func (p *Pool) runTask(ctx context.Context, idx int, name string, task PoolTask, worktreeDir string, events chan<- PoolEvent) PoolResult {
+	result := PoolResult{Task: name, Index: idx}
+
+	options := task.ThreadOptions
+	if worktree := p.options.Worktree; worktree != nil {
+		dir, err := p.addWorktree(ctx, worktree, worktreeDir, name, options.WorkingDirectory)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		if worktree.Cleanup {
+			defer p.removeWorktree(dir)
+		}
+		options.WorkingDirectory = dir
+	}
+	result.WorkingDirectory = options.WorkingDirectory
+
+	var thread *Thread
+	if task.ThreadID != "" {
+		thread = p.client.ResumeThread(task.ThreadID, options)
+	} else {
+		thread = p.client.StartThread(options)
+	}
+
+	var items []ThreadItem
+	thread.observe = func(event ThreadEvent) {
+		if event.Type == EventTypeItemCompleted && event.Item != nil {
+			items = append(items, event.Item)
+		}
+		select {
+		case events <- PoolEvent{Task: name, Index: idx, Event: event}:
+		case <-ctx.Done():
+		}
+	}
+
+	turn, err := thread.Run(ctx, task.Input, task.TurnOptions)
+	result.ThreadID = thread.ID()
+	if err != nil {
+		turn = Turn{Items: items}
+		result.Err = err
+	}
+	result.Turn = turn
+	return result
+}
+
+func (p *Pool) addWorktree(ctx context.Context, options *WorktreeOptions, parent, name, workingDirectory string) (string, error) {
+	repo := options.Repository
+	if repo == "" {
+		repo = workingDirectory
+	}
+	if repo == "" {
+		return "", errors.New("create worktree: repository or working directory must be set")
+	}
+	ref := options.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	dir, err := filepath.Abs(filepath.Join(parent, name))
+	if err != nil {
+		return "", fmt.Errorf("create worktree: %w", err)
+	}
+
+	args := []string{"-C", repo, "worktree", "add"}
+	if options.BranchPrefix != "" {
+		args = append(args, "-b", options.BranchPrefix+name)
+	} else {
+		args = append(args, "--detach")
+	}
+	args = append(args, dir, ref)
+
+	p.gitMu.Lock()
+	defer p.gitMu.Unlock()
+	if err := runGit(ctx, args...); err != nil {
+		return "", fmt.Errorf("create worktree: %w", err)
+	}
+	return dir, nil
+}
+
+func (p *Pool) removeWorktree(dir string) {
+	p.gitMu.Lock()
+	defer p.gitMu.Unlock()
+	// The task's context may already be cancelled, so removal uses its own.
+	_ = runGit(context.Background(), "-C", dir, "worktree", "remove", "--force", dir)
+}
+
+func runGit(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// poolTaskNames returns the name of every task, defaulting empty names and
+// rejecting duplicates and names that are unsafe for paths and branches.
+func poolTaskNames(tasks []PoolTask) ([]string, error) {
+	names := make([]string, len(tasks))
+	seen := make(map[string]bool, len(tasks))
+	for idx, task := range tasks {
+		name := task.Name
+		if name == "" {
+			name = fmt.Sprintf("task-%d", idx)
+		}
+		if name == "." || name == ".." || strings.ContainsAny(name, `/\: `) || strings.HasPrefix(name, "-") {
+			return nil, fmt.Errorf("task %d: invalid name %q", idx, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("task %d: duplicate name %q", idx, name)
+		}
+		seen[name] = true
+		names[idx] = name
+	}
+	return names, nil
+}
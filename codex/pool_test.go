@@ -0,0 +1,191 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// trackedReader reports when the CLI output of a run has been fully read.
+type trackedReader struct {
+	io.Reader
+	done func()
+	once sync.Once
+}
+
+func (r *trackedReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		r.once.Do(r.done)
+	}
+	return n, err
+}
+
+func TestPoolRun(t *testing.T) {
+	var active, peak atomic.Int32
+	executor := ReaderExecutor(func(ctx context.Context, args Args) (io.Reader, error) {
+		if n := active.Add(1); n > peak.Load() {
+			peak.Store(n)
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		lines := []string{`{"type":"thread.started","thread_id":"thread_` + args.Input + `"}`}
+		if args.Input == "broken" {
+			lines = append(lines,
+				`{"type":"item.completed","item":{"id":"cmd_1","type":"command_execution","command":"make","aggregated_output":"","status":"failed"}}`,
+				`{"type":"turn.failed","error":{"message":"build exploded"}}`,
+			)
+		} else {
+			lines = append(lines,
+				`{"type":"item.completed","item":{"id":"msg_1","type":"agent_message","text":"done `+args.Input+`"}}`,
+				`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+			)
+		}
+		return &trackedReader{Reader: strings.NewReader(strings.Join(lines, "\n")), done: func() { active.Add(-1) }}, nil
+	})
+
+	client, err := New(Options{Executor: executor})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	tasks := []PoolTask{
+		{Name: "alpha", Input: TextInput("alpha")},
+		{Name: "beta", Input: TextInput("beta")},
+		{Name: "broken", Input: TextInput("broken")},
+		{Input: TextInput("delta")},
+	}
+	stream, err := NewPool(client, PoolOptions{Concurrency: 2}).RunStreamed(t.Context(), tasks)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+
+	started := make(map[string]string)
+	for event := range stream.Events {
+		if event.Event.Type == EventTypeThreadStarted {
+			started[event.Task] = event.Event.ThreadID
+		}
+	}
+	results, err := stream.Wait()
+
+	var turnErr *TurnFailedError
+	if !errors.As(err, &turnErr) || !strings.Contains(err.Error(), "task broken: build exploded") {
+		t.Fatalf("expected the failed task to be reported, got %v", err)
+	}
+	if peak.Load() > 2 {
+		t.Fatalf("expected at most 2 concurrent runs, got %d", peak.Load())
+	}
+	if len(started) != 4 || started["task-3"] != "thread_delta" {
+		t.Fatalf("expected events tagged by task, got %v", started)
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	for idx, name := range []string{"alpha", "beta"} {
+		result := results[idx]
+		if result.Task != name || result.Err != nil || result.Turn.FinalResponse != "done "+name || result.ThreadID != "thread_"+name {
+			t.Fatalf("unexpected result for %s: %+v", name, result)
+		}
+	}
+	if broken := results[2]; broken.Err == nil || len(broken.Turn.Items) != 1 || broken.ThreadID != "thread_broken" {
+		t.Fatalf("expected partial result for the failed task, got %+v", broken)
+	}
+}
+
+func TestPoolRejectsDuplicateNames(t *testing.T) {
+	client, err := New(Options{Executor: NewReaderExecutor(strings.NewReader(""))})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	_, err = NewPool(client, PoolOptions{}).Run(t.Context(), []PoolTask{{Name: "a"}, {Name: "a"}})
+	if err == nil || !strings.Contains(err.Error(), "duplicate name") {
+		t.Fatalf("expected duplicate name error, got %v", err)
+	}
+}
+
+func TestPoolWorktrees(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+		return string(output)
+	}
+	git("init", "-q")
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "README.md")
+	git("commit", "-q", "-m", "initial")
+
+	var (
+		mu   sync.Mutex
+		dirs []string
+	)
+	executor := ReaderExecutor(func(ctx context.Context, args Args) (io.Reader, error) {
+		mu.Lock()
+		dirs = append(dirs, args.WorkingDirectory)
+		mu.Unlock()
+		if _, err := os.Stat(filepath.Join(args.WorkingDirectory, "README.md")); err != nil {
+			return nil, err
+		}
+		return strings.NewReader(`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`), nil
+	})
+	client, err := New(Options{Executor: executor})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	worktrees := t.TempDir()
+	pool := NewPool(client, PoolOptions{Worktree: &WorktreeOptions{
+		Repository:   repo,
+		BranchPrefix: "agent/",
+		Dir:          worktrees,
+		Cleanup:      true,
+	}})
+	results, err := pool.Run(t.Context(), []PoolTask{{Name: "one"}, {Name: "two"}})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	for _, result := range results {
+		if filepath.Dir(result.WorkingDirectory) != worktrees {
+			t.Fatalf("expected task %s to run in a worktree, got %q", result.Task, result.WorkingDirectory)
+		}
+		if _, err := os.Stat(result.WorkingDirectory); !os.IsNotExist(err) {
+			t.Fatalf("expected worktree %s to be removed, got err=%v", result.WorkingDirectory, err)
+		}
+	}
+	if len(dirs) != 2 || dirs[0] == dirs[1] {
+		t.Fatalf("expected each task to get its own worktree, got %v", dirs)
+	}
+	if branches := git("branch", "--list", "agent/*"); !strings.Contains(branches, "agent/one") || !strings.Contains(branches, "agent/two") {
+		t.Fatalf("expected task branches, got %q", branches)
+	}
+
+	// The temporary directory created for the worktrees is removed as well.
+	pool = NewPool(client, PoolOptions{Worktree: &WorktreeOptions{Repository: repo, Cleanup: true}})
+	results, err = pool.Run(t.Context(), []PoolTask{{Name: "three"}})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(results[0].WorkingDirectory)); !os.IsNotExist(err) {
+		t.Fatalf("expected worktree directory to be removed, got err=%v", err)
+	}
+}
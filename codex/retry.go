@@ -0,0 +1,203 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// DefaultContinuationPrompt is sent when a failed turn is retried on a thread
+// that already exists. %s is replaced with the failure message.
+const DefaultContinuationPrompt = "The previous turn was interrupted by an error (%s). Continue the task from where you left off."
+
+// RetryPolicy retries turns that fail transiently, such as on rate limits,
+// network errors or the CLI exiting mid-stream. Once the thread ID is known
+// the retry resumes the thread with a continuation prompt, so the agent keeps
+// the context of the interrupted attempt; otherwise the original input is
+// sent again. Retries apply to Thread.Run and the helpers built on it.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to one second.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. Defaults to 30 seconds.
+	MaxBackoff time.Duration
+	// Multiplier scales the delay after every attempt. Defaults to 2.
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction in either direction,
+	// e.g. 0.2 for ±20%. Zero disables jitter.
+	Jitter float64
+	// Retryable decides whether a failed attempt is retried. Defaults to
+	// IsRetryable.
+	Retryable func(err error) bool
+	// ContinuationPrompt is the prompt sent when resuming a thread after a
+	// failure. Every %s in it is replaced with the failure message; it is not
+	// otherwise a format string. Defaults to DefaultContinuationPrompt.
+	ContinuationPrompt string
+	// OnRetry, when set, is called before waiting for each retry.
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// Backoff returns the delay before the given retry, where 1 is the first
+// retry, including jitter.
+func (p *RetryPolicy) Backoff(retry int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = time.Second
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	delay := float64(initial)
+	for range retry - 1 {
+		delay *= multiplier
+		if delay >= float64(maxBackoff) {
+			break
+		}
+	}
+	delay = min(delay, float64(maxBackoff))
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(max(delay, 0))
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+func (p *RetryPolicy) continuation(err error) string {
+	prompt := p.ContinuationPrompt
+	if prompt == "" {
+		prompt = DefaultContinuationPrompt
+	}
+	return strings.ReplaceAll(prompt, "%s", err.Error())
+}
+
+// transientFailureMarkers are lowercase fragments of turn failure messages,
+// and of the CLI's standard error, that indicate a temporary problem.
+var transientFailureMarkers = []string{
+	"429",
+	"rate limit",
+	"too many requests",
+	"overloaded",
+	"timeout",
+	"timed out",
+	"temporarily unavailable",
+	"internal server error",
+	"bad gateway",
+	"service unavailable",
+	"gateway timeout",
+	"connection reset",
+	"connection closed",
+	"connection refused by upstream",
+	"broken pipe",
+	"network error",
+	"stream disconnected",
+	"stream error",
+}
+
+// IsRetryable reports whether err is worth retrying: turn failures that look
+// transient, the CLI emitting a truncated event, being killed by a signal, or
+// exiting with a transient failure on its standard error. Other exits, such as
+// for invalid flags or configuration, are deterministic and not retried.
+// Cancellation, authentication failures, budget and approval errors, schema
+// violations and a missing binary are never retried either.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var (
+		approvalErr   *ApprovalDeniedError
+		validationErr *OutputValidationError
+	)
+	switch {
+	case errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, ErrAuth),
+		errors.Is(err, ErrBudgetExceeded),
+		errors.Is(err, ErrCodexNotFound),
+		errors.As(err, &approvalErr),
+		errors.As(err, &validationErr):
+		return false
+	}
+
+	var turnErr *TurnFailedError
+	if errors.As(err, &turnErr) {
+		return isTransientFailure(turnErr.ThreadError.Message)
+	}
+
+	var execErr *ExecError
+	if errors.As(err, &execErr) {
+		return execErr.ExitCode == -1 || isTransientFailure(execErr.Stderr)
+	}
+
+	var protocolErr *ProtocolError
+	return errors.As(err, &protocolErr)
+}
+
+func isTransientFailure(message string) bool {
+	message = strings.ToLower(message)
+	for _, marker := range transientFailureMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Thread) runWithRetry(ctx context.Context, input Input, turnOptions *TurnOptions, policy *RetryPolicy) (Turn, error) {
+	var merged Turn
+
+	attemptInput := input
+	for attempt := 1; ; attempt++ {
+		turn, err := t.runOnce(ctx, attemptInput, turnOptions)
+		mergeTurn(&merged, turn)
+
+		if err == nil {
+			merged.FinalResponse = turn.FinalResponse
+			return merged, nil
+		}
+		if attempt >= policy.MaxAttempts || !policy.retryable(err) {
+			var turnErr *TurnFailedError
+			if errors.As(err, &turnErr) {
+				turnErr.Items = merged.Items
+			}
+			merged.FinalResponse = turn.FinalResponse
+			return merged, err
+		}
+
+		delay := policy.Backoff(attempt)
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Turn{}, ctx.Err()
+		case <-timer.C:
+		}
+
+		// Once the CLI assigned an ID the thread is resumed, and the agent is
+		// asked to continue rather than start over.
+		if t.ID() != "" {
+			attemptInput = TextInput(policy.continuation(err))
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedExecutor serves one transcript per run and records the arguments
+// of every run.
+func scriptedExecutor(runs *[]Args, transcripts ...[]string) ReaderExecutor {
+	return func(ctx context.Context, args Args) (io.Reader, error) {
+		*runs = append(*runs, args)
+		if len(*runs) > len(transcripts) {
+			return nil, fmt.Errorf("unexpected run %d", len(*runs))
+		}
+		return strings.NewReader(strings.Join(transcripts[len(*runs)-1], "\n")), nil
+	}
+}
+
+func TestRunRetriesTransientFailures(t *testing.T) {
+	var runs []Args
+	executor := scriptedExecutor(&runs,
+		[]string{
+			`{"type":"thread.started","thread_id":"thread_1"}`,
+			`{"type":"item.completed","item":{"id":"cmd_1","type":"command_execution","command":"go test ./...","aggregated_output":"","status":"completed"}}`,
+			`{"type":"turn.failed","error":{"message":"429 Too Many Requests"}}`,
+		},
+		[]string{
+			`{"type":"thread.started","thread_id":"thread_1"}`,
+			`{"type":"item.completed","item":{"id":"msg_1","type":"agent_message","text":"all green"}}`,
+			`{"type":"turn.completed","usage":{"input_tokens":5,"cached_input_tokens":0,"output_tokens":1}}`,
+		},
+	)
+
+	var retries []int
+	client, err := New(Options{Executor: executor})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	thread := client.StartThread(ThreadOptions{RetryPolicy: &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		OnRetry:        func(attempt int, err error, delay time.Duration) { retries = append(retries, attempt) },
+	}})
+
+	turn, err := thread.RunText(t.Context(), "fix the tests", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if len(turn.Items) != 2 || turn.FinalResponse != "all green" || turn.Usage == nil || turn.Usage.InputTokens != 5 {
+		t.Fatalf("unexpected merged turn: %+v", turn)
+	}
+	if len(runs) != 2 || len(retries) != 1 {
+		t.Fatalf("expected a single retry, got %d runs", len(runs))
+	}
+	if runs[1].ThreadID != "thread_1" || !strings.Contains(runs[1].Input, "429 Too Many Requests") {
+		t.Fatalf("expected retry to resume the thread with a continuation prompt, got %+v", runs[1])
+	}
+}
+
+func TestRunDoesNotRetryPermanentFailures(t *testing.T) {
+	var runs []Args
+	executor := scriptedExecutor(&runs, []string{
+		`{"type":"thread.started","thread_id":"thread_1"}`,
+		`{"type":"item.completed","item":{"id":"msg_1","type":"agent_message","text":"partial"}}`,
+		`{"type":"turn.failed","error":{"message":"401 Unauthorized"}}`,
+	})
+
+	client, err := New(Options{Executor: executor})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	thread := client.StartThread(ThreadOptions{RetryPolicy: &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}})
+
+	_, err = thread.RunText(t.Context(), "hi", nil)
+	var turnErr *TurnFailedError
+	if !errors.As(err, &turnErr) || !errors.Is(err, ErrAuth) {
+		t.Fatalf("expected auth turn failure, got %v", err)
+	}
+	if len(turnErr.Items) != 1 {
+		t.Fatalf("expected partial items on the error, got %d", len(turnErr.Items))
+	}
+	if len(runs) != 1 {
+		t.Fatalf("expected no retries, got %d runs", len(runs))
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 3}
+
+	for retry, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 300 * time.Millisecond,
+		3: 900 * time.Millisecond,
+		4: time.Second,
+	} {
+		if got := policy.Backoff(retry); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", retry, got, want)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for range 100 {
+		if got := policy.Backoff(1); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("jittered backoff %v out of range", got)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{&TurnFailedError{ThreadError: ThreadError{Message: "stream disconnected before completion"}}, true},
+		{&TurnFailedError{ThreadError: ThreadError{Message: "model refused"}}, false},
+		{&ExecError{ExitCode: -1, Err: errors.New("signal: segmentation fault")}, true},
+		{&ExecError{ExitCode: 1, Stderr: "not logged in"}, false},
+		{&ExecError{ExitCode: 1, Stderr: "error: stream disconnected before completion"}, true},
+		{&ExecError{ExitCode: 2, Stderr: "error: unexpected argument '--bogus' found"}, false},
+		{&ExecError{ExitCode: 1, Stderr: "error: connection reset by peer"}, true},
+		{&ExecError{ExitCode: 1, Stderr: "error: invalid network_access config"}, false},
+		{&ExecError{ExitCode: 1, Stderr: "error: connection refused to localhost:0 (bad base URL)"}, false},
+		{&TurnFailedError{ThreadError: ThreadError{Message: "network error: stream closed"}}, true},
+		{&ProtocolError{Line: []byte(`{"type":`)}, true},
+		{context.Canceled, false},
+		{&BudgetExceededError{}, false},
+	}
+	for _, test := range tests {
+		if got := IsRetryable(test.err); got != test.retryable {
+			t.Errorf("IsRetryable(%v) = %t, want %t", test.err, got, test.retryable)
+		}
+	}
+}
+
+func TestRetryPolicyContinuation(t *testing.T) {
+	policy := &RetryPolicy{ContinuationPrompt: "Failed at 100% (%s), retry %s."}
+	got := policy.continuation(errors.New("rate limited"))
+	if want := "Failed at 100% (rate limited), retry rate limited."; got != want {
+		t.Fatalf("continuation = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,225 @@
+package codex
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType           = reflect.TypeFor[time.Time]()
+	jsonRawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// SchemaFor derives a JSON Schema for T that satisfies the codex CLI's strict
+// structured output mode. T must be a struct (or a pointer to one).
+//
+// Field names follow their json tags and fields tagged with "-" are skipped.
+// Every field is listed as required; pointer fields and fields tagged with
+// omitempty or omitzero are made nullable instead. Two additional struct tags
+// are recognized:
+//
+//   - description:"..." adds a description to the property.
+//   - enum:"a,b,c" restricts the property to a comma separated set of values.
+//
+// Maps, interfaces, channels, functions and recursive types cannot be
+// expressed in strict mode and result in an error.
+func SchemaFor[T any]() (map[string]any, error) {
+	typ := reflect.TypeFor[T]()
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("output schema type %s must be a struct", typ)
+	}
+
+	g := &schemaGenerator{seen: make(map[reflect.Type]bool)}
+	return g.schema(typ, "$")
+}
+
+type schemaGenerator struct {
+	seen map[reflect.Type]bool
+}
+
+func (g *schemaGenerator) schema(typ reflect.Type, path string) (map[string]any, error) {
+	switch {
+	case typ == timeType:
+		return map[string]any{"type": "string"}, nil
+	case typ == jsonRawMessageType:
+		return nil, fmt.Errorf("%s: json.RawMessage is not supported in strict output schemas", path)
+	}
+
+	switch typ.Kind() {
+	case reflect.Pointer:
+		inner, err := g.schema(typ.Elem(), path)
+		if err != nil {
+			return nil, err
+		}
+		return nullableSchema(inner), nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			// encoding/json renders byte slices as base64 strings.
+			return map[string]any{"type": "string"}, nil
+		}
+		items, err := g.schema(typ.Elem(), path+"[]")
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Struct:
+		return g.objectSchema(typ, path)
+	default:
+		return nil, fmt.Errorf("%s: unsupported type %s for strict output schemas", path, typ)
+	}
+}
+
+func (g *schemaGenerator) objectSchema(typ reflect.Type, path string) (map[string]any, error) {
+	if g.seen[typ] {
+		return nil, fmt.Errorf("%s: recursive type %s is not supported", path, typ)
+	}
+	g.seen[typ] = true
+	defer delete(g.seen, typ)
+
+	properties := make(map[string]any)
+	required := []string{}
+
+	if err := g.addFields(typ, path, properties, &required); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}, nil
+}
+
+func (g *schemaGenerator) addFields(typ reflect.Type, path string, properties map[string]any, required *[]string) error {
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// Untagged embedded structs are flattened, mirroring encoding/json.
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := g.addFields(embedded, path, properties, required); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldPath := path + "." + name
+
+		prop, err := g.schema(field.Type, fieldPath)
+		if err != nil {
+			return err
+		}
+
+		if values := field.Tag.Get("enum"); values != "" {
+			enum, err := enumValues(field.Type, values)
+			if err != nil {
+				return fmt.Errorf("%s: %w", fieldPath, err)
+			}
+			prop["enum"] = enum
+		}
+
+		if description := field.Tag.Get("description"); description != "" {
+			prop["description"] = description
+		}
+
+		if field.Type.Kind() == reflect.Pointer || hasTagOption(opts, "omitempty") || hasTagOption(opts, "omitzero") {
+			prop = nullableSchema(prop)
+		}
+
+		properties[name] = prop
+		*required = append(*required, name)
+	}
+	return nil
+}
+
+// nullableSchema widens a schema so that it also accepts null, which is how
+// strict mode expresses optional values.
+func nullableSchema(schema map[string]any) map[string]any {
+	switch typ := schema["type"].(type) {
+	case string:
+		schema["type"] = []any{typ, "null"}
+	case []any:
+		if !slices.Contains(typ, any("null")) {
+			schema["type"] = append(typ, "null")
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, nil) {
+		schema["enum"] = append(enum, nil)
+	}
+	return schema
+}
+
+func enumValues(typ reflect.Type, values string) ([]any, error) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	var enum []any
+	for raw := range strings.SplitSeq(values, ",") {
+		raw = strings.TrimSpace(raw)
+		switch typ.Kind() {
+		case reflect.String:
+			enum = append(enum, raw)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integer enum value %q", raw)
+			}
+			enum = append(enum, n)
+		case reflect.Float32, reflect.Float64:
+			n, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number enum value %q", raw)
+			}
+			enum = append(enum, n)
+		default:
+			return nil, fmt.Errorf("enum tag is not supported for type %s", typ)
+		}
+	}
+	return enum, nil
+}
+
+func hasTagOption(opts, option string) bool {
+	for opt := range strings.SplitSeq(opts, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package codex
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type reviewFinding struct {
+	File     string `json:"file" description:"Path of the reviewed file."`
+	Line     int    `json:"line"`
+	Severity string `json:"severity" enum:"low,medium,high"`
+}
+
+type reviewResult struct {
+	Summary  string          `json:"summary"`
+	Findings []reviewFinding `json:"findings"`
+	Approved bool            `json:"approved"`
+	Score    *float64        `json:"score"`
+	Notes    string          `json:"notes,omitempty"`
+	internal string
+	Ignored  string `json:"-"`
+}
+
+func TestSchemaFor(t *testing.T) {
+	schema, err := SchemaFor[reviewResult]()
+	if err != nil {
+		t.Fatalf("SchemaFor returned error: %v", err)
+	}
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("failed to marshal schema: %v", err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to unmarshal schema: %v", err)
+	}
+
+	expected := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"summary": map[string]any{"type": "string"},
+			"findings": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"file":     map[string]any{"type": "string", "description": "Path of the reviewed file."},
+						"line":     map[string]any{"type": "integer"},
+						"severity": map[string]any{"type": "string", "enum": []any{"low", "medium", "high"}},
+					},
+					"required":             []any{"file", "line", "severity"},
+					"additionalProperties": false,
+				},
+			},
+			"approved": map[string]any{"type": "boolean"},
+			"score":    map[string]any{"type": []any{"number", "null"}},
+			"notes":    map[string]any{"type": []any{"string", "null"}},
+		},
+		"required":             []any{"summary", "findings", "approved", "score", "notes"},
+		"additionalProperties": false,
+	}
+
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("unexpected schema:\n%s", data)
+	}
+}
+
+func TestSchemaForRejectsUnsupportedTypes(t *testing.T) {
+	type withMap struct {
+		Labels map[string]string `json:"labels"`
+	}
+	if _, err := SchemaFor[withMap](); err == nil {
+		t.Fatal("expected an error for map fields")
+	}
+
+	type node struct {
+		Children []node `json:"children"`
+	}
+	if _, err := SchemaFor[node](); err == nil {
+		t.Fatal("expected an error for recursive types")
+	}
+
+	if _, err := SchemaFor[string](); err == nil {
+		t.Fatal("expected an error for non-struct types")
+	}
+}
+
+func TestDecodeStructuredOutput(t *testing.T) {
+	schema, err := SchemaFor[reviewResult]()
+	if err != nil {
+		t.Fatalf("SchemaFor returned error: %v", err)
+	}
+
+	response := `{"summary":"ok","findings":[{"file":"main.go","line":3,"severity":"low"}],"approved":true,"score":null,"notes":null}`
+	result, err := decodeStructuredOutput[reviewResult](schema, response)
+	if err != nil {
+		t.Fatalf("decodeStructuredOutput returned error: %v", err)
+	}
+	if result.Summary != "ok" || len(result.Findings) != 1 || result.Findings[0].Severity != "low" {
+		t.Fatalf("unexpected decoded value: %+v", result)
+	}
+}
+
+func TestDecodeStructuredOutputViolations(t *testing.T) {
+	schema, err := SchemaFor[reviewResult]()
+	if err != nil {
+		t.Fatalf("SchemaFor returned error: %v", err)
+	}
+
+	response := `{"summary":"ok","findings":[{"file":"main.go","line":1.5,"severity":"critical"}],"approved":true,"score":null,"extra":1}`
+	result, err := decodeStructuredOutput[reviewResult](schema, response)
+
+	var validationErr *OutputValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected OutputValidationError, got %v", err)
+	}
+	if result.Summary != "ok" {
+		t.Fatalf("expected best-effort decoded value, got %+v", result)
+	}
+
+	expected := []SchemaViolation{
+		{Path: "$", Message: `missing required property "notes"`},
+		{Path: "$.extra", Message: "unexpected property"},
+		{Path: "$.findings[0].line", Message: "expected integer, got number"},
+		{Path: "$.findings[0].severity", Message: `value "critical" is not one of the allowed enum values`},
+	}
+	if !reflect.DeepEqual(expected, validationErr.Violations) {
+		t.Fatalf("unexpected violations: %#v", validationErr.Violations)
+	}
+}
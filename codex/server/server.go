@@ -0,0 +1,456 @@
+// Package server exposes codex threads over HTTP. Clients start a turn with a
+// prompt, optionally resuming an existing thread, follow its events as
+// Server-Sent Events or over a WebSocket, and can cancel it while it runs.
+//
+// Routes:
+//
+//	POST   /turns             start a turn from a StartRequest
+//	GET    /turns             list the known turns
+//	GET    /turns/{id}        report the status of a turn
+//	DELETE /turns/{id}        cancel a running turn
+//	GET    /turns/{id}/events stream the turn's events as Server-Sent Events
+//	GET    /turns/{id}/ws     stream the turn's events over a WebSocket
+//
+// Events are buffered for the lifetime of a turn, so clients that connect
+// late, or reconnect with a Last-Event-ID header, receive the events they
+// missed. Every stream ends with an EndEvent describing the outcome.
+//
+// Since the server runs agents on the machine it runs on, it refuses requests
+// that web pages could forge: requests from browser origins that are not
+// allowed, POST bodies that are not JSON, and requests addressed to host
+// names that are not allowed, which blocks DNS rebinding.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/picatz/openai/codex"
+	"github.com/segmentio/ksuid"
+	"golang.org/x/net/websocket"
+)
+
+// DefaultMaxTurns is the number of turns a Server keeps when
+// Options.MaxTurns is not set.
+const DefaultMaxTurns = 100
+
+// Turn statuses reported in TurnStatus.Status.
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// EventTypeTurnEnded is the type of the EndEvent that ends every stream.
+const EventTypeTurnEnded = "turn.ended"
+
+// Options configure a Server.
+type Options struct {
+	// ThreadOptions configure every thread started or resumed by the server,
+	// such as its sandbox and working directory. Requests can only override
+	// the model.
+	ThreadOptions codex.ThreadOptions
+	// Token, when set, must be sent by clients as a bearer token in the
+	// Authorization header. The event stream routes also accept it as the
+	// token query parameter, for browsers' WebSocket and EventSource APIs
+	// that cannot set headers.
+	Token string
+	// AllowedOrigins lists the browser origins allowed to call the server,
+	// with "*" allowing any origin. Requests from the server's own origin and
+	// from non-browser clients, which send no Origin header, are always
+	// allowed; requests from other origins are refused.
+	AllowedOrigins []string
+	// AllowedHosts lists the host names, with or without a port, clients may
+	// address the server by in the Host header, with "*" allowing any host.
+	// localhost and IP addresses are always allowed. Other host names are
+	// refused, so that a web page can't reach the server through a domain it
+	// controls that resolves to the server's address.
+	AllowedHosts []string
+	// MaxTurns caps the number of finished turns kept for status and replay.
+	// Defaults to DefaultMaxTurns.
+	MaxTurns int
+}
+
+// StartRequest is the body of a POST /turns request.
+type StartRequest struct {
+	// Prompt is the input of the turn.
+	Prompt string `json:"prompt"`
+	// ThreadID resumes an existing thread instead of starting a new one.
+	ThreadID string `json:"thread_id,omitempty"`
+	// Model overrides the model configured for the server.
+	Model string `json:"model,omitempty"`
+	// OutputSchema requests structured output following the JSON schema.
+	OutputSchema json.RawMessage `json:"output_schema,omitempty"`
+}
+
+// TurnStatus describes a turn known to the server.
+type TurnStatus struct {
+	TurnID   string `json:"turn_id"`
+	ThreadID string `json:"thread_id,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	// Events counts the events emitted so far.
+	Events int `json:"events"`
+}
+
+// EndEvent is sent after the last event of a turn.
+type EndEvent struct {
+	Type string     `json:"type"`
+	Turn TurnStatus `json:"turn"`
+}
+
+// Server is an http.Handler that runs codex turns and streams their events.
+type Server struct {
+	client  *codex.Client
+	options Options
+	mux     *http.ServeMux
+
+	mu    sync.Mutex
+	turns map[string]*turn
+	order []string
+}
+
+// New creates a Server that runs turns with client.
+func New(client *codex.Client, options Options) *Server {
+	if options.MaxTurns <= 0 {
+		options.MaxTurns = DefaultMaxTurns
+	}
+	s := &Server{
+		client:  client,
+		options: options,
+		mux:     http.NewServeMux(),
+		turns:   make(map[string]*turn),
+	}
+	s.mux.HandleFunc("POST /turns", s.handleStart)
+	s.mux.HandleFunc("GET /turns", s.handleList)
+	s.mux.HandleFunc("GET /turns/{id}", s.handleStatus)
+	s.mux.HandleFunc("DELETE /turns/{id}", s.handleCancel)
+	s.mux.HandleFunc("GET /turns/{id}/events", s.handleEvents)
+	s.mux.Handle("GET /turns/{id}/ws", websocket.Server{
+		Handshake: s.websocketHandshake,
+		Handler:   s.handleWebSocket,
+	})
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.hostAllowed(r.Host) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not allowed", r.Host))
+		return
+	}
+	if err := s.checkOrigin(r); err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Last-Event-ID")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE")
+		w.Header().Add("Vary", "Origin")
+	}
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close cancels every running turn.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.turns {
+		t.cancel()
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.options.Token == "" {
+		return true
+	}
+	var token string
+	if streamRoute(r) {
+		// Query parameters end up in logs and browser history, so they are
+		// only accepted where headers can't be set.
+		token = r.URL.Query().Get("token")
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.options.Token)) == 1
+}
+
+// streamRoute reports whether r requests an event stream.
+func streamRoute(r *http.Request) bool {
+	return r.Method == http.MethodGet && (strings.HasSuffix(r.URL.Path, "/events") || strings.HasSuffix(r.URL.Path, "/ws"))
+}
+
+func (s *Server) hostAllowed(host string) bool {
+	if slices.Contains(s.options.AllowedHosts, "*") || slices.Contains(s.options.AllowedHosts, host) {
+		return true
+	}
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	name = strings.TrimSuffix(strings.Trim(name, "[]"), ".")
+	if strings.EqualFold(name, "localhost") || net.ParseIP(name) != nil {
+		return true
+	}
+	return slices.Contains(s.options.AllowedHosts, name)
+}
+
+// checkOrigin returns an error unless r comes from a non-browser client, the
+// server's own origin, or an allowed origin.
+func (s *Server) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(s.options.AllowedOrigins, "*") || slices.Contains(s.options.AllowedOrigins, origin) {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return nil
+	}
+	return fmt.Errorf("origin %q is not allowed", origin)
+}
+
+func (s *Server) websocketHandshake(config *websocket.Config, r *http.Request) error {
+	return s.checkOrigin(r)
+}
+
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
+	// Requiring JSON forces browsers to send a CORS preflight, so pages from
+	// other origins can't start turns with a simple form or fetch request.
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, errors.New("request body must be application/json"))
+		return
+	}
+
+	var req StartRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode request: %w", err))
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("prompt must be set"))
+		return
+	}
+
+	var turnOptions codex.TurnOptions
+	if len(req.OutputSchema) > 0 {
+		var schema map[string]any
+		if err := json.Unmarshal(req.OutputSchema, &schema); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("output schema must be a JSON object: %w", err))
+			return
+		}
+		turnOptions.OutputSchema = schema
+	}
+
+	threadOptions := s.options.ThreadOptions
+	if req.Model != "" {
+		threadOptions.Model = req.Model
+	}
+
+	// Turns outlive the request that started them, and are stopped through
+	// DELETE /turns/{id} or Close.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	t := newTurn(ksuid.New().String(), req.ThreadID, cancel)
+
+	s.mu.Lock()
+	if req.ThreadID != "" && s.threadBusyLocked(req.ThreadID) {
+		s.mu.Unlock()
+		cancel()
+		writeError(w, http.StatusConflict, fmt.Errorf("thread %s already has a running turn", req.ThreadID))
+		return
+	}
+	s.turns[t.id] = t
+	s.order = append(s.order, t.id)
+	s.pruneLocked()
+	s.mu.Unlock()
+
+	var thread *codex.Thread
+	if req.ThreadID != "" {
+		thread = s.client.ResumeThread(req.ThreadID, threadOptions)
+	} else {
+		thread = s.client.StartThread(threadOptions)
+	}
+	go t.run(ctx, thread, codex.TextInput(req.Prompt), &turnOptions)
+
+	w.Header().Set("Location", "/turns/"+t.id)
+	writeJSON(w, http.StatusAccepted, t.status())
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	statuses := make([]TurnStatus, 0, len(s.order))
+	for _, id := range s.order {
+		statuses = append(statuses, s.turns[id].status())
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, t.status())
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	t.cancel()
+	<-t.done
+	writeJSON(w, http.StatusOK, t.status())
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	from := 0
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		n, err := strconv.Atoi(lastID)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid Last-Event-ID %q", lastID))
+			return
+		}
+		from = n + 1
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	err := t.follow(r.Context(), from, func(idx int, event codex.ThreadEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", idx, event.Type, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(EndEvent{Type: EventTypeTurnEnded, Turn: t.status()})
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", EventTypeTurnEnded, data)
+	_ = rc.Flush()
+}
+
+// handleWebSocket sends every event as a JSON text message followed by an
+// EndEvent. Clients may send {"type":"cancel"} to cancel the turn.
+func (s *Server) handleWebSocket(conn *websocket.Conn) {
+	defer conn.Close()
+
+	t, ok := s.turn(conn.Request().PathValue("id"))
+	if !ok {
+		_ = websocket.JSON.Send(conn, map[string]string{"error": "turn not found"})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(conn.Request().Context())
+	defer cancel()
+
+	go func() {
+		defer cancel()
+		for {
+			var msg struct {
+				Type string `json:"type"`
+			}
+			if err := websocket.JSON.Receive(conn, &msg); err != nil {
+				return
+			}
+			if msg.Type == "cancel" {
+				t.cancel()
+			}
+		}
+	}()
+
+	err := t.follow(ctx, 0, func(_ int, event codex.ThreadEvent) error {
+		return websocket.JSON.Send(conn, event)
+	})
+	if err != nil {
+		return
+	}
+	_ = websocket.JSON.Send(conn, EndEvent{Type: EventTypeTurnEnded, Turn: t.status()})
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (*turn, bool) {
+	id := r.PathValue("id")
+	t, ok := s.turn(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("turn %s not found", id))
+	}
+	return t, ok
+}
+
+func (s *Server) turn(id string) (*turn, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.turns[id]
+	return t, ok
+}
+
+func (s *Server) threadBusyLocked(threadID string) bool {
+	for _, t := range s.turns {
+		status := t.status()
+		if status.Status == StatusRunning && status.ThreadID == threadID {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneLocked drops the oldest finished turns beyond Options.MaxTurns.
+func (s *Server) pruneLocked() {
+	for idx := 0; len(s.order) > s.options.MaxTurns && idx < len(s.order); {
+		id := s.order[idx]
+		if s.turns[id].status().Status == StatusRunning {
+			idx++
+			continue
+		}
+		delete(s.turns, id)
+		s.order = slices.Delete(s.order, idx, idx+1)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,308 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/picatz/openai/codex"
+	"github.com/picatz/openai/codex/codextest"
+	"golang.org/x/net/websocket"
+)
+
+func completedScript() codextest.Script {
+	return codextest.Events(
+		codex.ThreadEvent{Type: codex.EventTypeThreadStarted, ThreadID: "thread_1"},
+		codex.ThreadEvent{Type: codex.EventTypeItemCompleted, Item: &codex.AgentMessageItem{ID: "msg_1", Type: codex.ItemTypeAgentMessage, Text: "done"}},
+		codex.ThreadEvent{Type: codex.EventTypeTurnCompleted, Usage: &codex.Usage{InputTokens: 3, OutputTokens: 1}},
+	)
+}
+
+// slowScript starts a thread and then stalls until the turn is cancelled.
+func slowScript() codextest.Script {
+	return codextest.Script{Steps: []codextest.Step{
+		{Event: codex.ThreadEvent{Type: codex.EventTypeThreadStarted, ThreadID: "thread_slow"}},
+		{Event: codex.ThreadEvent{Type: codex.EventTypeTurnCompleted, Usage: &codex.Usage{}}, Delay: time.Minute},
+	}}
+}
+
+func newTestServer(t *testing.T, options Options, scripts ...codextest.Script) (*httptest.Server, *codextest.Executor) {
+	t.Helper()
+	executor := codextest.NewExecutor(scripts...)
+	srv := New(executor.Client(codex.Options{}), options)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		srv.Close()
+		ts.Close()
+	})
+	return ts, executor
+}
+
+func startTurn(t *testing.T, ts *httptest.Server, req StartRequest) TurnStatus {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(ts.URL+"/turns", "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202, got %s", resp.Status)
+	}
+	var status TurnStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+type sseEvent struct {
+	id, name, data string
+}
+
+func readSSE(t *testing.T, req *http.Request) []sseEvent {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	var (
+		events  []sseEvent
+		current sseEvent
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			events = append(events, current)
+			current = sseEvent{}
+		case strings.HasPrefix(line, "id: "):
+			current.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return events
+}
+
+func TestServerStreamsServerSentEvents(t *testing.T) {
+	ts, executor := newTestServer(t, Options{ThreadOptions: codex.ThreadOptions{Model: "gpt-5-codex"}}, completedScript())
+
+	status := startTurn(t, ts, StartRequest{Prompt: "summarize", Model: "gpt-5-mini"})
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/turns/"+status.TurnID+"/events", nil)
+	events := readSSE(t, req)
+
+	var names []string
+	for _, event := range events {
+		names = append(names, event.name)
+	}
+	if got := strings.Join(names, " "); got != "thread.started item.completed turn.completed turn.ended" {
+		t.Fatalf("unexpected events: %s", got)
+	}
+	if !strings.Contains(events[1].data, `"text":"done"`) {
+		t.Fatalf("expected the agent message in the event data, got %s", events[1].data)
+	}
+
+	var end EndEvent
+	if err := json.Unmarshal([]byte(events[3].data), &end); err != nil {
+		t.Fatal(err)
+	}
+	if end.Turn.Status != StatusCompleted || end.Turn.ThreadID != "thread_1" || end.Turn.Events != 3 {
+		t.Fatalf("unexpected end event: %+v", end)
+	}
+	if runs := executor.Runs(); len(runs) != 1 || runs[0].Input != "summarize" || runs[0].Model != "gpt-5-mini" {
+		t.Fatalf("unexpected runs: %+v", runs)
+	}
+
+	// Reconnecting with Last-Event-ID replays only the missed events.
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/turns/"+status.TurnID+"/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	replayed := readSSE(t, req)
+	if len(replayed) != 2 || replayed[0].id != "2" || replayed[0].name != "turn.completed" {
+		t.Fatalf("unexpected replay: %+v", replayed)
+	}
+}
+
+func TestServerWebSocketCancel(t *testing.T) {
+	ts, _ := newTestServer(t, Options{}, slowScript())
+
+	status := startTurn(t, ts, StartRequest{Prompt: "take your time"})
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/turns/" + status.TurnID + "/ws"
+	conn, err := websocket.Dial(wsURL, "", ts.URL)
+	if err != nil {
+		t.Fatalf("Dial returned error: %v", err)
+	}
+	defer conn.Close()
+
+	var started codex.ThreadEvent
+	if err := websocket.JSON.Receive(conn, &started); err != nil {
+		t.Fatal(err)
+	}
+	if started.Type != codex.EventTypeThreadStarted || started.ThreadID != "thread_slow" {
+		t.Fatalf("unexpected first event: %+v", started)
+	}
+
+	if err := websocket.JSON.Send(conn, map[string]string{"type": "cancel"}); err != nil {
+		t.Fatal(err)
+	}
+	var end EndEvent
+	if err := websocket.JSON.Receive(conn, &end); err != nil {
+		t.Fatal(err)
+	}
+	if end.Type != EventTypeTurnEnded || end.Turn.Status != StatusCancelled {
+		t.Fatalf("unexpected end event: %+v", end)
+	}
+}
+
+func TestServerCancelAndConflicts(t *testing.T) {
+	ts, executor := newTestServer(t, Options{}, slowScript())
+
+	status := startTurn(t, ts, StartRequest{Prompt: "resume me", ThreadID: "thread_slow"})
+
+	resp, err := http.Post(ts.URL+"/turns", "application/json", strings.NewReader(`{"prompt":"again","thread_id":"thread_slow"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 for a busy thread, got %s", resp.Status)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/turns/"+status.TurnID, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var cancelled TurnStatus
+	if err := json.NewDecoder(resp.Body).Decode(&cancelled); err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != StatusCancelled {
+		t.Fatalf("expected the turn to be cancelled, got %+v", cancelled)
+	}
+	if runs := executor.Runs(); len(runs) != 1 || runs[0].ThreadID != "thread_slow" {
+		t.Fatalf("expected a single resumed run, got %+v", runs)
+	}
+
+	resp, err = http.Get(ts.URL + "/turns/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown turn, got %s", resp.Status)
+	}
+}
+
+func TestServerRequiresToken(t *testing.T) {
+	ts, _ := newTestServer(t, Options{Token: "secret"}, completedScript())
+
+	resp, err := http.Get(ts.URL + "/turns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %s", resp.Status)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/turns", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 with a bearer token, got %s", resp.Status)
+	}
+
+	// The token parameter is only accepted by the event stream routes.
+	resp, err = http.Get(ts.URL + "/turns?token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with a token parameter, got %s", resp.Status)
+	}
+
+	resp, err = http.Get(ts.URL + "/turns/missing/events?token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown turn with a token parameter, got %s", resp.Status)
+	}
+}
+
+func TestServerRefusesCrossSiteRequests(t *testing.T) {
+	ts, executor := newTestServer(t, Options{AllowedOrigins: []string{"https://app.example"}, AllowedHosts: []string{"codex.internal"}}, completedScript())
+
+	post := func(contentType, origin, host string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/turns", strings.NewReader(`{"prompt":"hi"}`))
+		req.Header.Set("Content-Type", contentType)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if host != "" {
+			req.Host = host
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, test := range []struct {
+		name                string
+		contentType, origin string
+		host                string
+		want                int
+	}{
+		{"other origin", "application/json", "https://evil.example", "", http.StatusForbidden},
+		{"text body", "text/plain", "", "", http.StatusUnsupportedMediaType},
+		{"rebound host", "application/json", "", "evil.example:8787", http.StatusForbidden},
+		{"same origin", "application/json", ts.URL, "", http.StatusAccepted},
+	} {
+		if got := post(test.contentType, test.origin, test.host); got != test.want {
+			t.Errorf("%s: expected %d, got %d", test.name, test.want, got)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/turns", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Host = "codex.internal:8787"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "https://app.example" {
+		t.Fatalf("expected an allowed origin and host to be served, got %s", resp.Status)
+	}
+
+	if runs := executor.Runs(); len(runs) > 1 {
+		t.Fatalf("expected only the same origin request to start a turn, got %d runs", len(runs))
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/picatz/openai/codex"
+)
+
+// turn buffers the events of a running turn and notifies its followers.
+type turn struct {
+	id     string
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	threadID string
+	events   []codex.ThreadEvent
+	state    string
+	err      error
+	changed  chan struct{}
+}
+
+func newTurn(id, threadID string, cancel context.CancelFunc) *turn {
+	return &turn{
+		id:       id,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		threadID: threadID,
+		state:    StatusRunning,
+		changed:  make(chan struct{}),
+	}
+}
+
+func (t *turn) run(ctx context.Context, thread *codex.Thread, input codex.Input, turnOptions *codex.TurnOptions) {
+	defer t.cancel()
+
+	streamed, err := thread.RunStreamed(ctx, input, turnOptions)
+	if err != nil {
+		t.finish(ctx, err)
+		return
+	}
+
+	var failure error
+	for event := range streamed.Events {
+		if event.Type == codex.EventTypeTurnFailed {
+			threadErr := codex.ThreadError{Message: "turn failed"}
+			if event.Error != nil {
+				threadErr = *event.Error
+			}
+			failure = &codex.TurnFailedError{ThreadError: threadErr}
+		}
+		t.append(event)
+	}
+
+	err = streamed.Wait()
+	if failure != nil {
+		err = failure
+	}
+	t.finish(ctx, err)
+}
+
+func (t *turn) append(event codex.ThreadEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if event.Type == codex.EventTypeThreadStarted && event.ThreadID != "" {
+		t.threadID = event.ThreadID
+	}
+	t.events = append(t.events, event)
+	t.notifyLocked()
+}
+
+func (t *turn) finish(ctx context.Context, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case ctx.Err() != nil && (err == nil || errors.Is(err, context.Canceled)):
+		t.state = StatusCancelled
+	case err != nil:
+		t.state = StatusFailed
+		t.err = err
+	default:
+		t.state = StatusCompleted
+	}
+	t.notifyLocked()
+	close(t.done)
+}
+
+func (t *turn) notifyLocked() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+func (t *turn) status() TurnStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := TurnStatus{
+		TurnID:   t.id,
+		ThreadID: t.threadID,
+		Status:   t.state,
+		Events:   len(t.events),
+	}
+	if t.err != nil {
+		status.Error = t.err.Error()
+	}
+	return status
+}
+
+// follow calls send for every event starting at index from, as events are
+// produced, and returns once the turn finished and every event was sent.
+func (t *turn) follow(ctx context.Context, from int, send func(idx int, event codex.ThreadEvent) error) error {
+	for {
+		t.mu.Lock()
+		events := t.events[min(from, len(t.events)):]
+		finished := t.state != StatusRunning
+		changed := t.changed
+		t.mu.Unlock()
+
+		for _, event := range events {
+			if err := send(from, event); err != nil {
+				return err
+			}
+			from++
+		}
+		if finished {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package codex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/picatz/openai/internal/chat/storage"
+	"github.com/picatz/openai/internal/chat/storage/memory"
+	pebbleStorage "github.com/picatz/openai/internal/chat/storage/pebble"
+)
+
+// ThreadRecord is the metadata a ThreadStore keeps for a thread.
+type ThreadRecord struct {
+	// ID is the thread identifier assigned by the CLI.
+	ID string `json:"id"`
+	// Prompt is the prompt of the first recorded turn.
+	Prompt string `json:"prompt"`
+	// Model, SandboxMode, WorkingDirectory and SkipGitRepoCheck are the thread
+	// options of the most recent turn, used to rehydrate the thread.
+	Model            string      `json:"model,omitempty"`
+	SandboxMode      SandboxMode `json:"sandbox_mode,omitempty"`
+	WorkingDirectory string      `json:"working_directory,omitempty"`
+	SkipGitRepoCheck bool        `json:"skip_git_repo_check,omitempty"`
+	// Usage is the cumulative token usage of all recorded turns.
+	Usage     Usage     `json:"usage"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Turns summarizes every recorded turn in order.
+	Turns []TurnRecord `json:"turns"`
+}
+
+// TurnRecord summarizes a single completed or failed turn.
+type TurnRecord struct {
+	Prompt        string
+	Items         []ThreadItem
+	FinalResponse string
+	// Usage is nil when the CLI did not report usage for the turn.
+	Usage *Usage
+	// Error holds the failure message of a failed turn.
+	Error       string
+	StartedAt   time.Time
+	CompletedAt time.Time
+}
+
+type turnRecordJSON struct {
+	Prompt        string            `json:"prompt"`
+	Items         []json.RawMessage `json:"items,omitempty"`
+	FinalResponse string            `json:"final_response,omitempty"`
+	Usage         *Usage            `json:"usage,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	StartedAt     time.Time         `json:"started_at"`
+	CompletedAt   time.Time         `json:"completed_at"`
+}
+
+// MarshalJSON encodes the turn, including its polymorphic items.
+func (r TurnRecord) MarshalJSON() ([]byte, error) {
+	aux := turnRecordJSON{
+		Prompt:        r.Prompt,
+		FinalResponse: r.FinalResponse,
+		Usage:         r.Usage,
+		Error:         r.Error,
+		StartedAt:     r.StartedAt,
+		CompletedAt:   r.CompletedAt,
+	}
+	for _, item := range r.Items {
+		data, err := MarshalThreadItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("encode thread item: %w", err)
+		}
+		aux.Items = append(aux.Items, data)
+	}
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON decodes a turn encoded by MarshalJSON.
+func (r *TurnRecord) UnmarshalJSON(data []byte) error {
+	var aux turnRecordJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	items := make([]ThreadItem, 0, len(aux.Items))
+	for _, raw := range aux.Items {
+		item, err := UnmarshalThreadItem(raw)
+		if err != nil {
+			return fmt.Errorf("decode thread item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	*r = TurnRecord{
+		Prompt:        aux.Prompt,
+		Items:         items,
+		FinalResponse: aux.FinalResponse,
+		Usage:         aux.Usage,
+		Error:         aux.Error,
+		StartedAt:     aux.StartedAt,
+		CompletedAt:   aux.CompletedAt,
+	}
+	return nil
+}
+
+// observe updates the turn summary with a streamed event.
+func (r *TurnRecord) observe(event ThreadEvent) {
+	switch event.Type {
+	case EventTypeItemCompleted:
+		if event.Item == nil {
+			return
+		}
+		if msg, ok := event.Item.(*AgentMessageItem); ok {
+			r.FinalResponse = msg.Text
+		}
+		r.Items = append(r.Items, event.Item)
+	case EventTypeTurnCompleted:
+		r.Usage = event.Usage
+	case EventTypeTurnFailed:
+		r.Error = "turn failed"
+		if event.Error != nil {
+			r.Error = event.Error.Message
+		}
+	}
+}
+
+// ErrThreadNotFound is returned when a ThreadStore has no record for a thread.
+var ErrThreadNotFound = errors.New("thread not found")
+
+// ThreadStore durably records threads and their turns so they can be listed,
+// searched and resumed after the process exits. Set Options.ThreadStore to
+// record every turn run by a Client's threads.
+type ThreadStore struct {
+	mu      sync.Mutex
+	backend storage.Backend[string, ThreadRecord]
+}
+
+// OpenThreadStore opens, or creates, a ThreadStore backed by a pebble
+// database in the directory at path.
+func OpenThreadStore(path string) (*ThreadStore, error) {
+	backend, err := pebbleStorage.NewBackend(path, &pebble.Options{
+		LoggerAndTracer: storeLogger{},
+	}, &storage.JSONCodec[string, ThreadRecord]{})
+	if err != nil {
+		return nil, fmt.Errorf("open thread store: %w", err)
+	}
+	return &ThreadStore{backend: backend}, nil
+}
+
+// storeLogger silences pebble's informational logging, which would otherwise
+// be written to stderr of programs embedding the SDK.
+type storeLogger struct{}
+
+func (storeLogger) Infof(format string, args ...any)                       {}
+func (storeLogger) Fatalf(format string, args ...any)                      { panic(fmt.Sprintf(format, args...)) }
+func (storeLogger) Eventf(ctx context.Context, format string, args ...any) {}
+func (storeLogger) IsTracingEnabled(ctx context.Context) bool              { return false }
+
+// NewMemoryThreadStore creates a ThreadStore that keeps records in memory only.
+func NewMemoryThreadStore() *ThreadStore {
+	return &ThreadStore{backend: memory.NewBackend[string, ThreadRecord]()}
+}
+
+// Get returns the record for the thread with the given ID.
+func (s *ThreadStore) Get(ctx context.Context, id string) (ThreadRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, found, err := s.backend.Get(ctx, id)
+	if err != nil {
+		return ThreadRecord{}, false, fmt.Errorf("get thread %q: %w", id, err)
+	}
+	return record, found, nil
+}
+
+// List returns every recorded thread, most recently updated first.
+func (s *ThreadStore) List(ctx context.Context) ([]ThreadRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		records   []ThreadRecord
+		pageToken *string
+	)
+	for {
+		entries, next, err := s.backend.List(ctx, nil, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("list threads: %w", err)
+		}
+		for _, record := range entries {
+			records = append(records, record)
+		}
+		if next == nil {
+			break
+		}
+		pageToken = next
+	}
+
+	slices.SortStableFunc(records, func(a, b ThreadRecord) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return records, nil
+}
+
+// Search returns the threads whose ID, working directory, prompts or final
+// responses contain query, ignoring case, most recently updated first.
+func (s *ThreadStore) Search(ctx context.Context, query string) ([]ThreadRecord, error) {
+	records, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	contains := func(s string) bool { return strings.Contains(strings.ToLower(s), query) }
+
+	return slices.DeleteFunc(records, func(record ThreadRecord) bool {
+		if contains(record.ID) || contains(record.WorkingDirectory) || contains(record.Prompt) {
+			return false
+		}
+		for _, turn := range record.Turns {
+			if contains(turn.Prompt) || contains(turn.FinalResponse) {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
+
+// Delete removes the record for the thread with the given ID.
+func (s *ThreadStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.backend.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete thread %q: %w", id, err)
+	}
+	return nil
+}
+
+// Close flushes and closes the underlying storage.
+func (s *ThreadStore) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.backend.Flush(ctx); err != nil {
+		return fmt.Errorf("flush thread store: %w", err)
+	}
+	if err := s.backend.Close(ctx); err != nil {
+		return fmt.Errorf("close thread store: %w", err)
+	}
+	return nil
+}
+
+// recordTurn appends a turn to the thread's record, creating the record when
+// the thread has not been seen before.
+func (s *ThreadStore) recordTurn(ctx context.Context, id string, options ThreadOptions, turn TurnRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, found, err := s.backend.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("get thread %q: %w", id, err)
+	}
+	if !found {
+		record = ThreadRecord{ID: id, Prompt: turn.Prompt, CreatedAt: turn.StartedAt}
+	}
+
+	record.Model = options.Model
+	record.SandboxMode = options.SandboxMode
+	record.WorkingDirectory = options.WorkingDirectory
+	record.SkipGitRepoCheck = options.SkipGitRepoCheck
+	record.UpdatedAt = turn.CompletedAt
+	record.Turns = append(record.Turns, turn)
+	if turn.Usage != nil {
+		record.Usage.InputTokens += turn.Usage.InputTokens
+		record.Usage.CachedInputTokens += turn.Usage.CachedInputTokens
+		record.Usage.OutputTokens += turn.Usage.OutputTokens
+	}
+
+	if err := s.backend.Set(ctx, id, record); err != nil {
+		return fmt.Errorf("record thread %q: %w", id, err)
+	}
+	return nil
+}
+
+// threadOptions returns the options needed to resume the recorded thread.
+func (r ThreadRecord) threadOptions() ThreadOptions {
+	return ThreadOptions{
+		Model:            r.Model,
+		SandboxMode:      r.SandboxMode,
+		WorkingDirectory: r.WorkingDirectory,
+		SkipGitRepoCheck: r.SkipGitRepoCheck,
+	}
+}
@@ -0,0 +1,164 @@
+package codex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/picatz/openai/internal/chat/storage"
+	"github.com/picatz/openai/internal/chat/storage/memory"
+)
+
+// transcriptExecutor serves a canned transcript for every run, assigning the
+// thread ID given by the run's arguments or "thread_1" for new threads.
+func transcriptExecutor(response string) ReaderExecutor {
+	return func(ctx context.Context, args Args) (io.Reader, error) {
+		id := args.ThreadID
+		if id == "" {
+			id = "thread_1"
+		}
+		return strings.NewReader(strings.Join([]string{
+			fmt.Sprintf(`{"type":"thread.started","thread_id":%q}`, id),
+			fmt.Sprintf(`{"type":"item.completed","item":{"id":"msg_1","type":"agent_message","text":%q}}`, response),
+			`{"type":"turn.completed","usage":{"input_tokens":10,"cached_input_tokens":4,"output_tokens":2}}`,
+		}, "\n")), nil
+	}
+}
+
+func TestThreadStoreRecordsTurns(t *testing.T) {
+	ctx := t.Context()
+
+	for name, open := range map[string]func(t *testing.T) *ThreadStore{
+		"memory": func(t *testing.T) *ThreadStore { return NewMemoryThreadStore() },
+		"pebble": func(t *testing.T) *ThreadStore {
+			store, err := OpenThreadStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("OpenThreadStore returned error: %v", err)
+			}
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			defer store.Close(ctx)
+
+			client, err := New(Options{Executor: transcriptExecutor("fixed the bug"), ThreadStore: store})
+			if err != nil {
+				t.Fatalf("New returned error: %v", err)
+			}
+
+			thread := client.StartThread(ThreadOptions{Model: "gpt-5-codex", WorkingDirectory: "/src/app"})
+			if _, err := thread.RunText(ctx, "fix the flaky test", nil); err != nil {
+				t.Fatalf("Run returned error: %v", err)
+			}
+
+			resumed, err := client.ResumeStoredThread(ctx, "thread_1")
+			if err != nil {
+				t.Fatalf("ResumeStoredThread returned error: %v", err)
+			}
+			if resumed.ID() != "thread_1" || resumed.threadOptions.WorkingDirectory != "/src/app" {
+				t.Fatalf("unexpected resumed thread: id=%q options=%+v", resumed.ID(), resumed.threadOptions)
+			}
+			if _, err := resumed.RunText(ctx, "add a regression test", nil); err != nil {
+				t.Fatalf("Run returned error: %v", err)
+			}
+
+			record, found, err := store.Get(ctx, "thread_1")
+			if err != nil || !found {
+				t.Fatalf("Get returned found=%t err=%v", found, err)
+			}
+			if record.Prompt != "fix the flaky test" || record.Model != "gpt-5-codex" || len(record.Turns) != 2 {
+				t.Fatalf("unexpected record: %+v", record)
+			}
+			if record.Usage != (Usage{InputTokens: 20, CachedInputTokens: 8, OutputTokens: 4}) {
+				t.Fatalf("unexpected cumulative usage: %+v", record.Usage)
+			}
+			last := record.Turns[1]
+			if last.Prompt != "add a regression test" || last.FinalResponse != "fixed the bug" || len(last.Items) != 1 {
+				t.Fatalf("unexpected turn record: %+v", last)
+			}
+			if _, ok := last.Items[0].(*AgentMessageItem); !ok {
+				t.Fatalf("expected agent message item, got %T", last.Items[0])
+			}
+
+			matches, err := store.Search(ctx, "REGRESSION")
+			if err != nil || len(matches) != 1 {
+				t.Fatalf("Search returned %d matches, err=%v", len(matches), err)
+			}
+			if matches, _ := store.Search(ctx, "unrelated"); len(matches) != 0 {
+				t.Fatalf("expected no matches, got %d", len(matches))
+			}
+
+			if _, err := client.ResumeStoredThread(ctx, "missing"); !errors.Is(err, ErrThreadNotFound) {
+				t.Fatalf("expected ErrThreadNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestThreadStoreRecordsFailedTurns(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryThreadStore()
+
+	executor := ReaderExecutor(func(ctx context.Context, args Args) (io.Reader, error) {
+		return strings.NewReader(strings.Join([]string{
+			`{"type":"thread.started","thread_id":"thread_2"}`,
+			`{"type":"turn.failed","error":{"message":"rate limited"}}`,
+		}, "\n")), nil
+	})
+
+	client, err := New(Options{Executor: executor, ThreadStore: store})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if _, err := client.StartThread(ThreadOptions{}).RunText(ctx, "hi", nil); err == nil {
+		t.Fatal("expected turn failure")
+	}
+
+	records, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(records) != 1 || len(records[0].Turns) != 1 || records[0].Turns[0].Error != "rate limited" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+// failingBackend fails every write.
+type failingBackend struct {
+	storage.Backend[string, ThreadRecord]
+}
+
+func (failingBackend) Set(ctx context.Context, key string, value ThreadRecord) error {
+	return errors.New("lock held")
+}
+
+func TestThreadStoreErrorsDoNotFailTurns(t *testing.T) {
+	store := &ThreadStore{backend: failingBackend{memory.NewBackend[string, ThreadRecord]()}}
+
+	var storeErrs []error
+	client, err := New(Options{
+		Executor:    transcriptExecutor("done"),
+		ThreadStore: store,
+		ThreadStoreErrorHandler: func(ctx context.Context, threadID string, err error) {
+			storeErrs = append(storeErrs, fmt.Errorf("%s: %w", threadID, err))
+		},
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	turn, err := client.StartThread(ThreadOptions{}).RunText(t.Context(), "hi", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if turn.FinalResponse != "done" {
+		t.Fatalf("unexpected final response %q", turn.FinalResponse)
+	}
+	if len(storeErrs) != 1 || !strings.Contains(storeErrs[0].Error(), "thread_1: record thread") {
+		t.Fatalf("unexpected store errors: %v", storeErrs)
+	}
+}
@@ -1,6 +1,8 @@
 package codex
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -10,30 +12,37 @@ import (
 
 // EventStream returns an iterator that yields ThreadEvents decoded from the provided ExecStream.
 // The iterator yields (*ThreadEvent, nil) for each event, and (nil, error) if an error occurs.
+// Lines that are not valid JSON events are reported as a *ProtocolError and skipped.
 //
 // This is a convenience function for consuming events from a Codex execution stream. It
-// decodes JSON-encoded events from the stream's stdout until EOF or an error occurs.
+// decodes JSON-encoded events, one per line, from the stream's stdout until EOF.
 // The context can be used to cancel the iteration early, and the stream's Wait method is called
 // at the end to ensure proper cleanup. The stream is also closed when iteration ends.
 func EventStream(ctx context.Context, stream *ExecStream) iter.Seq2[*ThreadEvent, error] {
-	decoder := json.NewDecoder(stream.Stdout())
+	reader := bufio.NewReader(stream.Stdout())
 
 	return func(yield func(*ThreadEvent, error) bool) {
 		defer stream.Close()
 
 		for ctx.Err() == nil {
-			var event ThreadEvent
-			if err := decoder.Decode(&event); err != nil {
-				if err == io.EOF {
-					break
-				}
-				if !yield(nil, fmt.Errorf("failed to decode codex event from stream: %w", err)) {
+			line, readErr := reader.ReadBytes('\n')
+			if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+				var event ThreadEvent
+				if err := json.Unmarshal(trimmed, &event); err != nil {
+					if !yield(nil, &ProtocolError{Line: bytes.Clone(trimmed), Err: err}) {
+						return
+					}
+				} else if !yield(&event, nil) {
 					return
 				}
-				continue
 			}
-			if !yield(&event, nil) {
-				return
+			if readErr != nil {
+				if readErr != io.EOF {
+					if !yield(nil, fmt.Errorf("read codex output: %w", readErr)) {
+						return
+					}
+				}
+				break
 			}
 		}
 
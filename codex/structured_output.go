@@ -0,0 +1,134 @@
+package codex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SchemaViolation describes a single way in which a JSON value does not
+// conform to an output schema.
+type SchemaViolation struct {
+	// Path locates the offending value, starting with "$" for the document root.
+	Path string
+	// Message explains the violation.
+	Message string
+}
+
+// String renders the violation as "path: message".
+func (v SchemaViolation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+// OutputValidationError is returned when the agent's final response does not
+// conform to the requested output schema.
+type OutputValidationError struct {
+	// Response is the raw final response returned by the agent.
+	Response string
+	// Violations lists every mismatch found between the response and the schema.
+	Violations []SchemaViolation
+}
+
+func (e *OutputValidationError) Error() string {
+	return formatViolations("structured output does not match schema", e.Violations)
+}
+
+// RunTyped runs a turn that requests structured output shaped like T. The
+// schema is derived with SchemaFor and replaces any OutputSchema set on
+// turnOptions. The final response is validated against the schema and decoded
+// into T.
+//
+// When the response is valid JSON but does not conform to the schema, the
+// best-effort decoded value is returned together with an *OutputValidationError.
+func RunTyped[T any](ctx context.Context, thread *Thread, input Input, turnOptions *TurnOptions) (T, Turn, error) {
+	var zero T
+
+	schema, err := SchemaFor[T]()
+	if err != nil {
+		return zero, Turn{}, fmt.Errorf("derive output schema: %w", err)
+	}
+
+	var opts TurnOptions
+	if turnOptions != nil {
+		opts = *turnOptions
+	}
+	opts.OutputSchema = schema
+
+	turn, err := thread.Run(ctx, input, &opts)
+	if validationErr := (*OutputValidationError)(nil); errors.As(err, &validationErr) {
+		// Run validates the response itself when repairs are requested; the
+		// best-effort decoded value is still returned.
+		value, _ := decodeStructuredOutput[T](schema, validationErr.Response)
+		return value, turn, err
+	}
+	if err != nil {
+		return zero, turn, err
+	}
+
+	value, err := decodeStructuredOutput[T](schema, turn.FinalResponse)
+	return value, turn, err
+}
+
+func decodeStructuredOutput[T any](schema any, response string) (T, error) {
+	var value T
+
+	trimmed := strings.TrimSpace(response)
+	if trimmed == "" {
+		return value, errors.New("agent returned no final response to decode")
+	}
+
+	var document any
+	if err := json.Unmarshal([]byte(trimmed), &document); err != nil {
+		return value, fmt.Errorf("decode structured output: %w", err)
+	}
+
+	violations, err := validateAgainstSchema(schema, document)
+	if err != nil {
+		return value, err
+	}
+
+	if err := json.Unmarshal([]byte(trimmed), &value); err != nil && len(violations) == 0 {
+		return value, fmt.Errorf("decode structured output: %w", err)
+	}
+
+	if len(violations) > 0 {
+		return value, &OutputValidationError{Response: response, Violations: violations}
+	}
+	return value, nil
+}
+
+// repairPrompt asks the agent to fix a response that does not conform to the
+// output schema. %s is replaced with the list of violations.
+const repairPrompt = "Your previous response does not match the required output schema:\n%s\n\nRespond again with only a JSON document that fixes these problems and conforms to the schema."
+
+// validateOutput validates the final response of turn against the turn's
+// output schema, and asks the agent to fix it with follow-up turns on the same
+// thread up to TurnOptions.RepairAttempts times. The returned Turn merges the
+// items and usage of every follow-up turn, including when an error is returned.
+func (t *Thread) validateOutput(ctx context.Context, turn Turn, turnOptions *TurnOptions) (Turn, error) {
+	for attempt := 0; ; attempt++ {
+		violations, err := ValidateOutput(turnOptions.OutputSchema, turn.FinalResponse)
+		if err != nil {
+			return turn, err
+		}
+		if len(violations) == 0 {
+			return turn, nil
+		}
+		if attempt >= turnOptions.RepairAttempts || t.ID() == "" {
+			return turn, &OutputValidationError{Response: turn.FinalResponse, Violations: violations}
+		}
+
+		lines := make([]string, len(violations))
+		for idx, violation := range violations {
+			lines[idx] = "- " + violation.String()
+		}
+		repair, err := t.runAttempts(ctx, TextInput(fmt.Sprintf(repairPrompt, strings.Join(lines, "\n"))), turnOptions)
+		mergeTurn(&turn, repair)
+		if err != nil {
+			return turn, err
+		}
+		turn.FinalResponse = repair.FinalResponse
+	}
+}
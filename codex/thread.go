@@ -9,13 +9,17 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 )
 
 // Thread represents a conversation with an agent. A thread can span multiple turns.
 type Thread struct {
-	exec          *Exec
+	executor      Executor
 	options       Options
 	threadOptions ThreadOptions
+	ledger        *UsageLedger
+	// observe, when set, receives every event of turns run with Run.
+	observe func(ThreadEvent)
 
 	mu sync.RWMutex
 	id string
@@ -28,6 +32,11 @@ func (t *Thread) ID() string {
 	return t.id
 }
 
+// Usage returns the ledger that accumulates the thread's token usage.
+func (t *Thread) Usage() *UsageLedger {
+	return t.ledger
+}
+
 func (t *Thread) setID(id string) {
 	if id == "" {
 		return
@@ -52,6 +61,9 @@ type Turn struct {
 	// Usage reports token consumption for the turn. A nil value indicates the CLI
 	// did not emit usage information.
 	Usage *Usage
+	// Changes holds the changes the turn made to the git working tree when
+	// ThreadOptions.CaptureChanges is set.
+	Changes *ChangeSet
 }
 
 // RunResult aliases Turn for parity with the TypeScript SDK.
@@ -80,8 +92,75 @@ func (s *StreamedTurn) Wait() error {
 type RunStreamedResult = StreamedTurn
 
 // Run executes a complete agent turn with the provided input and returns its result.
-// The call blocks until the CLI exits or the context is cancelled.
+// When the turn pushes the thread's UsageLedger over its Budget, the completed
+// turn is returned together with a *BudgetExceededError. Likewise, when the
+// final response fails validation, the turn, merged with any repair turns, is
+// returned together with the *OutputValidationError.
+// When ThreadOptions.RetryPolicy is set, retryable failures are retried by
+// resuming the thread, and the items of every attempt are merged into the
+// returned Turn.
+//
+// When ThreadOptions.CaptureChanges is set, the changes the turn made to the
+// git working tree are attached to the returned Turn.
 func (t *Thread) Run(ctx context.Context, input Input, turnOptions *TurnOptions) (Turn, error) {
+	var snapshot *changeSnapshot
+	if t.threadOptions.CaptureChanges {
+		var err error
+		snapshot, err = snapshotChanges(t.threadOptions.WorkingDirectory)
+		if err != nil {
+			return Turn{}, err
+		}
+	}
+
+	turn, err := t.runAttempts(ctx, input, turnOptions)
+	var budgetErr *BudgetExceededError
+	switch {
+	case errors.As(err, &budgetErr):
+		// The turn that crossed the budget was still completed and paid
+		// for, so it is returned together with the error.
+	case err != nil:
+		return Turn{}, err
+	case turnOptions != nil && turnOptions.OutputSchema != nil && (turnOptions.ValidateOutput || turnOptions.RepairAttempts > 0):
+		turn, err = t.validateOutput(ctx, turn, turnOptions)
+		var validationErr *OutputValidationError
+		if err != nil && !errors.As(err, &validationErr) && !errors.As(err, &budgetErr) {
+			return Turn{}, err
+		}
+	}
+
+	if snapshot != nil {
+		changes, changesErr := snapshot.changes()
+		if changesErr != nil {
+			return Turn{}, changesErr
+		}
+		turn.Changes = changes
+	}
+	return turn, err
+}
+
+// runAttempts runs a turn, retrying it when ThreadOptions.RetryPolicy is set.
+func (t *Thread) runAttempts(ctx context.Context, input Input, turnOptions *TurnOptions) (Turn, error) {
+	if t.threadOptions.RetryPolicy != nil {
+		return t.runWithRetry(ctx, input, turnOptions, t.threadOptions.RetryPolicy)
+	}
+	return t.runOnce(ctx, input, turnOptions)
+}
+
+// mergeTurn appends the items of src to dst and adds up their usage.
+func mergeTurn(dst *Turn, src Turn) {
+	dst.Items = append(dst.Items, src.Items...)
+	if src.Usage != nil {
+		usage := *src.Usage
+		if dst.Usage != nil {
+			usage = addUsage(*dst.Usage, usage)
+		}
+		dst.Usage = &usage
+	}
+}
+
+// runOnce runs a single attempt of a turn. On failure the returned Turn holds
+// whatever was collected before the error.
+func (t *Thread) runOnce(ctx context.Context, input Input, turnOptions *TurnOptions) (Turn, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -99,6 +178,9 @@ func (t *Thread) Run(ctx context.Context, input Input, turnOptions *TurnOptions)
 
 loop:
 	for event := range streamed.Events {
+		if t.observe != nil {
+			t.observe(event)
+		}
 		switch event.Type {
 		case EventTypeItemCompleted:
 			if event.Item != nil {
@@ -121,19 +203,19 @@ loop:
 	}
 
 	waitErr := streamed.Wait()
+	turn := Turn{Items: items, FinalResponse: finalResponse, Usage: usage}
 
 	if turnFailure != nil {
-		if waitErr != nil && !errors.Is(waitErr, context.Canceled) {
-			return Turn{}, waitErr
-		}
-		return Turn{}, errors.New(turnFailure.Message)
+		// The CLI is stopped once the turn fails, so its exit status is
+		// irrelevant.
+		return turn, &TurnFailedError{ThreadError: *turnFailure, Items: items}
 	}
 
 	if waitErr != nil {
-		return Turn{}, waitErr
+		return turn, waitErr
 	}
 
-	return Turn{Items: items, FinalResponse: finalResponse, Usage: usage}, nil
+	return turn, nil
 }
 
 // RunText is a convenience wrapper for Run with a simple text prompt.
@@ -157,47 +239,88 @@ func (t *Thread) runStreamedInternal(ctx context.Context, input Input, turnOptio
 		turnOptions = &TurnOptions{}
 	}
 
+	if t.ledger != nil {
+		if err := t.ledger.Check(); err != nil {
+			return nil, err
+		}
+		if err := t.ledger.checkPriced(t.threadOptions.Model); err != nil {
+			return nil, err
+		}
+	}
+
+	if turnOptions.OutputSchema != nil {
+		if err := LintSchema(turnOptions.OutputSchema); err != nil {
+			return nil, err
+		}
+	}
+
 	schemaFile, err := createOutputSchemaFile(turnOptions.OutputSchema)
 	if err != nil {
 		return nil, err
 	}
 
-	prompt, images, err := normalizeInput(input)
+	input, inputFiles, err := materializeInput(ctx, input, turnOptions.InputOptions)
 	if err != nil {
 		_ = schemaFile.Cleanup()
 		return nil, err
 	}
+	cleanup := func() {
+		_ = schemaFile.Cleanup()
+		_ = inputFiles.Cleanup()
+	}
 
-	stream, err := t.exec.Run(ctx, Args{
-		Input:            prompt,
-		BaseURL:          t.options.BaseURL,
-		APIKey:           t.options.APIKey,
-		ThreadID:         t.currentID(),
-		Images:           images,
-		Model:            t.threadOptions.Model,
-		SandboxMode:      t.threadOptions.SandboxMode,
-		WorkingDirectory: t.threadOptions.WorkingDirectory,
-		SkipGitRepoCheck: t.threadOptions.SkipGitRepoCheck,
-		OutputSchemaFile: schemaFile.Path(),
-	})
+	prompt, images, err := normalizeInput(input)
 	if err != nil {
-		_ = schemaFile.Cleanup()
+		cleanup()
+		return nil, err
+	}
+
+	args, err := t.execArgs(prompt, images, schemaFile.Path(), turnOptions)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	if path := schemaFile.Path(); path != "" {
+		args.hostFiles = append(args.hostFiles, path)
+	}
+	for _, image := range images {
+		if inputFiles.contains(image) {
+			args.hostFiles = append(args.hostFiles, image)
+		}
+	}
+
+	startedAt := time.Now()
+
+	// The run gets its own cancellation so the CLI can be stopped when the
+	// stream is abandoned early, e.g. after an approval is denied.
+	runCtx, cancelRun := context.WithCancel(ctx)
+
+	stream, err := t.executor.Run(runCtx, args)
+	if err != nil {
+		cancelRun()
+		cleanup()
 		return nil, err
 	}
 
+	gate := newApprovalGate(t.threadOptions)
+	chain := middlewareChain(t.threadOptions)
+
 	events := make(chan ThreadEvent)
 	errCh := make(chan error, 1)
 
 	go func() {
 		defer close(events)
+		defer cancelRun()
 		stdout := stream.Stdout()
 		defer stdout.Close()
-		defer func() {
-			_ = schemaFile.Cleanup()
-		}()
+		defer cleanup()
 
 		reader := bufio.NewReader(stdout)
-		var runErr error
+		var (
+			runErr  error
+			aborted bool
+			record  = TurnRecord{Prompt: prompt, StartedAt: startedAt}
+		)
 
 		for {
 			if ctxErr := ctx.Err(); ctxErr != nil {
@@ -210,7 +333,8 @@ func (t *Thread) runStreamedInternal(ctx context.Context, input Input, turnOptio
 			if len(trimmed) > 0 {
 				var event ThreadEvent
 				if err := json.Unmarshal(trimmed, &event); err != nil {
-					runErr = fmt.Errorf("parse codex event: %w", err)
+					runErr = &ProtocolError{Line: bytes.Clone(trimmed), Err: err}
+					aborted = true
 					break
 				}
 
@@ -218,11 +342,34 @@ func (t *Thread) runStreamedInternal(ctx context.Context, input Input, turnOptio
 					t.setID(event.ThreadID)
 				}
 
-				select {
-				case events <- event:
-				case <-ctx.Done():
-					runErr = ctx.Err()
-					break
+				if err := gate.review(ctx, t.currentID(), event); err != nil {
+					runErr = err
+					aborted = true
+				}
+
+				if event.Type == EventTypeTurnCompleted && event.Usage != nil && t.ledger != nil {
+					if err := t.ledger.Add(t.threadOptions.Model, *event.Usage); err != nil && runErr == nil {
+						runErr = err
+						aborted = true
+					}
+				}
+
+				event, keep, err := applyMiddleware(ctx, chain, event)
+				if err != nil && runErr == nil {
+					runErr = err
+					aborted = true
+				}
+
+				if keep {
+					record.observe(event)
+
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						if runErr == nil {
+							runErr = ctx.Err()
+						}
+					}
 				}
 			}
 
@@ -241,13 +388,20 @@ func (t *Thread) runStreamedInternal(ctx context.Context, input Input, turnOptio
 			}
 		}
 
+		if aborted {
+			// Stop the CLI; its exit status is irrelevant once the turn was aborted.
+			cancelRun()
+		}
+
 		waitErr := stream.Wait()
 		if runErr == nil {
 			runErr = waitErr
-		} else if waitErr != nil && !errors.Is(runErr, waitErr) {
+		} else if waitErr != nil && !aborted && !errors.Is(runErr, waitErr) {
 			runErr = fmt.Errorf("%w; wait error: %v", runErr, waitErr)
 		}
 
+		t.recordTurn(ctx, record, runErr)
+
 		errCh <- runErr
 	}()
 
@@ -261,3 +415,54 @@ func (t *Thread) runStreamedInternal(ctx context.Context, input Input, turnOptio
 		},
 	}, nil
 }
+
+// recordTurn saves a finished turn to the client's thread store, if any.
+// Failures are reported to Options.ThreadStoreErrorHandler rather than
+// returned, so they never change the result of the turn.
+func (t *Thread) recordTurn(ctx context.Context, record TurnRecord, runErr error) {
+	id := t.currentID()
+	if t.options.ThreadStore == nil || id == "" {
+		return
+	}
+
+	record.CompletedAt = time.Now()
+	if record.Error == "" && runErr != nil {
+		record.Error = runErr.Error()
+	}
+	// Record the turn even when it ended because ctx was cancelled.
+	ctx = context.WithoutCancel(ctx)
+	if err := t.options.ThreadStore.recordTurn(ctx, id, t.threadOptions, record); err != nil && t.options.ThreadStoreErrorHandler != nil {
+		t.options.ThreadStoreErrorHandler(ctx, id, err)
+	}
+}
+
+// execArgs assembles the CLI arguments for a single turn from the client,
+// thread and turn options.
+func (t *Thread) execArgs(prompt string, images []string, schemaPath string, turnOptions *TurnOptions) (Args, error) {
+	configOverrides, err := mergeConfigOverrides(t.threadOptions.ConfigOverrides, turnOptions.ConfigOverrides).render()
+	if err != nil {
+		return Args{}, err
+	}
+	enable, disable := t.threadOptions.Features.flags()
+
+	return Args{
+		Input:             prompt,
+		BaseURL:           t.options.BaseURL,
+		APIKey:            t.options.APIKey,
+		ThreadID:          t.currentID(),
+		Images:            images,
+		Model:             t.threadOptions.Model,
+		SandboxMode:       t.threadOptions.SandboxMode,
+		ApprovalMode:      t.threadOptions.ApprovalMode,
+		WorkingDirectory:  t.threadOptions.WorkingDirectory,
+		SkipGitRepoCheck:  t.threadOptions.SkipGitRepoCheck,
+		OutputSchemaFile:  schemaPath,
+		OutputLastMessage: turnOptions.OutputLastMessage,
+		Enable:            enable,
+		Disable:           disable,
+		ConfigOverrides:   configOverrides,
+		ConfigFile:        t.threadOptions.ConfigFile,
+		FullAuto:          t.threadOptions.FullAuto,
+		IncludePlanTool:   t.threadOptions.IncludePlanTool,
+	}, nil
+}
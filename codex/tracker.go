@@ -0,0 +1,325 @@
+package codex
+
+import (
+	"encoding/json"
+	"iter"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ItemDelta describes an incremental change to a thread item, derived by an
+// ItemTracker from the full snapshots carried by item events.
+type ItemDelta interface {
+	// ItemID identifies the item that changed.
+	ItemID() string
+}
+
+// ItemStartedDelta reports the first event seen for an item.
+type ItemStartedDelta struct {
+	// Item is a copy of the item as first reported.
+	Item ThreadItem
+}
+
+// ItemCompletedDelta reports that an item has reached its final state.
+type ItemCompletedDelta struct {
+	// Item is a copy of the completed item.
+	Item ThreadItem
+}
+
+// TextDelta carries text appended to an agent message or reasoning item.
+type TextDelta struct {
+	ID   string
+	Type ItemType
+	// Text is the newly appended text.
+	Text string
+	// Reset is true when the text did not extend the previous snapshot, in which
+	// case Text holds the complete replacement.
+	Reset bool
+}
+
+// CommandOutputDelta carries output a command produced since the previous snapshot.
+type CommandOutputDelta struct {
+	ID      string
+	Command string
+	// Output holds the new output bytes.
+	Output string
+	// Reset is true when the output did not extend the previous snapshot, in
+	// which case Output holds the complete replacement.
+	Reset bool
+}
+
+// TodoCompletedDelta reports a todo list entry that became checked.
+type TodoCompletedDelta struct {
+	ID string
+	// Index is the position of the entry within the list.
+	Index int
+	Item  TodoItem
+}
+
+// FileChangesDelta carries file changes that were not part of the previous snapshot.
+type FileChangesDelta struct {
+	ID      string
+	Changes []FileUpdateChange
+}
+
+func (d ItemStartedDelta) ItemID() string   { return itemID(d.Item) }
+func (d ItemCompletedDelta) ItemID() string { return itemID(d.Item) }
+func (d TextDelta) ItemID() string          { return d.ID }
+func (d CommandOutputDelta) ItemID() string { return d.ID }
+func (d TodoCompletedDelta) ItemID() string { return d.ID }
+func (d FileChangesDelta) ItemID() string   { return d.ID }
+
+// ItemTracker aggregates item.started, item.updated and item.completed events
+// into the current state of every item, and reports what changed between
+// snapshots as typed deltas. It is safe for concurrent use.
+type ItemTracker struct {
+	mu        sync.Mutex
+	items     map[string]ThreadItem
+	order     []string
+	completed map[string]bool
+}
+
+// NewItemTracker creates an empty ItemTracker.
+func NewItemTracker() *ItemTracker {
+	return &ItemTracker{
+		items:     make(map[string]ThreadItem),
+		completed: make(map[string]bool),
+	}
+}
+
+// Apply records an event and returns the deltas it introduced. Events that
+// do not carry an item, or carry an item without an ID, produce no deltas.
+func (t *ItemTracker) Apply(event ThreadEvent) []ItemDelta {
+	switch event.Type {
+	case EventTypeItemStarted, EventTypeItemUpdated, EventTypeItemCompleted:
+	default:
+		return nil
+	}
+	if event.Item == nil {
+		return nil
+	}
+	id := itemID(event.Item)
+	if id == "" {
+		return nil
+	}
+
+	item := cloneItem(event.Item)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous, seen := t.items[id]
+	if !seen {
+		t.order = append(t.order, id)
+	}
+	t.items[id] = item
+
+	var deltas []ItemDelta
+	if !seen {
+		deltas = append(deltas, ItemStartedDelta{Item: cloneItem(item)})
+	}
+	deltas = append(deltas, diffItems(id, previous, item)...)
+	if event.Type == EventTypeItemCompleted && !t.completed[id] {
+		t.completed[id] = true
+		deltas = append(deltas, ItemCompletedDelta{Item: cloneItem(item)})
+	}
+	return deltas
+}
+
+// Item returns a copy of the current state of the item with the given ID.
+func (t *ItemTracker) Item(id string) (ThreadItem, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	item, ok := t.items[id]
+	if !ok {
+		return nil, false
+	}
+	return cloneItem(item), true
+}
+
+// Completed reports whether an item.completed event has been seen for id.
+func (t *ItemTracker) Completed(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.completed[id]
+}
+
+// Snapshot returns copies of all tracked items in the order they were first seen.
+func (t *ItemTracker) Snapshot() []ThreadItem {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	items := make([]ThreadItem, 0, len(t.order))
+	for _, id := range t.order {
+		items = append(items, cloneItem(t.items[id]))
+	}
+	return items
+}
+
+// Track applies every event from events and yields the resulting deltas.
+// Errors from events are passed through unchanged.
+func (t *ItemTracker) Track(events iter.Seq2[*ThreadEvent, error]) iter.Seq2[ItemDelta, error] {
+	return func(yield func(ItemDelta, error) bool) {
+		for event, err := range events {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if event == nil {
+				continue
+			}
+			for _, delta := range t.Apply(*event) {
+				if !yield(delta, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func diffItems(id string, previous, current ThreadItem) []ItemDelta {
+	switch cur := current.(type) {
+	case *AgentMessageItem:
+		prev, _ := previous.(*AgentMessageItem)
+		var before string
+		if prev != nil {
+			before = prev.Text
+		}
+		if text, reset, ok := appended(before, cur.Text); ok {
+			return []ItemDelta{TextDelta{ID: id, Type: ItemTypeAgentMessage, Text: text, Reset: reset}}
+		}
+	case *ReasoningItem:
+		prev, _ := previous.(*ReasoningItem)
+		var before string
+		if prev != nil {
+			before = prev.Text
+		}
+		if text, reset, ok := appended(before, cur.Text); ok {
+			return []ItemDelta{TextDelta{ID: id, Type: ItemTypeReasoning, Text: text, Reset: reset}}
+		}
+	case *CommandExecutionItem:
+		prev, _ := previous.(*CommandExecutionItem)
+		var before string
+		if prev != nil {
+			before = prev.AggregatedOutput
+		}
+		if output, reset, ok := appended(before, cur.AggregatedOutput); ok {
+			return []ItemDelta{CommandOutputDelta{ID: id, Command: cur.Command, Output: output, Reset: reset}}
+		}
+	case *TodoListItem:
+		prev, _ := previous.(*TodoListItem)
+		var deltas []ItemDelta
+		for idx, todo := range cur.Items {
+			if !todo.Completed {
+				continue
+			}
+			if prev != nil && idx < len(prev.Items) && prev.Items[idx].Text == todo.Text && prev.Items[idx].Completed {
+				continue
+			}
+			deltas = append(deltas, TodoCompletedDelta{ID: id, Index: idx, Item: todo})
+		}
+		return deltas
+	case *FileChangeItem:
+		prev, _ := previous.(*FileChangeItem)
+		var added []FileUpdateChange
+		for _, change := range cur.Changes {
+			if prev != nil && slices.Contains(prev.Changes, change) {
+				continue
+			}
+			added = append(added, change)
+		}
+		if len(added) > 0 {
+			return []ItemDelta{FileChangesDelta{ID: id, Changes: added}}
+		}
+	}
+	return nil
+}
+
+// appended returns the text added to before to produce after. When after does
+// not extend before, the whole of after is returned with reset set.
+func appended(before, after string) (text string, reset bool, changed bool) {
+	if before == after {
+		return "", false, false
+	}
+	if rest, ok := strings.CutPrefix(after, before); ok {
+		return rest, false, true
+	}
+	return after, true, true
+}
+
+// itemID returns the ID of a thread item, or "" when it has none.
+func itemID(item ThreadItem) string {
+	switch v := item.(type) {
+	case *AgentMessageItem:
+		return v.ID
+	case *ReasoningItem:
+		return v.ID
+	case *CommandExecutionItem:
+		return v.ID
+	case *FileChangeItem:
+		return v.ID
+	case *McpToolCallItem:
+		return v.ID
+	case *WebSearchItem:
+		return v.ID
+	case *TodoListItem:
+		return v.ID
+	case *ErrorItem:
+		return v.ID
+	case *UnknownThreadItem:
+		var payload struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(v.Raw, &payload) == nil {
+			return payload.ID
+		}
+	}
+	return ""
+}
+
+// cloneItem returns a deep copy of a thread item so that tracked state cannot
+// be modified through values handed to callers.
+func cloneItem(item ThreadItem) ThreadItem {
+	switch v := item.(type) {
+	case *AgentMessageItem:
+		c := *v
+		return &c
+	case *ReasoningItem:
+		c := *v
+		return &c
+	case *CommandExecutionItem:
+		c := *v
+		if v.ExitCode != nil {
+			code := *v.ExitCode
+			c.ExitCode = &code
+		}
+		return &c
+	case *FileChangeItem:
+		c := *v
+		c.Changes = slices.Clone(v.Changes)
+		return &c
+	case *McpToolCallItem:
+		c := *v
+		return &c
+	case *WebSearchItem:
+		c := *v
+		return &c
+	case *TodoListItem:
+		c := *v
+		c.Items = slices.Clone(v.Items)
+		return &c
+	case *ErrorItem:
+		c := *v
+		return &c
+	case *UnknownThreadItem:
+		c := *v
+		c.Raw = slices.Clone(v.Raw)
+		return &c
+	default:
+		return item
+	}
+}
@@ -0,0 +1,79 @@
+package codex
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestItemTracker(t *testing.T) {
+	tracker := NewItemTracker()
+
+	exitCode := 0
+	events := []ThreadEvent{
+		{Type: EventTypeThreadStarted, ThreadID: "thread_1"},
+		{Type: EventTypeItemStarted, Item: &CommandExecutionItem{ID: "cmd_1", Command: "go test", Status: CommandExecutionStatusInProgress}},
+		{Type: EventTypeItemUpdated, Item: &CommandExecutionItem{ID: "cmd_1", Command: "go test", AggregatedOutput: "ok ", Status: CommandExecutionStatusInProgress}},
+		{Type: EventTypeItemUpdated, Item: &CommandExecutionItem{ID: "cmd_1", Command: "go test", AggregatedOutput: "ok pkg\n", Status: CommandExecutionStatusInProgress}},
+		{Type: EventTypeItemCompleted, Item: &CommandExecutionItem{ID: "cmd_1", Command: "go test", AggregatedOutput: "ok pkg\n", ExitCode: &exitCode, Status: CommandExecutionStatusCompleted}},
+		{Type: EventTypeItemStarted, Item: &TodoListItem{ID: "todo_1", Items: []TodoItem{{Text: "read"}, {Text: "write"}}}},
+		{Type: EventTypeItemUpdated, Item: &TodoListItem{ID: "todo_1", Items: []TodoItem{{Text: "read", Completed: true}, {Text: "write"}}}},
+		{Type: EventTypeItemUpdated, Item: &TodoListItem{ID: "todo_1", Items: []TodoItem{{Text: "read", Completed: true}, {Text: "write", Completed: true}}}},
+		{Type: EventTypeItemUpdated, Item: &FileChangeItem{ID: "patch_1", Changes: []FileUpdateChange{{Path: "a.go", Kind: PatchChangeKindUpdate}}}},
+		{Type: EventTypeItemCompleted, Item: &FileChangeItem{ID: "patch_1", Changes: []FileUpdateChange{{Path: "a.go", Kind: PatchChangeKindUpdate}, {Path: "b.go", Kind: PatchChangeKindAdd}}, Status: PatchApplyStatusCompleted}},
+	}
+
+	var deltas []ItemDelta
+	for _, event := range events {
+		deltas = append(deltas, tracker.Apply(event)...)
+	}
+
+	expected := []ItemDelta{
+		ItemStartedDelta{Item: &CommandExecutionItem{ID: "cmd_1", Command: "go test", Status: CommandExecutionStatusInProgress}},
+		CommandOutputDelta{ID: "cmd_1", Command: "go test", Output: "ok "},
+		CommandOutputDelta{ID: "cmd_1", Command: "go test", Output: "pkg\n"},
+		ItemCompletedDelta{Item: &CommandExecutionItem{ID: "cmd_1", Command: "go test", AggregatedOutput: "ok pkg\n", ExitCode: &exitCode, Status: CommandExecutionStatusCompleted}},
+		ItemStartedDelta{Item: &TodoListItem{ID: "todo_1", Items: []TodoItem{{Text: "read"}, {Text: "write"}}}},
+		TodoCompletedDelta{ID: "todo_1", Index: 0, Item: TodoItem{Text: "read", Completed: true}},
+		TodoCompletedDelta{ID: "todo_1", Index: 1, Item: TodoItem{Text: "write", Completed: true}},
+		ItemStartedDelta{Item: &FileChangeItem{ID: "patch_1", Changes: []FileUpdateChange{{Path: "a.go", Kind: PatchChangeKindUpdate}}}},
+		FileChangesDelta{ID: "patch_1", Changes: []FileUpdateChange{{Path: "a.go", Kind: PatchChangeKindUpdate}}},
+		FileChangesDelta{ID: "patch_1", Changes: []FileUpdateChange{{Path: "b.go", Kind: PatchChangeKindAdd}}},
+		ItemCompletedDelta{Item: &FileChangeItem{ID: "patch_1", Changes: []FileUpdateChange{{Path: "a.go", Kind: PatchChangeKindUpdate}, {Path: "b.go", Kind: PatchChangeKindAdd}}, Status: PatchApplyStatusCompleted}},
+	}
+	if !reflect.DeepEqual(expected, deltas) {
+		t.Fatalf("unexpected deltas:\n got: %#v\nwant: %#v", deltas, expected)
+	}
+
+	snapshot := tracker.Snapshot()
+	if len(snapshot) != 3 {
+		t.Fatalf("expected 3 tracked items, got %d", len(snapshot))
+	}
+	if cmd, ok := snapshot[0].(*CommandExecutionItem); !ok || cmd.Status != CommandExecutionStatusCompleted {
+		t.Fatalf("unexpected first snapshot item: %#v", snapshot[0])
+	}
+
+	// Snapshots are copies, so mutating them must not affect tracked state.
+	snapshot[1].(*TodoListItem).Items[0].Text = "changed"
+	item, ok := tracker.Item("todo_1")
+	if !ok || item.(*TodoListItem).Items[0].Text != "read" {
+		t.Fatalf("tracked state was modified through a snapshot: %#v", item)
+	}
+	if !tracker.Completed("cmd_1") || tracker.Completed("todo_1") {
+		t.Fatal("unexpected completion state")
+	}
+}
+
+func TestItemTrackerTextReset(t *testing.T) {
+	tracker := NewItemTracker()
+	tracker.Apply(ThreadEvent{Type: EventTypeItemUpdated, Item: &AgentMessageItem{ID: "msg_1", Text: "Hello"}})
+
+	deltas := tracker.Apply(ThreadEvent{Type: EventTypeItemUpdated, Item: &AgentMessageItem{ID: "msg_1", Text: "Goodbye"}})
+	expected := []ItemDelta{TextDelta{ID: "msg_1", Type: ItemTypeAgentMessage, Text: "Goodbye", Reset: true}}
+	if !reflect.DeepEqual(expected, deltas) {
+		t.Fatalf("unexpected deltas: %#v", deltas)
+	}
+
+	if deltas := tracker.Apply(ThreadEvent{Type: EventTypeItemUpdated, Item: &AgentMessageItem{ID: "msg_1", Text: "Goodbye"}}); len(deltas) != 0 {
+		t.Fatalf("expected no deltas for an unchanged snapshot, got %#v", deltas)
+	}
+}
@@ -0,0 +1,205 @@
+package codex
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ModelPrice is the price of a model in US dollars per million tokens.
+type ModelPrice struct {
+	Input       float64
+	CachedInput float64
+	Output      float64
+}
+
+// PriceTable maps model identifiers to their prices.
+type PriceTable map[string]ModelPrice
+
+// DefaultPriceTable holds published list prices for models commonly used with
+// codex. Prices change over time, so pass an explicit table to
+// NewUsageLedger when exact figures matter.
+var DefaultPriceTable = PriceTable{
+	"gpt-5-codex": {Input: 1.25, CachedInput: 0.125, Output: 10},
+	"gpt-5":       {Input: 1.25, CachedInput: 0.125, Output: 10},
+	"gpt-5-mini":  {Input: 0.25, CachedInput: 0.025, Output: 2},
+	"gpt-5-nano":  {Input: 0.05, CachedInput: 0.005, Output: 0.4},
+	"o4-mini":     {Input: 1.1, CachedInput: 0.275, Output: 4.4},
+}
+
+// Cost estimates the price of usage on model in US dollars. Cached input
+// tokens are a subset of the input tokens and are billed at the cached rate.
+// The second result is false when the model is not in the table.
+func (p PriceTable) Cost(model string, usage Usage) (float64, bool) {
+	price, ok := p[model]
+	if !ok {
+		return 0, false
+	}
+	cached := min(usage.CachedInputTokens, usage.InputTokens)
+	uncached := usage.InputTokens - cached
+	cost := float64(uncached)*price.Input + float64(cached)*price.CachedInput + float64(usage.OutputTokens)*price.Output
+	return cost / 1_000_000, true
+}
+
+// Budget caps the usage recorded by a UsageLedger. Zero values are unlimited.
+//
+// The CLI only reports usage once a turn completes, so a budget can't stop a
+// running turn. It is checked when a turn starts, which fails once the ledger
+// is over budget, and when a turn completes: the turn that crosses the budget
+// is returned from Thread.Run together with a *BudgetExceededError.
+type Budget struct {
+	// MaxTokens caps the sum of input and output tokens.
+	MaxTokens int
+	// MaxCost caps the estimated cost in US dollars. Turns on models missing
+	// from the price table, including the CLI's default model when
+	// ThreadOptions.Model is empty, are rejected while it is set, since their
+	// cost can't be estimated.
+	MaxCost float64
+}
+
+// ErrBudgetExceeded is matched by errors.Is for every *BudgetExceededError.
+var ErrBudgetExceeded = errors.New("usage budget exceeded")
+
+// BudgetExceededError is returned when a turn pushes a UsageLedger past its
+// budget, or when a turn is started on a ledger that is already over budget.
+type BudgetExceededError struct {
+	Budget Budget
+	Totals UsageTotals
+}
+
+func (e *BudgetExceededError) Error() string {
+	if e.Budget.MaxTokens > 0 && e.Totals.Tokens() > e.Budget.MaxTokens {
+		return fmt.Sprintf("%s: used %d of %d tokens", ErrBudgetExceeded, e.Totals.Tokens(), e.Budget.MaxTokens)
+	}
+	return fmt.Sprintf("%s: spent $%.4f of $%.4f", ErrBudgetExceeded, e.Totals.Cost, e.Budget.MaxCost)
+}
+
+// Is reports whether target is ErrBudgetExceeded.
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// UsageTotals is a snapshot of the usage recorded by a UsageLedger.
+type UsageTotals struct {
+	Usage
+	// Cost is the estimated cost in US dollars of all priced usage.
+	Cost float64
+	// Turns counts the turns that reported usage.
+	Turns int
+	// Unpriced is the usage of models missing from the price table.
+	Unpriced Usage
+	// ByModel breaks the usage down per model. Turns run without an explicit
+	// model are recorded under "".
+	ByModel map[string]Usage
+}
+
+// Tokens returns the sum of input and output tokens.
+func (t UsageTotals) Tokens() int {
+	return t.InputTokens + t.OutputTokens
+}
+
+// UsageLedger accumulates token usage and its estimated cost across turns.
+// Every Thread has a ledger, available from Thread.Usage; share one between
+// threads with ThreadOptions.UsageLedger to account for a whole job. It is
+// safe for concurrent use.
+type UsageLedger struct {
+	mu     sync.Mutex
+	prices PriceTable
+	budget Budget
+	totals UsageTotals
+}
+
+// NewUsageLedger creates a ledger that prices usage with prices, or
+// DefaultPriceTable when prices is nil, and enforces budget.
+func NewUsageLedger(prices PriceTable, budget Budget) *UsageLedger {
+	if prices == nil {
+		prices = DefaultPriceTable
+	}
+	return &UsageLedger{
+		prices: prices,
+		budget: budget,
+		totals: UsageTotals{ByModel: make(map[string]Usage)},
+	}
+}
+
+// Add records the usage of a turn run on model. It returns a
+// *BudgetExceededError when the new totals exceed the budget.
+func (l *UsageLedger) Add(model string, usage Usage) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.totals.Usage = addUsage(l.totals.Usage, usage)
+	l.totals.ByModel[model] = addUsage(l.totals.ByModel[model], usage)
+	l.totals.Turns++
+	if cost, ok := l.prices.Cost(model, usage); ok {
+		l.totals.Cost += cost
+	} else {
+		l.totals.Unpriced = addUsage(l.totals.Unpriced, usage)
+	}
+	return l.checkLocked()
+}
+
+// Totals returns a snapshot of the recorded usage.
+func (l *UsageLedger) Totals() UsageTotals {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.snapshotLocked()
+}
+
+// Budget returns the budget enforced by the ledger.
+func (l *UsageLedger) Budget() Budget {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.budget
+}
+
+// Check returns a *BudgetExceededError when the ledger is over budget.
+func (l *UsageLedger) Check() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.checkLocked()
+}
+
+// checkPriced returns an error when the budget caps the cost but model has no
+// price to estimate it with.
+func (l *UsageLedger) checkPriced(model string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.budget.MaxCost <= 0 {
+		return nil
+	}
+	if _, ok := l.prices[model]; !ok {
+		if model == "" {
+			return errors.New("cost budget requires an explicit model with a known price")
+		}
+		return fmt.Errorf("cost budget requires a known price, but model %q is not in the price table", model)
+	}
+	return nil
+}
+
+func (l *UsageLedger) checkLocked() error {
+	overTokens := l.budget.MaxTokens > 0 && l.totals.Tokens() > l.budget.MaxTokens
+	overCost := l.budget.MaxCost > 0 && l.totals.Cost > l.budget.MaxCost
+	if overTokens || overCost {
+		return &BudgetExceededError{Budget: l.budget, Totals: l.snapshotLocked()}
+	}
+	return nil
+}
+
+func (l *UsageLedger) snapshotLocked() UsageTotals {
+	totals := l.totals
+	totals.ByModel = make(map[string]Usage, len(l.totals.ByModel))
+	for model, usage := range l.totals.ByModel {
+		totals.ByModel[model] = usage
+	}
+	return totals
+}
+
+func addUsage(a, b Usage) Usage {
+	return Usage{
+		InputTokens:       a.InputTokens + b.InputTokens,
+		CachedInputTokens: a.CachedInputTokens + b.CachedInputTokens,
+		OutputTokens:      a.OutputTokens + b.OutputTokens,
+	}
+}
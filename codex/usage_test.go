@@ -0,0 +1,101 @@
+package codex
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPriceTableCost(t *testing.T) {
+	prices := PriceTable{"model": {Input: 2, CachedInput: 0.5, Output: 8}}
+
+	cost, ok := prices.Cost("model", Usage{InputTokens: 1_000_000, CachedInputTokens: 400_000, OutputTokens: 500_000})
+	if !ok {
+		t.Fatal("expected model to be priced")
+	}
+	// 600k uncached * $2 + 400k cached * $0.5 + 500k output * $8.
+	if want := 1.2 + 0.2 + 4.0; math.Abs(cost-want) > 1e-9 {
+		t.Fatalf("expected cost %v, got %v", want, cost)
+	}
+
+	if _, ok := prices.Cost("other", Usage{InputTokens: 1}); ok {
+		t.Fatal("expected unknown model to be unpriced")
+	}
+}
+
+func TestUsageLedgerBudget(t *testing.T) {
+	ledger := NewUsageLedger(PriceTable{"model": {Input: 1, Output: 1}}, Budget{MaxTokens: 100})
+
+	if err := ledger.Add("model", Usage{InputTokens: 40, OutputTokens: 20}); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+	if err := ledger.Add("unknown", Usage{InputTokens: 10}); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+
+	err := ledger.Add("model", Usage{InputTokens: 30, OutputTokens: 10})
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) || !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected BudgetExceededError, got %v", err)
+	}
+
+	totals := ledger.Totals()
+	if totals.Tokens() != 110 || totals.Turns != 3 || totals.Unpriced.InputTokens != 10 {
+		t.Fatalf("unexpected totals: %+v", totals)
+	}
+	if totals.ByModel["model"] != (Usage{InputTokens: 70, OutputTokens: 30}) {
+		t.Fatalf("unexpected per-model usage: %+v", totals.ByModel)
+	}
+	if !errors.Is(ledger.Check(), ErrBudgetExceeded) {
+		t.Fatal("expected Check to report the exceeded budget")
+	}
+}
+
+func TestThreadEnforcesBudget(t *testing.T) {
+	// Each turn of transcriptExecutor reports usage costing $0.000104.
+	ledger := NewUsageLedger(PriceTable{"gpt-5-codex": {Input: 10, CachedInput: 1, Output: 20}}, Budget{MaxCost: 0.00015})
+
+	client, err := New(Options{Executor: transcriptExecutor("ok")})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	thread := client.StartThread(ThreadOptions{Model: "gpt-5-codex", UsageLedger: ledger})
+
+	if _, err := thread.RunText(t.Context(), "first", nil); err != nil {
+		t.Fatalf("first turn returned error: %v", err)
+	}
+	turn, err := thread.RunText(t.Context(), "second", nil)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected second turn to exceed the budget, got %v", err)
+	}
+	if turn.FinalResponse != "ok" || turn.Usage == nil {
+		t.Fatalf("expected the completed turn with the budget error, got %+v", turn)
+	}
+	if _, err := thread.RunStreamedText(t.Context(), "third", nil); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected turns to be refused once over budget, got %v", err)
+	}
+
+	if totals := thread.Usage().Totals(); totals.Turns != 2 || totals.InputTokens != 20 {
+		t.Fatalf("unexpected totals: %+v", totals)
+	}
+}
+
+func TestThreadRejectsUnpricedCostBudget(t *testing.T) {
+	ledger := NewUsageLedger(PriceTable{"gpt-5-codex": {Input: 1, Output: 1}}, Budget{MaxCost: 1})
+
+	client, err := New(Options{Executor: transcriptExecutor("ok")})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	for _, model := range []string{"", "local-model"} {
+		thread := client.StartThread(ThreadOptions{Model: model, UsageLedger: ledger})
+		if _, err := thread.RunText(t.Context(), "hi", nil); err == nil || !strings.Contains(err.Error(), "cost budget requires") {
+			t.Fatalf("expected model %q to be rejected, got %v", model, err)
+		}
+	}
+	if totals := ledger.Totals(); totals.Turns != 0 {
+		t.Fatalf("expected no turns to run, got %+v", totals)
+	}
+}
@@ -0,0 +1,613 @@
+package codex
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits of the CLI's strict structured output mode enforced by LintSchema.
+const (
+	maxStrictSchemaDepth      = 10
+	maxStrictSchemaProperties = 5000
+)
+
+// unsupportedStrictKeywords are JSON Schema keywords the CLI's strict mode
+// rejects.
+var unsupportedStrictKeywords = []string{
+	"allOf",
+	"oneOf",
+	"not",
+	"if",
+	"then",
+	"else",
+	"dependentRequired",
+	"dependentSchemas",
+	"patternProperties",
+	"unevaluatedProperties",
+	"unevaluatedItems",
+	"propertyNames",
+	"minProperties",
+	"maxProperties",
+	"contains",
+	"minContains",
+	"maxContains",
+	"uniqueItems",
+}
+
+// SchemaLintError is returned when an output schema uses features the CLI's
+// strict structured output mode does not support.
+type SchemaLintError struct {
+	// Violations lists every problem found, located by their path in the schema.
+	Violations []SchemaViolation
+}
+
+func (e *SchemaLintError) Error() string {
+	return formatViolations("output schema is not supported in strict mode", e.Violations)
+}
+
+// LintSchema checks that schema, which must marshal to a JSON Schema object,
+// only uses features supported by the CLI's strict structured output mode: the
+// root must be an object, every object must list all of its properties as
+// required and set additionalProperties to false, $ref must point into the
+// schema itself, and composition keywords other than anyOf are rejected. It
+// returns a *SchemaLintError describing every problem found. Turns run with an
+// OutputSchema are linted before the CLI is started.
+func LintSchema(schema any) error {
+	root, err := schemaObject(schema)
+	if err != nil {
+		return err
+	}
+
+	l := &schemaLinter{}
+	if types := schemaTypes(root["type"]); !slices.Equal(types, []string{"object"}) {
+		l.report("$", `root schema must have "type": "object"`)
+	}
+	if _, ok := root["anyOf"]; ok {
+		l.report("$", "root schema must not use anyOf")
+	}
+	l.lint(root, "$", 1)
+
+	if l.properties > maxStrictSchemaProperties {
+		l.report("$", fmt.Sprintf("schema has %d properties, more than the %d allowed", l.properties, maxStrictSchemaProperties))
+	}
+	if len(l.violations) > 0 {
+		return &SchemaLintError{Violations: l.violations}
+	}
+	return nil
+}
+
+type schemaLinter struct {
+	violations []SchemaViolation
+	properties int
+}
+
+func (l *schemaLinter) report(path, message string) {
+	l.violations = append(l.violations, SchemaViolation{Path: path, Message: message})
+}
+
+func (l *schemaLinter) lint(schema map[string]any, path string, depth int) {
+	if depth > maxStrictSchemaDepth {
+		l.report(path, fmt.Sprintf("schema is nested more than %d levels deep", maxStrictSchemaDepth))
+		return
+	}
+
+	for _, keyword := range unsupportedStrictKeywords {
+		if _, ok := schema[keyword]; ok {
+			l.report(path, fmt.Sprintf("keyword %q is not supported", keyword))
+		}
+	}
+
+	if ref, ok := schema["$ref"].(string); ok && !strings.HasPrefix(ref, "#") {
+		l.report(path, fmt.Sprintf("$ref %q must point into the schema", ref))
+	}
+
+	properties, hasProperties := schema["properties"].(map[string]any)
+	if hasProperties || slices.Contains(schemaTypes(schema["type"]), "object") {
+		if additional, ok := schema["additionalProperties"].(bool); !ok || additional {
+			l.report(path, `objects must set "additionalProperties": false`)
+		}
+
+		required := make(map[string]bool)
+		if list, ok := schema["required"].([]any); ok {
+			for _, name := range list {
+				if key, ok := name.(string); ok {
+					required[key] = true
+				}
+			}
+		}
+
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		l.properties += len(names)
+		for _, name := range names {
+			if !required[name] {
+				l.report(path, fmt.Sprintf("property %q must be required; make it nullable to mark it optional", name))
+			}
+			if child, ok := properties[name].(map[string]any); ok {
+				l.lint(child, path+".properties."+name, depth+1)
+			}
+		}
+	}
+
+	if items, ok := schema["items"].(map[string]any); ok {
+		l.lint(items, path+".items", depth+1)
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		for idx, branch := range anyOf {
+			if child, ok := branch.(map[string]any); ok {
+				l.lint(child, fmt.Sprintf("%s.anyOf[%d]", path, idx), depth)
+			}
+		}
+	}
+	for _, keyword := range []string{"$defs", "definitions"} {
+		defs, _ := schema[keyword].(map[string]any)
+		names := make([]string, 0, len(defs))
+		for name := range defs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if child, ok := defs[name].(map[string]any); ok {
+				l.lint(child, path+"."+keyword+"."+name, depth)
+			}
+		}
+	}
+}
+
+// ValidateOutput checks that response is a JSON document conforming to
+// schema, and returns every violation found. It supports the subset of JSON
+// Schema draft 2020-12 used for structured output: type, enum, const,
+// properties, required, additionalProperties, patternProperties, min and
+// maxProperties, items, prefixItems, min and maxItems, uniqueItems, string
+// length, pattern and common formats, numeric ranges and multipleOf, anyOf,
+// oneOf, allOf, not, and $ref pointers into the schema. Other keywords are
+// ignored. The error is only non-nil when schema is not a JSON Schema object.
+func ValidateOutput(schema any, response string) ([]SchemaViolation, error) {
+	root, err := schemaObject(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	var document any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(response)), &document); err != nil {
+		return []SchemaViolation{{Path: "$", Message: fmt.Sprintf("response is not valid JSON: %v", err)}}, nil
+	}
+
+	v := &schemaValidator{root: root}
+	v.validate(root, document, "$")
+	return v.violations, nil
+}
+
+// validateAgainstSchema checks a decoded JSON document against a schema value
+// that marshals to a JSON Schema object.
+func validateAgainstSchema(schema any, document any) ([]SchemaViolation, error) {
+	root, err := schemaObject(schema)
+	if err != nil {
+		return nil, err
+	}
+	v := &schemaValidator{root: root}
+	v.validate(root, document, "$")
+	return v.violations, nil
+}
+
+func schemaObject(schema any) (map[string]any, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("encode output schema: %w", err)
+	}
+
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil || root == nil {
+		return nil, fmt.Errorf("output schema must marshal to a JSON object")
+	}
+	return root, nil
+}
+
+// maxRefDepth bounds $ref resolution so recursive schemas cannot loop forever
+// on pathological documents.
+const maxRefDepth = 64
+
+type schemaValidator struct {
+	root       map[string]any
+	violations []SchemaViolation
+	refDepth   int
+}
+
+func (v *schemaValidator) report(path, format string, args ...any) {
+	v.violations = append(v.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// matches reports whether value conforms to schema without recording
+// violations.
+func (v *schemaValidator) matches(schema map[string]any, value any, path string) bool {
+	sub := &schemaValidator{root: v.root, refDepth: v.refDepth}
+	sub.validate(schema, value, path)
+	return len(sub.violations) == 0
+}
+
+func (v *schemaValidator) validate(schema map[string]any, value any, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, ok := resolveSchemaRef(v.root, ref)
+		switch {
+		case !ok:
+			v.report(path, "unresolvable $ref %q", ref)
+		case v.refDepth >= maxRefDepth:
+			v.report(path, "$ref %q nested too deeply", ref)
+		default:
+			v.refDepth++
+			v.validate(target, value, path)
+			v.refDepth--
+		}
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		actual := jsonTypeOf(value)
+		if !typeAllowed(types, actual) {
+			v.report(path, "expected %s, got %s", strings.Join(types, " or "), actual)
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		if !slices.ContainsFunc(enum, func(candidate any) bool { return jsonEqual(candidate, value) }) {
+			v.report(path, "value %s is not one of the allowed enum values", compactJSON(value))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !jsonEqual(constant, value) {
+		v.report(path, "value %s must be %s", compactJSON(value), compactJSON(constant))
+	}
+
+	v.validateComposition(schema, value, path)
+
+	switch value := value.(type) {
+	case map[string]any:
+		v.validateObject(schema, value, path)
+	case []any:
+		v.validateArray(schema, value, path)
+	case string:
+		v.validateString(schema, value, path)
+	case float64:
+		v.validateNumber(schema, value, path)
+	}
+}
+
+func (v *schemaValidator) validateComposition(schema map[string]any, value any, path string) {
+	if anyOf, ok := schemaList(schema["anyOf"]); ok {
+		if !slices.ContainsFunc(anyOf, func(branch map[string]any) bool { return v.matches(branch, value, path) }) {
+			v.report(path, "value does not match any of the anyOf schemas")
+		}
+	}
+	if oneOf, ok := schemaList(schema["oneOf"]); ok {
+		matched := 0
+		for _, branch := range oneOf {
+			if v.matches(branch, value, path) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			v.report(path, "value matches %d of the oneOf schemas, expected exactly 1", matched)
+		}
+	}
+	if allOf, ok := schemaList(schema["allOf"]); ok {
+		for _, branch := range allOf {
+			v.validate(branch, value, path)
+		}
+	}
+	if not, ok := schema["not"].(map[string]any); ok && v.matches(not, value, path) {
+		v.report(path, "value must not match the schema in not")
+	}
+}
+
+func (v *schemaValidator) validateObject(schema map[string]any, object map[string]any, path string) {
+	properties, _ := schema["properties"].(map[string]any)
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			key, _ := name.(string)
+			if _, present := object[key]; !present {
+				v.report(path, "missing required property %q", key)
+			}
+		}
+	}
+	if n, ok := schemaNumber(schema, "minProperties"); ok && float64(len(object)) < n {
+		v.report(path, "expected at least %v properties, got %d", n, len(object))
+	}
+	if n, ok := schemaNumber(schema, "maxProperties"); ok && float64(len(object)) > n {
+		v.report(path, "expected at most %v properties, got %d", n, len(object))
+	}
+
+	patterns, _ := schema["patternProperties"].(map[string]any)
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "." + key
+		matched := false
+		if propSchema, ok := properties[key].(map[string]any); ok {
+			v.validate(propSchema, object[key], childPath)
+			matched = true
+		}
+		for pattern, raw := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil || !re.MatchString(key) {
+				continue
+			}
+			if patternSchema, ok := raw.(map[string]any); ok {
+				v.validate(patternSchema, object[key], childPath)
+			}
+			matched = true
+		}
+		if matched {
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.report(childPath, "unexpected property")
+			}
+		case map[string]any:
+			v.validate(additional, object[key], childPath)
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]any, array []any, path string) {
+	prefix, _ := schemaList(schema["prefixItems"])
+	for idx, item := range array {
+		itemPath := fmt.Sprintf("%s[%d]", path, idx)
+		if idx < len(prefix) {
+			v.validate(prefix[idx], item, itemPath)
+			continue
+		}
+		switch items := schema["items"].(type) {
+		case map[string]any:
+			v.validate(items, item, itemPath)
+		case bool:
+			if !items {
+				v.report(itemPath, "unexpected item")
+			}
+		}
+	}
+
+	if n, ok := schemaNumber(schema, "minItems"); ok && float64(len(array)) < n {
+		v.report(path, "expected at least %v items, got %d", n, len(array))
+	}
+	if n, ok := schemaNumber(schema, "maxItems"); ok && float64(len(array)) > n {
+		v.report(path, "expected at most %v items, got %d", n, len(array))
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range array {
+			for j := i + 1; j < len(array); j++ {
+				if jsonEqual(array[i], array[j]) {
+					v.report(path, "items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateString(schema map[string]any, s, path string) {
+	length := utf8.RuneCountInString(s)
+	if n, ok := schemaNumber(schema, "minLength"); ok && float64(length) < n {
+		v.report(path, "expected at least %v characters, got %d", n, length)
+	}
+	if n, ok := schemaNumber(schema, "maxLength"); ok && float64(length) > n {
+		v.report(path, "expected at most %v characters, got %d", n, length)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		switch {
+		case err != nil:
+			v.report(path, "invalid pattern %q: %v", pattern, err)
+		case !re.MatchString(s):
+			v.report(path, "value %q does not match pattern %q", s, pattern)
+		}
+	}
+	if format, ok := schema["format"].(string); ok && !formatValid(format, s) {
+		v.report(path, "value %q is not a valid %s", s, format)
+	}
+}
+
+func (v *schemaValidator) validateNumber(schema map[string]any, n float64, path string) {
+	value := strconv.FormatFloat(n, 'g', -1, 64)
+	if limit, ok := schemaNumber(schema, "minimum"); ok && n < limit {
+		v.report(path, "value %s is less than the minimum %v", value, limit)
+	}
+	if limit, ok := schemaNumber(schema, "maximum"); ok && n > limit {
+		v.report(path, "value %s is greater than the maximum %v", value, limit)
+	}
+	if limit, ok := schemaNumber(schema, "exclusiveMinimum"); ok && n <= limit {
+		v.report(path, "value %s must be greater than %v", value, limit)
+	}
+	if limit, ok := schemaNumber(schema, "exclusiveMaximum"); ok && n >= limit {
+		v.report(path, "value %s must be less than %v", value, limit)
+	}
+	if divisor, ok := schemaNumber(schema, "multipleOf"); ok && divisor > 0 {
+		if q := n / divisor; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.report(path, "value %s is not a multiple of %v", value, divisor)
+		}
+	}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// formatValid checks the string formats commonly used in structured output.
+// Unknown formats are treated as annotations and always pass.
+func formatValid(format, s string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	case "time":
+		_, err := time.Parse("15:04:05Z07:00", s)
+		return err == nil
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "uuid":
+		return uuidPattern.MatchString(s)
+	case "ipv4":
+		addr, err := netip.ParseAddr(s)
+		return err == nil && addr.Is4()
+	case "ipv6":
+		addr, err := netip.ParseAddr(s)
+		return err == nil && addr.Is6()
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	default:
+		return true
+	}
+}
+
+// resolveSchemaRef resolves a JSON pointer reference such as "#/$defs/item"
+// against the root schema.
+func resolveSchemaRef(root map[string]any, ref string) (map[string]any, bool) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, false
+	}
+	if pointer == "" {
+		return root, true
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, false
+	}
+
+	var current any = root
+	for token := range strings.SplitSeq(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch node := current.(type) {
+		case map[string]any:
+			current, ok = node[token]
+		case []any:
+			idx, err := strconv.Atoi(token)
+			ok = err == nil && idx >= 0 && idx < len(node)
+			if ok {
+				current = node[idx]
+			}
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+
+	schema, ok := current.(map[string]any)
+	return schema, ok
+}
+
+func schemaList(raw any) ([]map[string]any, bool) {
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, false
+	}
+	schemas := make([]map[string]any, 0, len(list))
+	for _, entry := range list {
+		if schema, ok := entry.(map[string]any); ok {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas, true
+}
+
+func schemaNumber(schema map[string]any, keyword string) (float64, bool) {
+	n, ok := schema[keyword].(float64)
+	return n, ok
+}
+
+func schemaTypes(raw any) []string {
+	switch t := raw.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, entry := range t {
+			if s, ok := entry.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	default:
+		return nil
+	}
+}
+
+func jsonTypeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func typeAllowed(types []string, actual string) bool {
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonEqual compares decoded JSON values.
+func jsonEqual(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func compactJSON(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+func formatViolations(prefix string, violations []SchemaViolation) string {
+	switch len(violations) {
+	case 0:
+		return prefix
+	case 1:
+		return prefix + ": " + violations[0].String()
+	default:
+		return fmt.Sprintf("%s: %s (and %d more)", prefix, violations[0], len(violations)-1)
+	}
+}
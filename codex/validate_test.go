@@ -0,0 +1,192 @@
+package codex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func violationStrings(violations []SchemaViolation) []string {
+	lines := make([]string, len(violations))
+	for idx, violation := range violations {
+		lines[idx] = violation.String()
+	}
+	return lines
+}
+
+func TestLintSchema(t *testing.T) {
+	strict, err := SchemaFor[reviewResult]()
+	if err != nil {
+		t.Fatalf("SchemaFor returned error: %v", err)
+	}
+	if err := LintSchema(strict); err != nil {
+		t.Fatalf("expected SchemaFor output to pass linting, got %v", err)
+	}
+
+	loose := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"name": map[string]any{"type": "string"},
+			"tags": map[string]any{
+				"type":        "array",
+				"uniqueItems": true,
+				"items":       map[string]any{"oneOf": []any{map[string]any{"type": "string"}}},
+			},
+			"owner": map[string]any{"$ref": "https://example.com/owner.json"},
+		},
+		"required":             []any{"tags", "owner"},
+		"additionalProperties": false,
+	}
+
+	err = LintSchema(loose)
+	var lintErr *SchemaLintError
+	if !errors.As(err, &lintErr) {
+		t.Fatalf("expected a *SchemaLintError, got %v", err)
+	}
+	want := []string{
+		`$: property "name" must be required; make it nullable to mark it optional`,
+		`$.properties.owner: $ref "https://example.com/owner.json" must point into the schema`,
+		`$.properties.tags: keyword "uniqueItems" is not supported`,
+		`$.properties.tags.items: keyword "oneOf" is not supported`,
+	}
+	if got := violationStrings(lintErr.Violations); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected lint violations:\n%s", strings.Join(got, "\n"))
+	}
+
+	err = LintSchema(map[string]any{"type": "array", "items": map[string]any{"type": "string"}})
+	if !errors.As(err, &lintErr) || lintErr.Violations[0].Message != `root schema must have "type": "object"` {
+		t.Fatalf("expected the root type to be rejected, got %v", err)
+	}
+
+	if err := LintSchema(map[string]any{"type": "object", "properties": map[string]any{}}); err == nil || !strings.Contains(err.Error(), "additionalProperties") {
+		t.Fatalf("expected missing additionalProperties to be rejected, got %v", err)
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id":      map[string]any{"type": "string", "format": "uuid"},
+			"email":   map[string]any{"type": "string", "format": "email"},
+			"name":    map[string]any{"type": "string", "minLength": 2, "pattern": "^[A-Z]"},
+			"score":   map[string]any{"type": "number", "minimum": 0, "exclusiveMaximum": 1},
+			"step":    map[string]any{"type": "integer", "multipleOf": 5},
+			"kind":    map[string]any{"const": "review"},
+			"labels":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "maxItems": 2},
+			"owner":   map[string]any{"$ref": "#/$defs/person"},
+			"contact": map[string]any{"anyOf": []any{map[string]any{"type": "null"}, map[string]any{"$ref": "#/$defs/person"}}},
+		},
+		"required":             []any{"id", "name", "owner"},
+		"additionalProperties": false,
+		"$defs": map[string]any{
+			"person": map[string]any{
+				"type":                 "object",
+				"properties":           map[string]any{"login": map[string]any{"type": "string"}},
+				"required":             []any{"login"},
+				"additionalProperties": false,
+			},
+		},
+	}
+
+	valid := `{"id":"5f0c6b9e-1c1b-4c1a-9a57-2b7f6d0f4c11","email":"dev@example.com","name":"Ada","score":0.5,"step":10,"kind":"review","labels":["a"],"owner":{"login":"ada"},"contact":null}`
+	violations, err := ValidateOutput(schema, valid)
+	if err != nil || len(violations) != 0 {
+		t.Fatalf("expected a valid document, got %v, %v", violations, err)
+	}
+
+	invalid := `{"id":"nope","email":"not an email","name":"a","score":1,"step":7,"kind":"other","labels":["a","b",3],"owner":{},"contact":{"login":1},"extra":true}`
+	violations, err = ValidateOutput(schema, invalid)
+	if err != nil {
+		t.Fatalf("ValidateOutput returned error: %v", err)
+	}
+	want := []string{
+		`$.contact: value does not match any of the anyOf schemas`,
+		`$.email: value "not an email" is not a valid email`,
+		`$.extra: unexpected property`,
+		`$.id: value "nope" is not a valid uuid`,
+		`$.kind: value "other" must be "review"`,
+		`$.labels[2]: expected string, got integer`,
+		`$.labels: expected at most 2 items, got 3`,
+		`$.name: expected at least 2 characters, got 1`,
+		`$.name: value "a" does not match pattern "^[A-Z]"`,
+		`$.owner: missing required property "login"`,
+		`$.score: value 1 must be less than 1`,
+		`$.step: value 7 is not a multiple of 5`,
+	}
+	if got := violationStrings(violations); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected violations:\n%s", strings.Join(got, "\n"))
+	}
+
+	violations, err = ValidateOutput(schema, "not json")
+	if err != nil || len(violations) != 1 || !strings.Contains(violations[0].Message, "not valid JSON") {
+		t.Fatalf("expected a single JSON violation, got %v, %v", violations, err)
+	}
+}
+
+func TestRunRepairsInvalidOutput(t *testing.T) {
+	var runs []Args
+	executor := scriptedExecutor(&runs,
+		[]string{
+			`{"type":"thread.started","thread_id":"thread_1"}`,
+			`{"type":"item.completed","item":{"id":"msg_1","type":"agent_message","text":"{\"summary\":\"ok\"}"}}`,
+			`{"type":"turn.completed","usage":{"input_tokens":5,"cached_input_tokens":0,"output_tokens":1}}`,
+		},
+		[]string{
+			`{"type":"thread.started","thread_id":"thread_1"}`,
+			`{"type":"item.completed","item":{"id":"msg_2","type":"agent_message","text":"{\"summary\":\"ok\",\"approved\":true}"}}`,
+			`{"type":"turn.completed","usage":{"input_tokens":7,"cached_input_tokens":0,"output_tokens":2}}`,
+		},
+	)
+
+	client, err := New(Options{Executor: executor})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	thread := client.StartThread(ThreadOptions{})
+
+	type verdict struct {
+		Summary  string `json:"summary"`
+		Approved bool   `json:"approved"`
+	}
+	value, turn, err := RunTyped[verdict](t.Context(), thread, TextInput("review"), &TurnOptions{RepairAttempts: 1})
+	if err != nil {
+		t.Fatalf("RunTyped returned error: %v", err)
+	}
+
+	if !value.Approved || len(turn.Items) != 2 || turn.Usage == nil || turn.Usage.InputTokens != 12 {
+		t.Fatalf("unexpected repaired result: %+v, %+v", value, turn)
+	}
+	if len(runs) != 2 || runs[1].ThreadID != "thread_1" || !strings.Contains(runs[1].Input, `$: missing required property "approved"`) {
+		t.Fatalf("expected a repair turn on the same thread, got %+v", runs)
+	}
+	if runs[1].OutputSchemaFile == "" {
+		t.Fatal("expected the repair turn to request structured output")
+	}
+}
+
+func TestRunValidateOutput(t *testing.T) {
+	executor := NewReaderExecutor(strings.NewReader(strings.Join([]string{
+		`{"type":"thread.started","thread_id":"thread_1"}`,
+		`{"type":"item.completed","item":{"id":"msg_1","type":"agent_message","text":"[]"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`,
+	}, "\n")))
+
+	client, err := New(Options{Executor: executor})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	schema, err := SchemaFor[reviewFinding]()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	turn, err := client.StartThread(ThreadOptions{}).RunText(t.Context(), "review", &TurnOptions{OutputSchema: schema, ValidateOutput: true})
+	var validationErr *OutputValidationError
+	if !errors.As(err, &validationErr) || validationErr.Response != "[]" || validationErr.Violations[0].String() != "$: expected object, got array" {
+		t.Fatalf("expected an *OutputValidationError, got %v", err)
+	}
+	if turn.FinalResponse != "[]" || len(turn.Items) != 1 || turn.Usage == nil {
+		t.Fatalf("expected the turn with the validation error, got %+v", turn)
+	}
+}
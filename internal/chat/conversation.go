@@ -0,0 +1,378 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/openai/openai-go"
+	"github.com/picatz/openai/internal/chat/storage"
+	"github.com/segmentio/ksuid"
+)
+
+// DefaultConversation is the name of the conversation used when none is given.
+const DefaultConversation = "default"
+
+// conversationsPrefix is the storage key prefix shared by all conversations.
+//
+// The entries of a conversation are stored under its own prefix (see
+// conversationPrefix), where the systemPromptKey entry holds its system
+// prompt as the Req of a ReqRespPair, and the entries under pairsPrefix hold
+// its request-response pairs. Summaries are stored with the pairs, at the
+// point in the conversation where they were made, as a pair with a system
+// message for its Req and no Resp.
+const conversationsPrefix = "conversations/"
+
+const (
+	systemPromptKey = "system"
+	pairsPrefix     = "pairs/"
+)
+
+// conversationPrefix returns the storage key prefix of the named conversation.
+func conversationPrefix(name string) string {
+	return conversationsPrefix + name + "/"
+}
+
+// validateConversationName checks that name can be used as a conversation name.
+func validateConversationName(name string) error {
+	if name == "" || strings.ContainsAny(name, "/ \t\r\n") {
+		return fmt.Errorf("invalid conversation name %q: must not be empty or contain slashes or spaces", name)
+	}
+	return nil
+}
+
+// conversationCommand manages the named conversations of the session.
+var conversationCommand = Command{
+	Name:        "conversation",
+	Description: "Show the current conversation, or manage conversations with 'new <name>', 'switch <name>', 'rename [<old>] <new>', and 'delete <name>'.",
+	Matches: func(input string) bool {
+		input = strings.TrimSpace(input)
+		return input == "conversation" || strings.HasPrefix(input, "conversation ")
+	},
+	Run: func(ctx context.Context, s *Session, input string) {
+		args := strings.Fields(input)[1:]
+		if len(args) == 0 {
+			s.OutWriter.WriteString(fmt.Sprintf("Current conversation: %s\n", s.Conversation))
+			return
+		}
+
+		var err error
+		switch {
+		case args[0] == "new" && len(args) == 2:
+			err = s.NewConversation(ctx, args[1])
+		case args[0] == "switch" && len(args) == 2:
+			err = s.SwitchConversation(ctx, args[1])
+		case args[0] == "rename" && len(args) == 2:
+			err = s.RenameConversation(ctx, s.Conversation, args[1])
+		case args[0] == "rename" && len(args) == 3:
+			err = s.RenameConversation(ctx, args[1], args[2])
+		case args[0] == "delete" && len(args) == 2:
+			// Prompt the user for confirmation before deleting the conversation.
+			s.OutWriter.WriteString(fmt.Sprintf("\nAre you sure you want to delete the %q conversation? (y/n): ", args[1]))
+			s.OutWriter.Flush()
+
+			confirmation, readErr := s.Terminal.ReadLine()
+			if readErr != nil {
+				s.OutWriter.WriteString(fmt.Sprintf("Error reading confirmation: %s\n", readErr))
+				return
+			}
+
+			if strings.ToLower(strings.TrimSpace(confirmation)) != "y" {
+				s.OutWriter.WriteString("\nConversation not deleted.\n")
+				return
+			}
+			err = s.DeleteConversation(ctx, args[1])
+		default:
+			err = fmt.Errorf("unknown conversation command %q", strings.Join(args, " "))
+		}
+		if err != nil {
+			s.OutWriter.WriteString(fmt.Sprintf("Error: %s\n", err))
+			return
+		}
+
+		s.OutWriter.WriteString(fmt.Sprintf("Current conversation: %s\n", s.Conversation))
+	},
+}
+
+// conversationsCommand lists the named conversations of the session.
+var conversationsCommand = Command{
+	Name:        "conversations",
+	Description: "List the conversations in the backend storage.",
+	Run: func(ctx context.Context, s *Session, input string) {
+		names, err := s.Conversations(ctx)
+		if err != nil {
+			s.OutWriter.WriteString(fmt.Sprintf("Error listing conversations: %s\n", err))
+			return
+		}
+
+		for _, name := range names {
+			if name == s.Conversation {
+				s.OutWriter.WriteString("* " + lipgloss.NewStyle().Bold(true).Render(name) + "\n")
+				continue
+			}
+			s.OutWriter.WriteString("  " + name + "\n")
+		}
+	},
+}
+
+// Conversations returns the names of the conversations in the backend storage, sorted.
+func (cs *Session) Conversations(ctx context.Context) ([]string, error) {
+	entries, _, err := storage.NewPrefixed(cs.backend, conversationsPrefix).List(ctx, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+
+	var names []string
+	for key := range entries {
+		name, _, _ := strings.Cut(key, "/")
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// NewConversation creates a new, empty conversation and switches to it.
+func (cs *Session) NewConversation(ctx context.Context, name string) error {
+	if err := validateConversationName(name); err != nil {
+		return err
+	}
+
+	names, err := cs.Conversations(ctx)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(names, name) {
+		return fmt.Errorf("conversation %q already exists", name)
+	}
+
+	return cs.openConversation(ctx, name)
+}
+
+// SwitchConversation switches to an existing conversation, loading its history.
+func (cs *Session) SwitchConversation(ctx context.Context, name string) error {
+	names, err := cs.Conversations(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(names, name) {
+		return fmt.Errorf("conversation %q does not exist", name)
+	}
+
+	if err := cs.saveCache(ctx); err != nil {
+		return err
+	}
+
+	return cs.openConversation(ctx, name)
+}
+
+// RenameConversation renames a conversation, moving all of its entries in
+// the backend storage.
+func (cs *Session) RenameConversation(ctx context.Context, oldName, newName string) error {
+	if err := validateConversationName(newName); err != nil {
+		return err
+	}
+
+	names, err := cs.Conversations(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(names, oldName) {
+		return fmt.Errorf("conversation %q does not exist", oldName)
+	}
+	if slices.Contains(names, newName) {
+		return fmt.Errorf("conversation %q already exists", newName)
+	}
+
+	var (
+		from = storage.NewPrefixed(cs.backend, conversationPrefix(oldName))
+		to   = storage.NewPrefixed(cs.backend, conversationPrefix(newName))
+	)
+
+	entries, _, err := from.List(ctx, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list conversation %q: %w", oldName, err)
+	}
+	for key, value := range entries {
+		if err := to.Set(ctx, key, value); err != nil {
+			return fmt.Errorf("failed to copy conversation entry %q: %w", key, err)
+		}
+		if err := from.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete conversation entry %q: %w", key, err)
+		}
+	}
+
+	if cs.Conversation == oldName {
+		cs.Conversation = newName
+		cs.StorageBackend = storage.NewPrefixed(cs.backend, conversationPrefix(newName)+pairsPrefix)
+	}
+
+	return cs.saveCache(ctx)
+}
+
+// DeleteConversation deletes a conversation and all of its entries from the
+// backend storage. The current conversation cannot be deleted.
+func (cs *Session) DeleteConversation(ctx context.Context, name string) error {
+	if name == cs.Conversation {
+		return fmt.Errorf("cannot delete the current conversation %q, switch to another conversation first", name)
+	}
+
+	conversation := storage.NewPrefixed(cs.backend, conversationPrefix(name))
+
+	entries, _, err := conversation.List(ctx, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list conversation %q: %w", name, err)
+	}
+
+	var deleted int
+	for key := range entries {
+		if err := conversation.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete conversation entry %q: %w", key, err)
+		}
+		deleted++
+	}
+	if deleted == 0 {
+		return fmt.Errorf("conversation %q does not exist", name)
+	}
+
+	return cs.saveCache(ctx)
+}
+
+// SetSystemPrompt sets the system prompt of the current conversation, which
+// is kept as its first message, including after it is summarized.
+func (cs *Session) SetSystemPrompt(ctx context.Context, prompt string) error {
+	conversation := storage.NewPrefixed(cs.backend, conversationPrefix(cs.Conversation))
+	if err := conversation.Set(ctx, systemPromptKey, ReqRespPair{
+		Model: cs.ChatModel,
+		Req: openai.ChatCompletionMessage{
+			Role:    "system",
+			Content: prompt,
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to save system prompt: %w", err)
+	}
+
+	if cs.SystemPrompt != "" && len(cs.Messages) > 0 && cs.Messages[0].Role == "system" && cs.Messages[0].Content == cs.SystemPrompt {
+		cs.Messages = cs.Messages[1:]
+	}
+	if prompt != "" {
+		cs.Messages = append([]openai.ChatCompletionMessage{{Role: "system", Content: prompt}}, cs.Messages...)
+	}
+	cs.SystemPrompt = prompt
+
+	return nil
+}
+
+// openConversation makes name the current conversation, creating it if it
+// doesn't exist, and loads its system prompt and history.
+func (cs *Session) openConversation(ctx context.Context, name string) error {
+	if err := validateConversationName(name); err != nil {
+		return err
+	}
+
+	conversation := storage.NewPrefixed(cs.backend, conversationPrefix(name))
+
+	system, found, err := conversation.Get(ctx, systemPromptKey)
+	if err != nil {
+		return fmt.Errorf("failed to get system prompt of conversation %q: %w", name, err)
+	}
+
+	// The system prompt entry also marks that the conversation exists,
+	// so it is written even if the prompt is empty.
+	if !found {
+		system = ReqRespPair{
+			Model: cs.ChatModel,
+			Req:   openai.ChatCompletionMessage{Role: "system"},
+		}
+		if err := conversation.Set(ctx, systemPromptKey, system); err != nil {
+			return fmt.Errorf("failed to create conversation %q: %w", name, err)
+		}
+	}
+
+	cs.Conversation = name
+	cs.SystemPrompt = system.Req.Content
+	cs.StorageBackend = storage.NewPrefixed(cs.backend, conversationPrefix(name)+pairsPrefix)
+	cs.resetMessages()
+
+	return cs.loadCache(ctx)
+}
+
+// resetMessages clears the messages sent to the model, keeping only the
+// conversation's system prompt.
+func (cs *Session) resetMessages() {
+	cs.Messages = []openai.ChatCompletionMessage{}
+	if cs.SystemPrompt != "" {
+		cs.Messages = append(cs.Messages, openai.ChatCompletionMessage{
+			Role:    "system",
+			Content: cs.SystemPrompt,
+		})
+	}
+}
+
+// saveSummary stores a summary of the conversation so far with its history,
+// so it replaces the messages before it when the conversation is loaded.
+func (cs *Session) saveSummary(ctx context.Context, summary openai.ChatCompletionMessage, tokens int64) error {
+	if err := cs.StorageBackend.Set(ctx, fmt.Sprintf("%s-summary", ksuid.New()), ReqRespPair{
+		Model:     cs.ChatModel,
+		Req:       summary,
+		ReqTokens: tokens,
+	}); err != nil {
+		return fmt.Errorf("failed to save summary to backend storage: %w", err)
+	}
+	return nil
+}
+
+// migrateLegacyHistory moves chat history stored before conversations were
+// named into the default conversation.
+//
+// This runs for every new session, so the conversations are skipped rather
+// than listed: legacy entries sort either before or after all of them.
+func (cs *Session) migrateLegacyHistory(ctx context.Context) error {
+	var (
+		pairs = storage.NewPrefixed(cs.backend, conversationPrefix(DefaultConversation)+pairsPrefix)
+
+		// conversationsEnd sorts right after every key under
+		// conversationsPrefix, as "0" follows its trailing "/".
+		conversationsEnd = strings.TrimSuffix(conversationsPrefix, "/") + "0"
+
+		pageToken *string
+		skipped   bool
+	)
+	for {
+		entries, next, err := cs.backend.List(ctx, storage.PageSize(100), pageToken)
+		if err != nil {
+			return fmt.Errorf("failed to list chat history: %w", err)
+		}
+
+		reached := false
+		for key, value := range entries {
+			if strings.HasPrefix(key, conversationsPrefix) {
+				if skipped {
+					continue
+				}
+				reached = true
+				break
+			}
+			if err := pairs.Set(ctx, key, value); err != nil {
+				return fmt.Errorf("failed to migrate chat history entry %q: %w", key, err)
+			}
+			if err := cs.backend.Delete(ctx, key); err != nil {
+				return fmt.Errorf("failed to delete migrated chat history entry %q: %w", key, err)
+			}
+		}
+
+		switch {
+		case reached:
+			skipped = true
+			pageToken = storage.PageToken(conversationsEnd)
+		case next == nil:
+			return nil
+		default:
+			pageToken = next
+		}
+	}
+}
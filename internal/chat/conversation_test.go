@@ -0,0 +1,110 @@
+package chat_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/picatz/openai/internal/chat"
+	"github.com/picatz/openai/internal/chat/storage/memory"
+	"github.com/picatz/openai/internal/chat/tokenizer"
+	"github.com/shoenig/test/must"
+)
+
+func TestSession_conversations(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, streamChunk("ok"))
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	client := openai.NewClient(option.WithBaseURL(ts.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+
+	// History stored before conversations were named is moved into the
+	// default conversation.
+	backend := memory.NewBackend[string, chat.ReqRespPair]()
+	must.NoError(t, backend.Set(t.Context(), "legacy-chatcmpl-0", chat.ReqRespPair{
+		Req:  openai.ChatCompletionMessage{Role: "user", Content: "old question"},
+		Resp: openai.ChatCompletionMessage{Role: "assistant", Content: "old answer"},
+	}))
+
+	chatSession, restore, err := chat.NewSession(t.Context(), &client, openai.ChatModelGPT4o, "", strings.NewReader("work question\r\n"), io.Discard, backend, tokenizer.Estimate{})
+	must.NoError(t, err)
+	t.Cleanup(restore)
+
+	must.Eq(t, chat.DefaultConversation, chatSession.Conversation)
+	must.Len(t, 2, chatSession.Messages)
+	must.Eq(t, "old question", chatSession.Messages[0].Content)
+
+	_, found, err := backend.Get(t.Context(), "legacy-chatcmpl-0")
+	must.NoError(t, err)
+	must.False(t, found)
+
+	// Each conversation has its own system prompt and history.
+	must.NoError(t, chatSession.NewConversation(t.Context(), "work"))
+	must.Len(t, 0, chatSession.Messages)
+	must.NoError(t, chatSession.SetSystemPrompt(t.Context(), "You are a code reviewer."))
+
+	_, err = chatSession.RunOnce(t.Context())
+	must.NoError(t, err)
+	must.Len(t, 3, chatSession.Messages)
+
+	must.Error(t, chatSession.NewConversation(t.Context(), "work"))
+	must.Error(t, chatSession.NewConversation(t.Context(), "bad/name"))
+
+	must.NoError(t, chatSession.SwitchConversation(t.Context(), chat.DefaultConversation))
+	must.Eq(t, "", chatSession.SystemPrompt)
+	must.Len(t, 2, chatSession.Messages)
+	must.Eq(t, "old answer", chatSession.Messages[1].Content)
+
+	must.NoError(t, chatSession.RenameConversation(t.Context(), "work", "review"))
+	must.Error(t, chatSession.SwitchConversation(t.Context(), "work"))
+
+	names, err := chatSession.Conversations(t.Context())
+	must.NoError(t, err)
+	must.Eq(t, []string{chat.DefaultConversation, "review"}, names)
+
+	must.NoError(t, chatSession.SwitchConversation(t.Context(), "review"))
+	must.Eq(t, "You are a code reviewer.", chatSession.SystemPrompt)
+	must.Len(t, 3, chatSession.Messages)
+	must.Eq(t, "system", string(chatSession.Messages[0].Role))
+	must.Eq(t, "work question", chatSession.Messages[1].Content)
+
+	// The current conversation can't be deleted.
+	must.Error(t, chatSession.DeleteConversation(t.Context(), "review"))
+	must.NoError(t, chatSession.DeleteConversation(t.Context(), chat.DefaultConversation))
+	must.Error(t, chatSession.DeleteConversation(t.Context(), chat.DefaultConversation))
+
+	names, err = chatSession.Conversations(t.Context())
+	must.NoError(t, err)
+	must.Eq(t, []string{"review"}, names)
+}
+
+func TestSession_migratesLegacyHistoryAroundConversations(t *testing.T) {
+	backend := memory.NewBackend[string, chat.ReqRespPair]()
+	for _, key := range []string{"0legacy-chatcmpl-0", "conversations/work/pairs/0-chatcmpl-1", "legacy-chatcmpl-2"} {
+		must.NoError(t, backend.Set(t.Context(), key, chat.ReqRespPair{
+			Req: openai.ChatCompletionMessage{Role: "user", Content: key},
+		}))
+	}
+
+	client := openai.NewClient(option.WithAPIKey("test"))
+	chatSession, restore, err := chat.NewSession(t.Context(), &client, openai.ChatModelGPT4o, "", strings.NewReader(""), io.Discard, backend, tokenizer.Estimate{})
+	must.NoError(t, err)
+	t.Cleanup(restore)
+
+	// Legacy entries sorting before and after the conversations are moved
+	// into the default conversation, and the conversations are left alone.
+	must.Len(t, 4, chatSession.Messages)
+	must.Eq(t, "0legacy-chatcmpl-0", chatSession.Messages[0].Content)
+	must.Eq(t, "legacy-chatcmpl-2", chatSession.Messages[2].Content)
+
+	_, found, err := backend.Get(t.Context(), "conversations/work/pairs/0-chatcmpl-1")
+	must.NoError(t, err)
+	must.True(t, found)
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/openai/openai-go"
 	"github.com/picatz/openai/internal/chat/storage"
+	"github.com/picatz/openai/internal/chat/tokenizer"
 	"github.com/segmentio/ksuid"
 	"golang.org/x/term"
 )
@@ -31,16 +33,23 @@ import (
 var DefaultCachePath = cmp.Or(os.Getenv("HOME"), os.Getenv("USERPROFILE")) + "/.openai-cli-chat-pebble-storage-cache"
 
 // newMessageUnion converts a slice of ChatCompletionMessage into the expected union slice.
+//
+// Tool results are expected to use the "tool" role, with the call they answer
+// as their only tool call (see toolMessage).
 func newMessageUnion(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessageParamUnion {
 	msgUnion := make([]openai.ChatCompletionMessageParamUnion, len(messages))
 	for i, m := range messages {
-		switch m.Role {
-		case "system":
+		switch {
+		case m.Role == "system":
 			msgUnion[i] = openai.SystemMessage(m.Content)
-		case "user":
+		case m.Role == "user":
 			msgUnion[i] = openai.UserMessage(m.Content)
-		case "assistant":
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			msgUnion[i] = m.ToParam()
+		case m.Role == "assistant":
 			msgUnion[i] = openai.AssistantMessage(m.Content)
+		case m.Role == "tool" && len(m.ToolCalls) > 0:
+			msgUnion[i] = openai.ToolMessage(m.Content, m.ToolCalls[0].ID)
 		default:
 			// Fallback to user message if role is unknown
 			msgUnion[i] = openai.UserMessage(m.Content)
@@ -91,14 +100,14 @@ var builtinCommands = []Command{
 		Name:        "erase",
 		Description: "Clear the chat history.",
 		Run: func(ctx context.Context, s *Session, input string) {
-			s.Messages = []openai.ChatCompletionMessage{}
-			s.CurrentTokensUsed = 0
+			s.resetMessages()
+			s.CurrentTokensUsed = s.countTokens(s.Messages)
 			s.OutWriter.WriteString("Chat history cleared.\n")
 		},
 	},
 	{
 		Name:        "erase all",
-		Description: "Clear the chat history and backend storage.",
+		Description: "Clear the chat history and the conversation's backend storage.",
 		Run: func(ctx context.Context, s *Session, input string) {
 			// Prompt the user for confirmation before clearing the chat history.
 			s.OutWriter.WriteString("\nAre you sure you want to clear the chat history? (y/n): ")
@@ -115,8 +124,8 @@ var builtinCommands = []Command{
 				return
 			}
 
-			s.Messages = []openai.ChatCompletionMessage{}
-			s.CurrentTokensUsed = 0
+			s.resetMessages()
+			s.CurrentTokensUsed = s.countTokens(s.Messages)
 
 			var (
 				perPage       = storage.PageSize(10)
@@ -171,16 +180,16 @@ var builtinCommands = []Command{
 	},
 	{
 		Name:        "system",
-		Description: "Set the system context.",
+		Description: "Set the system context of the conversation.",
 		Matches: func(input string) bool {
 			return strings.HasPrefix(strings.TrimSpace(input), "system:")
 		},
 		Run: func(ctx context.Context, s *Session, input string) {
-			systemMsg := openai.ChatCompletionMessage{
-				Role:    "system",
-				Content: input,
+			prompt := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), "system:"))
+			if err := s.SetSystemPrompt(ctx, prompt); err != nil {
+				s.OutWriter.WriteString(fmt.Sprintf("Error: %s\n", err))
+				return
 			}
-			s.Messages = append(s.Messages, systemMsg)
 			s.OutWriter.WriteString("System context updated.\n")
 		},
 	},
@@ -191,11 +200,22 @@ var builtinCommands = []Command{
 			s.ShowHelp()
 		},
 	},
+	conversationCommand,
+	conversationsCommand,
+	{
+		Name:        "tools",
+		Description: "Show the tools available to the model.",
+		Run: func(ctx context.Context, s *Session, input string) {
+			for _, tool := range s.Tools {
+				s.OutWriter.WriteString("- " + lipgloss.NewStyle().Faint(true).Render(tool.Name) + ": " + tool.Description + "\n")
+			}
+		},
+	},
 	{
 		Name:        "tokens",
-		Description: "Show the number of tokens used.",
+		Description: "Show the number of tokens in the conversation's context.",
 		Run: func(ctx context.Context, s *Session, input string) {
-			s.OutWriter.WriteString(fmt.Sprintf("Tokens used: %d\n", s.CurrentTokensUsed))
+			s.OutWriter.WriteString(fmt.Sprintf("Tokens used: %d of %d\n", s.CurrentTokensUsed, s.contextWindow()))
 		},
 	},
 	{
@@ -226,7 +246,7 @@ var builtinCommands = []Command{
 				return false
 			}
 		},
-		Description: "Show the chat message history from the backend storage.",
+		Description: "Show the conversation's most recent message history from the backend storage.",
 		Run: func(ctx context.Context, s *Session, input string) {
 			// Default to showing the last 10 messages if no number is provided.
 			numToShow := 10
@@ -245,13 +265,19 @@ var builtinCommands = []Command{
 				return
 			}
 
-			entries, _, err := s.StorageBackend.List(ctx, storage.PageSize(numToShow), nil)
+			entries, err := s.recentHistory(ctx, numToShow)
 			if err != nil {
 				s.OutWriter.WriteString(fmt.Sprintf("Error listing entries: %s\n", err))
 				return
 			}
 
-			for key, value := range entries {
+			for _, entry := range entries {
+				key, value := entry.Key, entry.Value
+				if value.Req.Role == "system" {
+					s.OutWriter.WriteString(fmt.Sprintf("\tsummary (%s): %s\n\n", key, value.Req.Content))
+					s.OutWriter.WriteString("---\n")
+					continue
+				}
 				s.OutWriter.WriteString(fmt.Sprintf("\t%s (%s): %s\n\n", value.Req.Role, key, value.Req.Content))
 				s.OutWriter.WriteString(fmt.Sprintf("\t%s (%s): %s\n\n", value.Resp.Role, key, value.Resp.Content))
 				s.OutWriter.WriteString(fmt.Sprintf("\tTokens used: %d\n\n", value.ReqTokens+value.RespTokens))
@@ -263,21 +289,31 @@ var builtinCommands = []Command{
 
 // ReqRespPair represents a request-response pair in the chat session,
 // used for storing conversation history in the backend.
+//
+// ToolMessages holds the tool calls made while answering the request, and
+// their results, in the order they were sent between Req and Resp.
 type ReqRespPair struct {
-	Model      string                       `json:"model,omitzero"`
-	Req        openai.ChatCompletionMessage `json:"req,omitzero"`
-	ReqTokens  int64                        `json:"req_tokens,omitzero"`
-	Resp       openai.ChatCompletionMessage `json:"resp,omitzero"`
-	RespTokens int64                        `json:"resp_tokens,omitzero"`
+	Model        string                         `json:"model,omitzero"`
+	Req          openai.ChatCompletionMessage   `json:"req,omitzero"`
+	ReqTokens    int64                          `json:"req_tokens,omitzero"`
+	ToolMessages []openai.ChatCompletionMessage `json:"tool_messages,omitzero"`
+	Resp         openai.ChatCompletionMessage   `json:"resp,omitzero"`
+	RespTokens   int64                          `json:"resp_tokens,omitzero"`
 }
 
 // Session encapsulates the state and behavior of a CLI chat session.
 // It manages terminal I/O, conversation history, caching, and command processing.
+//
+// The history of each named conversation is kept separately in the backend
+// storage, and StorageBackend holds the history of the current conversation.
 type Session struct {
 	Client                     *openai.Client
 	ChatModel                  string
+	Conversation               string
+	SystemPrompt               string
 	StorageBackend             storage.Backend[string, ReqRespPair]
 	Messages                   []openai.ChatCompletionMessage
+	Tokenizer                  tokenizer.Counter
 	CurrentTokensUsed          int64
 	SummarizeContextWindowSize int64
 
@@ -286,15 +322,36 @@ type Session struct {
 	TermWidth  int
 	TermHeight int
 	Commands   []Command
+	Tools      []Tool
+
+	// input is the terminal's reader, watched for Ctrl-C while a
+	// response is streaming.
+	input *interruptReader
+
+	// tokenizerWarned is set once the user is told that token counts are
+	// estimated.
+	tokenizerWarned bool
+
+	// approvedHosts are the hosts the user allowed the fetch_url tool to
+	// fetch from.
+	approvedHosts map[string]bool
+
+	// backend is the storage backend shared by all conversations.
+	backend storage.Backend[string, ReqRespPair]
 }
 
 // NewSession creates and initializes a new chat session.
 //
-// It sets the terminal to raw mode, loads any existing chat history,
-// and registers the default commands.
+// It sets the terminal to raw mode, loads any existing chat history of the
+// named conversation (or DefaultConversation, if empty), creating it if
+// needed, and registers the default commands.
+//
+// Tokens are counted with counter. If it is nil, the encoding of the chat
+// model is loaded in the background, which downloads it on first use (see
+// tokenizer.Get), and tokens are estimated until it is loaded.
 //
 // A restoration function is returned to restore the terminal state on exit.
-func NewSession(ctx context.Context, client *openai.Client, chatModel string, r io.Reader, w io.Writer, b storage.Backend[string, ReqRespPair]) (*Session, func(), error) {
+func NewSession(ctx context.Context, client *openai.Client, chatModel, conversation string, r io.Reader, w io.Writer, b storage.Backend[string, ReqRespPair], counter tokenizer.Counter) (*Session, func(), error) {
 	var (
 		restoreFunc     = func() {} // Default no-op restore function.
 		termWidth   int = 80        // Terminal width (default 80).
@@ -332,11 +389,19 @@ func NewSession(ctx context.Context, client *openai.Client, chatModel string, r
 		}
 	}
 
+	// Load the encoding of the chat model, unless tokens are counted some other way.
+	if counter == nil {
+		counter = tokenizer.LoadInBackground(ctx, tokenizer.EncodingForModel(chatModel), tokenizerLoadTimeout)
+	}
+
+	// Wrap the reader so that Ctrl-C can interrupt a streaming response.
+	input := newInterruptReader(r)
+
 	// Combine the reader and writer into a single io.ReadWriter.
 	termReadWriter := struct {
 		io.Reader
 		io.Writer
-	}{r, w}
+	}{input, w}
 
 	// Create a new terminal instance.
 	t := term.NewTerminal(termReadWriter, "")
@@ -351,21 +416,30 @@ func NewSession(ctx context.Context, client *openai.Client, chatModel string, r
 	cs := &Session{
 		Client:            client,
 		ChatModel:         chatModel,
-		StorageBackend:    b,
 		Messages:          []openai.ChatCompletionMessage{},
+		Tokenizer:         counter,
 		CurrentTokensUsed: 0,
 		Terminal:          t,
 		OutWriter:         outWriter,
 		TermWidth:         termWidth,
 		TermHeight:        termHeight,
 		Commands:          builtinCommands,
+		Tools:             builtinTools,
+		input:             input,
+		backend:           b,
 	}
 
 	// Set up tab-completion for common commands.
 	t.AutoCompleteCallback = cs.autoComplete
 
-	// Load any existing chat history from the cache.
-	if err := cs.loadCache(ctx); err != nil {
+	// Move chat history from before conversations were named into the default one.
+	if err := cs.migrateLegacyHistory(ctx); err != nil {
+		restoreFunc()
+		return nil, nil, fmt.Errorf("failed to migrate chat history: %w", err)
+	}
+
+	// Load any existing chat history of the conversation from the cache.
+	if err := cs.openConversation(ctx, cmp.Or(conversation, DefaultConversation)); err != nil {
 		restoreFunc()
 		return nil, nil, fmt.Errorf("failed to load chat history: %w", err)
 	}
@@ -445,6 +519,8 @@ func ranSuccessfully() (bool, error) {
 }
 
 func (cs *Session) RunOnce(ctx context.Context) (bool, error) {
+	cs.warnTokenizerFallback()
+
 	cs.OutWriter.WriteString("‣ ")
 	cs.OutWriter.Flush()
 
@@ -473,16 +549,16 @@ func (cs *Session) RunOnce(ctx context.Context) (bool, error) {
 		Content: *processedInput,
 	}
 
+	// Summarize the conversation first if the message wouldn't fit in the context window.
+	if err := cs.maybeSummarize(ctx, nextUserMessage); err != nil {
+		return nonFatalError(fmt.Errorf("summarization error: %w", err))
+	}
+
 	// Send the chat request and display the bot's response, storing the conversation history.
 	if err := cs.chatRequest(ctx, nextUserMessage); err != nil {
 		return nonFatalError(fmt.Errorf("chat request error: %w", err))
 	}
 
-	// Summarize the conversation if necessary.
-	if err := cs.maybeSummarize(ctx); err != nil {
-		return nonFatalError(fmt.Errorf("summarization error: %w", err))
-	}
-
 	return ranSuccessfully()
 }
 
@@ -521,7 +597,7 @@ func (cs *Session) processInput(ctx context.Context, input *string) bool {
 
 	// If there's a `#url:path` token in the input, handle it; we'll replace the input with that URL's contents,
 	// and path name presented to the URL for context to handle the completion.
-	err = cs.addURLs(input)
+	err = cs.addURLs(ctx, input)
 	if err != nil {
 		cs.OutWriter.WriteString(fmt.Sprintf("Error adding URLs: %s\n", err))
 		cs.OutWriter.Flush()
@@ -541,7 +617,8 @@ func (cs *Session) processInput(ctx context.Context, input *string) bool {
 	return false
 }
 
-// addFiles to input replaces the #file:$path commands with the file's contents.
+// addFiles to input replaces the #file:$path commands with the file's contents,
+// read like the read_file tool reads them, see readFile.
 //
 // If there's a space in the path, the path must be wrapped in quotes.
 func (cs *Session) addFiles(input *string) error {
@@ -552,28 +629,13 @@ func (cs *Session) addFiles(input *string) error {
 	fields := strings.Fields(*input)
 	for _, field := range fields {
 		if strings.HasPrefix(field, "#file:") {
-			filePath := strings.TrimPrefix(field, "#file:")
-
-			// cs.OutWriter.Write([]byte(fmt.Sprintf("Reading file: %s\n", filePath)))
-			// cs.OutWriter.Flush()
-
-			file, err := os.Open(filePath)
+			fileContent, err := readFile(strings.TrimPrefix(field, "#file:"))
 			if err != nil {
-				return fmt.Errorf("failed to open file %q: %w", filePath, err)
-			}
-			defer file.Close()
-
-			scanner := bufio.NewScanner(file)
-			var fileContent strings.Builder
-			for scanner.Scan() {
-				fileContent.WriteString(scanner.Text() + "\n")
-			}
-			if err := scanner.Err(); err != nil {
-				return fmt.Errorf("error reading file %q: %w", filePath, err)
+				return err
 			}
 
 			// Replace the #file: directive in the input with the file's content
-			*input = strings.Replace(*input, field, fileContent.String(), 1)
+			*input = strings.Replace(*input, field, fileContent, 1)
 		}
 	}
 
@@ -581,7 +643,7 @@ func (cs *Session) addFiles(input *string) error {
 }
 
 // addURLS makes GET requests to the URLs in the input and replaces them with the response content.
-func (cs *Session) addURLs(input *string) error {
+func (cs *Session) addURLs(ctx context.Context, input *string) error {
 	if input == nil || !strings.Contains(*input, "#url:") {
 		return nil
 	}
@@ -589,83 +651,149 @@ func (cs *Session) addURLs(input *string) error {
 	fields := strings.Fields(*input)
 	for _, field := range fields {
 		if strings.HasPrefix(field, "#url:") {
-			url := strings.TrimPrefix(field, "#url:")
-
-			if !strings.HasPrefix(url, "http://") || !strings.HasPrefix(url, "https://") {
-				url = fmt.Sprint("https://", url)
-			}
-
-			if strings.HasPrefix(url, "http://") {
-				url = strings.Replace(url, "http://", "https://", 1)
-			}
-
-			resp, err := http.Get(url)
+			body, err := fetchURL(ctx, http.DefaultClient, strings.TrimPrefix(field, "#url:"))
 			if err != nil {
-				return fmt.Errorf("failed to fetch URL %q: %w", url, err)
-			}
-			defer resp.Body.Close()
-
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return fmt.Errorf("error reading response body from URL %q: %w", url, err)
+				return err
 			}
 
 			// Replace the #url: directive in the input with the response content
-			*input = strings.Replace(*input, field, string(body), 1)
+			*input = strings.Replace(*input, field, body, 1)
 		}
 	}
 
 	return nil
 }
 
+// httpsURL returns url with an HTTPS scheme, adding one if it is missing or
+// replacing HTTP.
+func httpsURL(url string) string {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = fmt.Sprint("https://", url)
+	}
+
+	if strings.HasPrefix(url, "http://") {
+		url = strings.Replace(url, "http://", "https://", 1)
+	}
+
+	return url
+}
+
+// fetchURL makes a GET request to url with client, always over HTTPS, and
+// returns the response body, truncated to the size of a tool result.
+func fetchURL(ctx context.Context, client *http.Client, url string) (string, error) {
+	url = httpsURL(url)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request for URL %q: %w", url, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch URL %q: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxToolResultBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("error reading response body from URL %q: %w", url, err)
+	}
+	if len(body) > maxToolResultBytes {
+		body = append(body[:maxToolResultBytes], "\n[truncated]"...)
+	}
+
+	return string(body), nil
+}
+
 // ptr is a helper function to create a pointer to a value, because
 // we're using a pointer to process the input (in case we need to modify it).
 func ptr[T any](v T) *T {
 	return &v
 }
 
-// chatRequest sends the conversation to the API and displays the bot's response.
+// chatRequest sends the conversation to the API and streams the bot's response
+// to the terminal, re-rendering it as Markdown once it is complete.
+//
+// If the model calls any of the session's tools, they are run once the user
+// approves them and their results sent back, until the model answers the
+// message.
+//
+// Pressing Ctrl-C while the response is streaming stops it; the partial
+// response is kept in the conversation history.
 func (cs *Session) chatRequest(ctx context.Context, nextUserMessage openai.ChatCompletionMessage) error {
 	cs.Messages = append(cs.Messages, nextUserMessage)
 
-	resp, err := cs.Client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Model:    cs.ChatModel,
-		Messages: newMessageUnion(cs.Messages),
-		// TODO(kent): consider this more.
-		//
-		// MaxCompletionTokens: cmp.Or(cs.MaxCompletionTokens, 2048),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create chat: %w", err)
-	}
+	var (
+		respID       string
+		respMessage  openai.ChatCompletionMessage
+		toolMessages []openai.ChatCompletionMessage
+		usage        openai.CompletionUsage
+		interrupted  bool
+	)
+	for round := 1; ; round++ {
+		reqCtx, stop := cs.interruptible(ctx)
+		acc, err := cs.streamCompletion(reqCtx, round < maxToolRounds)
+
+		// A cancelled request context with a live session context means the
+		// user interrupted the response, which is not an error.
+		interrupted = reqCtx.Err() != nil && ctx.Err() == nil
+		stop()
+		if err != nil && !interrupted {
+			cs.Messages = cs.Messages[:len(cs.Messages)-1-len(toolMessages)]
+			return fmt.Errorf("failed to create chat: %w", err)
+		}
 
-	// Render the response (renderMarkdown is assumed to be implemented elsewhere).
-	rendered, err := renderMarkdown(strings.TrimRight(resp.Choices[0].Message.Content, "\n"), cs.TermWidth)
-	if err != nil {
-		return err
+		respID = cmp.Or(acc.ID, respID)
+		usage.PromptTokens += acc.Usage.PromptTokens
+		usage.CompletionTokens += acc.Usage.CompletionTokens
+		usage.TotalTokens += acc.Usage.TotalTokens
+
+		respMessage = openai.ChatCompletionMessage{}
+		if len(acc.Choices) > 0 {
+			respMessage = acc.Choices[0].Message
+		}
+		respMessage.Role = "assistant"
+
+		if interrupted || len(respMessage.ToolCalls) == 0 {
+			break
+		}
+
+		results := cs.runTools(ctx, respMessage.ToolCalls)
+		toolMessages = append(toolMessages, respMessage)
+		toolMessages = append(toolMessages, results...)
+		cs.Messages = append(cs.Messages, respMessage)
+		cs.Messages = append(cs.Messages, results...)
 	}
 
-	cs.OutWriter.WriteString(rendered)
-	cs.OutWriter.Flush()
+	if interrupted {
+		// Tool calls without results would be rejected by the API, so
+		// drop any that were interrupted before being answered.
+		respMessage.ToolCalls = nil
+
+		cs.OutWriter.WriteString(lipgloss.NewStyle().Faint(true).Render("(interrupted)") + "\n\n")
+		cs.OutWriter.Flush()
+	}
 
 	// Append the bot response to the conversation history and update token count.
-	cs.Messages = append(cs.Messages, resp.Choices[0].Message)
-	cs.CurrentTokensUsed += resp.Usage.TotalTokens
+	cs.Messages = append(cs.Messages, respMessage)
+	cs.CurrentTokensUsed = cs.countTokens(cs.Messages)
 
 	// The reqRespPairKey is a K-Sortable Unique IDentifier (KSUID) for the request and response.
 	//
 	// This is useful for iterating over the cache in a sorted order, which we can
 	// use to do things like summarize the conversation based on the most recent
 	// messages in the backend.
-	reqRespPairKey := fmt.Sprintf("%s-%s", ksuid.New(), resp.ID)
+	reqRespPairKey := fmt.Sprintf("%s-%s", ksuid.New(), cmp.Or(respID, "interrupted"))
 
-	// Save the request and response to the backend storage.
+	// Save the request and response to the backend storage, using the session
+	// context so an interrupted response is still saved.
 	if err := cs.StorageBackend.Set(ctx, reqRespPairKey, ReqRespPair{
-		Model:      cs.ChatModel,
-		Req:        nextUserMessage,
-		ReqTokens:  resp.Usage.PromptTokens,
-		Resp:       resp.Choices[0].Message,
-		RespTokens: resp.Usage.CompletionTokens,
+		Model:        cs.ChatModel,
+		Req:          nextUserMessage,
+		ReqTokens:    usage.PromptTokens,
+		ToolMessages: toolMessages,
+		Resp:         respMessage,
+		RespTokens:   usage.CompletionTokens,
 	}); err != nil {
 		return fmt.Errorf("failed to save chat response to backend storage: %w", err)
 	}
@@ -676,10 +804,65 @@ func (cs *Session) chatRequest(ctx context.Context, nextUserMessage openai.ChatC
 	return nil
 }
 
+// streamCompletion requests a completion of the conversation, writing the
+// response to the terminal as it streams and then rendering it as Markdown.
+//
+// The accumulated response is returned even if the stream fails part way.
+func (cs *Session) streamCompletion(ctx context.Context, withTools bool) (openai.ChatCompletionAccumulator, error) {
+	params := openai.ChatCompletionNewParams{
+		Model:    cs.ChatModel,
+		Messages: newMessageUnion(cs.Messages),
+		StreamOptions: openai.ChatCompletionStreamOptionsParam{
+			IncludeUsage: openai.Bool(true),
+		},
+		// TODO(kent): consider this more.
+		//
+		// MaxCompletionTokens: cmp.Or(cs.MaxCompletionTokens, 2048),
+	}
+	if withTools {
+		params.Tools = cs.toolParams()
+	}
+
+	stream := cs.Client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	var (
+		acc = openai.ChatCompletionAccumulator{}
+		out = &streamWriter{cs: cs}
+	)
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			out.WriteString(chunk.Choices[0].Delta.Content)
+		}
+	}
+
+	var content string
+	if len(acc.Choices) > 0 {
+		content = acc.Choices[0].Message.Content
+	}
+
+	// Render the response, replacing the streamed plain text. Responses
+	// that only call tools have nothing to render.
+	if content != "" {
+		rendered, err := renderMarkdown(strings.TrimRight(content, "\n"), cs.TermWidth)
+		if err != nil {
+			return acc, err
+		}
+		out.replace(rendered)
+	}
+
+	return acc, stream.Err()
+}
+
 // ChunkString takes a given string (and number of tokens it contains), and splits it into
 // smaller strings that are within the given max token limit. This is useful for embeddings
 // which require smaller context windows than their chat counterparts.
-func ChunkString(s string, maxTokens int64) ([]string, error) {
+//
+// Tokens are counted with counter, such as an encoding from the tokenizer package.
+// A single word with more tokens than the limit is kept as its own chunk.
+func ChunkString(s string, maxTokens int64, counter tokenizer.Counter) ([]string, error) {
 	if maxTokens <= 0 {
 		return nil, fmt.Errorf("maxTokens must be greater than 0")
 	}
@@ -690,12 +873,16 @@ func ChunkString(s string, maxTokens int64) ([]string, error) {
 	var currentChunk []string
 	var currentTokens int64
 
-	for _, word := range words {
-		// Estimate token count for the word (1 word = 1 token approximation)
-		wordTokens := int64(len(word)) / 2 // Rough estimate: 2 characters per token
+	for i, word := range words {
+		// Count the word's tokens as they appear in the text, after a space.
+		if i > 0 {
+			word = " " + word
+		}
+		wordTokens := int64(counter.Count(word))
+		word = strings.TrimPrefix(word, " ")
 
 		// Check if adding this word exceeds the maxTokens limit
-		if currentTokens+wordTokens > maxTokens {
+		if len(currentChunk) > 0 && currentTokens+wordTokens > maxTokens {
 			// Add the current chunk to the list of chunks
 			chunks = append(chunks, strings.Join(currentChunk, " "))
 			// Reset the current chunk and token count
@@ -716,19 +903,28 @@ func ChunkString(s string, maxTokens int64) ([]string, error) {
 	return chunks, nil
 }
 
-// maybeSummarize checks if the token count exceeds a threshold and, if so, generates a summary.
-func (cs *Session) maybeSummarize(ctx context.Context) error {
-	if cs.CurrentTokensUsed >= cmp.Or(cs.SummarizeContextWindowSize, 4096) {
-		summary, summaryTokens, err := cs.summarize(ctx, 0)
+// maybeSummarize checks if the prompt for the conversation, including any pending messages,
+// exceeds a threshold of the model's context window and, if so, generates a summary.
+func (cs *Session) maybeSummarize(ctx context.Context, pending ...openai.ChatCompletionMessage) error {
+	projected := cs.countTokens(append(slices.Clip(cs.Messages), pending...))
+	if float64(projected) >= float64(cs.contextWindow())*summarizeThreshold {
+		summary, err := cs.summarize(ctx, 0)
 		if err != nil {
 			return err
 		}
 
-		cs.Messages = []openai.ChatCompletionMessage{{
+		summaryMessage := openai.ChatCompletionMessage{
 			Role:    "system",
 			Content: "Summary of previous messages for context: " + summary,
-		}}
-		cs.CurrentTokensUsed = summaryTokens
+		}
+
+		cs.resetMessages()
+		cs.Messages = append(cs.Messages, summaryMessage)
+		cs.CurrentTokensUsed = cs.countTokens(cs.Messages)
+
+		if err := cs.saveSummary(ctx, summaryMessage, cs.countTokens([]openai.ChatCompletionMessage{summaryMessage})); err != nil {
+			return err
+		}
 
 		if err := cs.saveCache(ctx); err != nil {
 			return fmt.Errorf("failed to save chat history: %w", err)
@@ -740,7 +936,7 @@ func (cs *Session) maybeSummarize(ctx context.Context) error {
 }
 
 // summarize generates a summary of the conversation, retrying on rate limit errors if necessary.
-func (cs *Session) summarize(ctx context.Context, attempts int) (string, int64, error) {
+func (cs *Session) summarize(ctx context.Context, attempts int) (string, error) {
 	summaryMsgs := []openai.ChatCompletionMessage{
 		{
 			Role: "system",
@@ -754,7 +950,8 @@ func (cs *Session) summarize(ctx context.Context, attempts int) (string, int64,
 
 	var b strings.Builder
 	for _, m := range cs.Messages {
-		if m.Role == "system" {
+		// Skip the system prompt, but keep earlier summaries.
+		if m.Role == "system" && m.Content == cs.SystemPrompt {
 			continue
 		}
 		b.WriteString(string(m.Role) + ":\n" + m.Content + "\n")
@@ -775,10 +972,10 @@ func (cs *Session) summarize(ctx context.Context, attempts int) (string, int64,
 			time.Sleep(5 * time.Second)
 			return cs.summarize(ctx, attempts)
 		}
-		return "", 0, err
+		return "", err
 	}
 
-	return resp.Choices[0].Message.Content, resp.Usage.TotalTokens, nil
+	return resp.Choices[0].Message.Content, nil
 }
 
 // clearScreen clears the terminal.
@@ -790,17 +987,28 @@ func (cs *Session) clearScreen() {
 
 // loadCache loads the most recent conversation history from the cache, if it exists.
 func (cs *Session) loadCache(ctx context.Context) error {
-	entries, _, err := cs.StorageBackend.List(ctx, storage.PageSize(10), nil)
+	entries, err := cs.recentHistory(ctx, 10)
 	if err != nil {
 		return fmt.Errorf("failed to list chat cache: %w", err)
 	}
 
-	for _, value := range entries {
-		cs.CurrentTokensUsed += (value.ReqTokens + value.RespTokens)
+	for _, entry := range entries {
+		value := entry.Value
+
+		// A summary replaces the messages that came before it.
+		if value.Req.Role == "system" {
+			cs.resetMessages()
+			cs.Messages = append(cs.Messages, value.Req)
+			continue
+		}
+
 		cs.Messages = append(cs.Messages, value.Req)
+		cs.Messages = append(cs.Messages, value.ToolMessages...)
 		cs.Messages = append(cs.Messages, value.Resp)
 	}
 
+	cs.CurrentTokensUsed = cs.countTokens(cs.Messages)
+
 	if err := cs.maybeSummarize(ctx); err != nil {
 		return fmt.Errorf("failed to summarize chat after loading from cache: %w", err)
 	}
@@ -808,6 +1016,26 @@ func (cs *Session) loadCache(ctx context.Context) error {
 	return nil
 }
 
+// recentHistory returns up to n of the most recent request-response pairs
+// of the conversation, in chronological order.
+//
+// Keys start with a KSUID, whose alphanumeric characters encode in the same
+// order as they compare, so the encoded key order is chronological.
+func (cs *Session) recentHistory(ctx context.Context, n int) ([]storage.Entry[string, ReqRespPair], error) {
+	entries, _, err := cs.StorageBackend.List(ctx, storage.PageSize(n), nil, storage.Descending())
+	if err != nil {
+		return nil, err
+	}
+
+	var history []storage.Entry[string, ReqRespPair]
+	for key, value := range entries {
+		history = append(history, storage.Entry[string, ReqRespPair]{Key: key, Value: value})
+	}
+	slices.Reverse(history)
+
+	return history, nil
+}
+
 // saveCache writes the conversation history to the cache file.
 func (cs *Session) saveCache(ctx context.Context) error {
 	if err := cs.StorageBackend.Flush(ctx); err != nil {
@@ -3,18 +3,27 @@ package chat_test
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
 	"github.com/cockroachdb/pebble"
 	"github.com/cockroachdb/pebble/vfs"
 	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
 	"github.com/picatz/openai/internal/chat"
 	"github.com/picatz/openai/internal/chat/storage"
+	"github.com/picatz/openai/internal/chat/storage/memory"
 	pebbleStorage "github.com/picatz/openai/internal/chat/storage/pebble"
+	"github.com/picatz/openai/internal/chat/tokenizer"
+	"github.com/picatz/openai/internal/chat/tokenizer/tokenizertest"
 	"github.com/shoenig/test/must"
 )
 
@@ -46,7 +55,7 @@ func TestChatSession(t *testing.T) {
 		must.NoError(t, memBackend.Close(t.Context()))
 	})
 
-	chatSession, restore, err := chat.NewSession(t.Context(), &client, openai.ChatModelGPT4o, input, output, memBackend)
+	chatSession, restore, err := chat.NewSession(t.Context(), &client, openai.ChatModelGPT4o, "", input, output, memBackend, tokenizer.Estimate{})
 	must.NoError(t, err)
 	t.Cleanup(restore)
 	must.NotNil(t, chatSession)
@@ -60,22 +69,172 @@ func TestChatSession(t *testing.T) {
 	t.Log(output.String())
 }
 
-func TestChunkString(t *testing.T) {
-	var (
-		input     = "This is a test string that is longer than the chunk size."
-		chunkSize = int64(8)
-	)
+// lockedBuffer is a bytes.Buffer that is safe for concurrent use.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
 
-	chunks, err := chat.ChunkString(input, chunkSize)
+// streamChunk formats a chat completion chunk as a server-sent event.
+func streamChunk(content string) string {
+	return `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":` + fmt.Sprintf("%q", content) + `}}]}` + "\n\n"
+}
+
+func newStreamingSession(t *testing.T, handler http.HandlerFunc, input io.Reader, output io.Writer) *chat.Session {
+	t.Helper()
+
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	client := openai.NewClient(option.WithBaseURL(ts.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	backend := memory.NewBackend[string, chat.ReqRespPair]()
+
+	chatSession, restore, err := chat.NewSession(t.Context(), &client, openai.ChatModelGPT4o, "", input, output, backend, tokenizer.Estimate{})
 	must.NoError(t, err)
+	t.Cleanup(restore)
+
+	return chatSession
+}
 
-	expectedChunks := []string{
-		"This is a test string",
-		"that is longer than",
-		"the chunk size.",
+func TestChatSession_streaming(t *testing.T) {
+	chatSession := newStreamingSession(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, streamChunk("Hello"))
+		io.WriteString(w, streamChunk(", world!"))
+		io.WriteString(w, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}, strings.NewReader("hello\r\n"), io.Discard)
+
+	done, err := chatSession.RunOnce(t.Context())
+	must.NoError(t, err)
+	must.False(t, done)
+
+	must.Len(t, 2, chatSession.Messages)
+	must.Eq(t, "Hello, world!", chatSession.Messages[1].Content)
+	// The context is counted locally, estimated at 4 bytes per token: 3 to prime
+	// the reply, and 3 for each message, plus its role and content.
+	must.Eq(t, int64(3+(3+1+2)+(3+3+4)), chatSession.CurrentTokensUsed)
+
+	entries, _, err := chatSession.StorageBackend.List(t.Context(), storage.PageSize(10), nil)
+	must.NoError(t, err)
+	var saved int
+	for _, pair := range entries {
+		must.Eq(t, "hello", pair.Req.Content)
+		must.Eq(t, "Hello, world!", pair.Resp.Content)
+		must.Eq(t, int64(4), pair.RespTokens)
+		saved++
 	}
+	must.Eq(t, 1, saved)
+}
 
-	must.Eq(t, expectedChunks, chunks)
+func TestChatSession_interrupt(t *testing.T) {
+	input, typed := io.Pipe()
+	t.Cleanup(func() { typed.Close() })
+
+	output := &lockedBuffer{}
+
+	chatSession := newStreamingSession(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, streamChunk("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}, input, output)
+
+	go func() {
+		typed.Write([]byte("hello\r\n"))
+		// Press Ctrl-C once the first delta has been written to the terminal.
+		for !strings.Contains(output.String(), "partial") {
+			time.Sleep(time.Millisecond)
+		}
+		typed.Write([]byte{3})
+	}()
+
+	done, err := chatSession.RunOnce(t.Context())
+	must.NoError(t, err)
+	must.False(t, done)
+	must.StrContains(t, output.String(), "(interrupted)")
+
+	must.Len(t, 2, chatSession.Messages)
+	must.Eq(t, "partial", chatSession.Messages[1].Content)
+
+	entries, _, err := chatSession.StorageBackend.List(t.Context(), storage.PageSize(10), nil)
+	must.NoError(t, err)
+	var saved int
+	for _, pair := range entries {
+		must.Eq(t, "partial", pair.Resp.Content)
+		saved++
+	}
+	must.Eq(t, 1, saved)
+}
+
+func TestChatSession_resumesRecentHistory(t *testing.T) {
+	backend := memory.NewBackend[string, chat.ReqRespPair]()
+	for i := range 12 {
+		must.NoError(t, backend.Set(t.Context(), fmt.Sprintf("%02d-chatcmpl", i), chat.ReqRespPair{
+			Req:  openai.ChatCompletionMessage{Role: "user", Content: fmt.Sprintf("question %d", i)},
+			Resp: openai.ChatCompletionMessage{Role: "assistant", Content: fmt.Sprintf("answer %d", i)},
+		}))
+	}
+
+	client := openai.NewClient(option.WithAPIKey("test"))
+
+	chatSession, restore, err := chat.NewSession(t.Context(), &client, openai.ChatModelGPT4o, "", strings.NewReader(""), io.Discard, backend, tokenizer.Estimate{})
+	must.NoError(t, err)
+	t.Cleanup(restore)
+
+	// The 10 most recent pairs are restored, oldest first.
+	must.Len(t, 20, chatSession.Messages)
+	must.Eq(t, "question 2", chatSession.Messages[0].Content)
+	must.Eq(t, "answer 11", chatSession.Messages[19].Content)
+}
+
+func TestChunkString(t *testing.T) {
+	input := "This is a test string that is longer than the chunk size."
+
+	t.Run("estimate", func(t *testing.T) {
+		chunks, err := chat.ChunkString(input, 8, tokenizer.Estimate{})
+		must.NoError(t, err)
+
+		expectedChunks := []string{
+			"This is a test string",
+			"that is longer than the",
+			"chunk size.",
+		}
+
+		must.Eq(t, expectedChunks, chunks)
+	})
+
+	t.Run("encoding", func(t *testing.T) {
+		// Every word is a single token, except " the", which is split into
+		// " th" and "e".
+		encoding, err := tokenizer.Load(tokenizer.O200kBase, strings.NewReader(tokenizertest.Encoding(
+			"This", " is", " a", " test", " string", " that", " longer", " than", " chunk", " size",
+		)))
+		must.NoError(t, err)
+
+		chunks, err := chat.ChunkString(input, 5, encoding)
+		must.NoError(t, err)
+
+		expectedChunks := []string{
+			"This is a test string",
+			"that is longer than",
+			"the chunk size.",
+		}
+
+		must.Eq(t, expectedChunks, chunks)
+	})
 }
 
 func TestChunkString_consign_similarity(t *testing.T) {
@@ -85,7 +244,7 @@ func TestChunkString_consign_similarity(t *testing.T) {
 		// chunkSize = int64(5)
 	)
 
-	chunks, err := chat.ChunkString(input, chunkSize)
+	chunks, err := chat.ChunkString(input, chunkSize, tokenizer.Estimate{})
 	must.NoError(t, err)
 
 	// expectedChunks := []string{
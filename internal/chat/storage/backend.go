@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"iter"
+	"strings"
 )
 
 type Entry[K, V any] struct {
@@ -14,7 +15,17 @@ type Backend[K, V any] interface {
 	Get(ctx context.Context, key K) (value V, found bool, err error)
 	Set(ctx context.Context, key K, value V) error
 	Delete(ctx context.Context, key K) error
-	List(ctx context.Context, pageSize *int, pageToken *K) (entries iter.Seq2[K, V], nextPageToken *K, err error)
+
+	// List returns a page of entries in ascending encoded key order, or
+	// descending with the Descending option, starting at the pageToken key
+	// if given. The encoded key order is the byte order of the keys encoded
+	// with a JSONCodec, which need not be the order of the keys themselves,
+	// and is shared by every backend so that they list entries alike.
+	// The next page token is the key that starts the next page, or nil if
+	// there are no more entries. The WithPrefix option limits the entries
+	// to the keys starting with a prefix.
+	List(ctx context.Context, pageSize *int, pageToken *K, opts ...ListOption) (entries iter.Seq2[K, V], nextPageToken *K, err error)
+
 	Flush(ctx context.Context) error
 	Close(ctx context.Context) error
 }
@@ -30,3 +41,49 @@ func PageSize(pageSize int) *int {
 func PageToken[T any](pageToken T) *T {
 	return ptr(pageToken)
 }
+
+// ListOptions configures how a backend lists its entries.
+type ListOptions struct {
+	// Descending lists entries from the greatest encoded key to the least.
+	Descending bool
+
+	// Prefix limits the entries to the keys starting with it. It is only
+	// supported by backends with string keys.
+	Prefix string
+}
+
+// ListOption configures a call to Backend.List.
+type ListOption func(*ListOptions)
+
+// Descending lists entries in descending encoded key order.
+func Descending() ListOption {
+	return func(o *ListOptions) {
+		o.Descending = true
+	}
+}
+
+// WithPrefix lists the entries with keys starting with prefix.
+func WithPrefix(prefix string) ListOption {
+	return func(o *ListOptions) {
+		o.Prefix = prefix
+	}
+}
+
+// HasPrefix reports whether key is a string starting with the Prefix option,
+// or whether there is no Prefix option.
+func (o ListOptions) HasPrefix(key any) bool {
+	if o.Prefix == "" {
+		return true
+	}
+	s, ok := key.(string)
+	return ok && strings.HasPrefix(s, o.Prefix)
+}
+
+// NewListOptions returns the ListOptions configured by opts.
+func NewListOptions(opts ...ListOption) ListOptions {
+	var o ListOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -9,3 +9,11 @@ type Codec[K, V any] interface {
 	EncodeValue(V) ([]byte, error)
 	DecodeValue([]byte) (V, error)
 }
+
+// PrefixCodec is implemented by codecs that can encode a prefix of string
+// keys, such that the encoding of every key starting with the prefix starts
+// with it. Backends storing encoded keys use it to list a prefix without
+// reading the keys outside of it.
+type PrefixCodec interface {
+	EncodeKeyPrefix(prefix string) ([]byte, error)
+}
@@ -1,9 +1,16 @@
 package storage
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"errors"
+	"unicode/utf8"
+)
 
-// Ensure JSONCodec implements Codec interface.
-var _ Codec[any, any] = (*JSONCodec[any, any])(nil)
+// Ensure JSONCodec implements Codec and PrefixCodec interfaces.
+var (
+	_ Codec[any, any] = (*JSONCodec[any, any])(nil)
+	_ PrefixCodec     = (*JSONCodec[any, any])(nil)
+)
 
 // JSONCodec is a codec for encoding and decoding keys and values
 // using standard Go JSON serialization.
@@ -23,6 +30,20 @@ func (c *JSONCodec[K, V]) DecodeKey(data []byte) (K, error) {
 	return key, nil
 }
 
+// EncodeKeyPrefix encodes a prefix of string keys, which is their JSON
+// encoding without the closing quote. The prefix must be valid UTF-8, since
+// a rune cut short would be encoded differently from the whole rune.
+func (c *JSONCodec[K, V]) EncodeKeyPrefix(prefix string) ([]byte, error) {
+	if !utf8.ValidString(prefix) {
+		return nil, errors.New("key prefix is not valid UTF-8")
+	}
+	b, err := json.Marshal(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return b[:len(b)-1], nil
+}
+
 // EncodeValue encodes a value into a JSON byte slice for a storage backend.
 func (c *JSONCodec[K, V]) EncodeValue(value V) ([]byte, error) {
 	return json.Marshal(value)
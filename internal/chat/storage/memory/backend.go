@@ -1,9 +1,10 @@
 package memory
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"iter"
-
 	"slices"
 
 	"github.com/picatz/openai/internal/chat/storage"
@@ -11,8 +12,18 @@ import (
 
 var _ storage.Backend[string, string] = (*Backend[string, string])(nil)
 
+// Backend is an in-memory storage backend, which keeps its entries in a
+// slice sorted by their keys encoded with a storage.JSONCodec. It lists
+// entries in the same order as a Pebble backend using that codec.
 type Backend[K comparable, V any] struct {
-	store []storage.Entry[K, V]
+	codec storage.JSONCodec[K, V]
+	store []entry[K, V]
+}
+
+// entry is a stored entry, with the encoding of its key it is sorted by.
+type entry[K, V any] struct {
+	storage.Entry[K, V]
+	encodedKey []byte
 }
 
 // NewBackend creates a new in-memory storage backend, which uses a slice to store entries.
@@ -20,64 +31,112 @@ func NewBackend[K comparable, V any]() *Backend[K, V] {
 	return &Backend[K, V]{}
 }
 
+// search returns the position of the encoded key in the store, or where it
+// would be inserted, and whether it was found.
+func (b *Backend[K, V]) search(encodedKey []byte) (int, bool) {
+	return slices.BinarySearchFunc(b.store, encodedKey, func(e entry[K, V], encodedKey []byte) int {
+		return bytes.Compare(e.encodedKey, encodedKey)
+	})
+}
+
 // Get retrieves a value from the in-memory store by its key.
 func (b *Backend[K, V]) Get(ctx context.Context, key K) (V, bool, error) {
-	for _, entry := range b.store {
-		if entry.Key == key {
-			return entry.Value, true, nil
-		}
-	}
 	var zero V
+
+	encodedKey, err := b.codec.EncodeKey(key)
+	if err != nil {
+		return zero, false, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	if i, found := b.search(encodedKey); found {
+		return b.store[i].Value, true, nil
+	}
 	return zero, false, nil
 }
 
 // Set stores a key-value pair in the in-memory store.
 func (b *Backend[K, V]) Set(ctx context.Context, key K, value V) error {
+	encodedKey, err := b.codec.EncodeKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
 	// Check if the key already exists, and if so, update the value.
-	for i, entry := range b.store {
-		if entry.Key == key {
-			b.store[i].Value = value
-			return nil
-		}
+	i, found := b.search(encodedKey)
+	if found {
+		b.store[i].Value = value
+		return nil
 	}
 
-	// Add the new entry to the store, at the front, similar to
-	// how Pebble does it, in a sense, but not really.
-	b.store = append([]storage.Entry[K, V]{{Key: key, Value: value}}, b.store...)
+	// Insert the new entry, keeping the store sorted like Pebble does.
+	b.store = slices.Insert(b.store, i, entry[K, V]{Entry: storage.Entry[K, V]{Key: key, Value: value}, encodedKey: encodedKey})
 	return nil
 }
 
 // Delete removes a key-value pair from the in-memory store by its key.
 func (b *Backend[K, V]) Delete(ctx context.Context, key K) error {
-	for i, entry := range b.store {
-		if entry.Key == key {
-			b.store = slices.Delete(b.store, i, i+1)
-			break
-		}
+	encodedKey, err := b.codec.EncodeKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	if i, found := b.search(encodedKey); found {
+		b.store = slices.Delete(b.store, i, i+1)
 	}
 
 	return nil
 }
 
-// List retrieves all key-value pairs from the in-memory store, with optional pagination.
-func (b *Backend[K, V]) List(ctx context.Context, pageSize *int, pageToken *K) (iter.Seq2[K, V], *K, error) {
-	var entries []storage.Entry[K, V]
-	var nextPageToken *K
+// List retrieves key-value pairs from the in-memory store, with optional pagination.
+func (b *Backend[K, V]) List(ctx context.Context, pageSize *int, pageToken *K, opts ...storage.ListOption) (iter.Seq2[K, V], *K, error) {
+	var (
+		listOpts      = storage.NewListOptions(opts...)
+		lo, hi        = 0, len(b.store)
+		nextPageToken *K
+	)
+
+	if listOpts.Prefix != "" {
+		var zero K
+		if _, ok := any(zero).(string); !ok {
+			return nil, nil, fmt.Errorf("listing a key prefix requires string keys")
+		}
+		prefix, err := b.codec.EncodeKeyPrefix(listOpts.Prefix)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode key prefix: %w", err)
+		}
+		// Keys sharing the prefix are sorted next to each other.
+		lo, _ = b.search(prefix)
+		hi = lo
+		for hi < len(b.store) && bytes.HasPrefix(b.store[hi].encodedKey, prefix) {
+			hi++
+		}
+	}
 
 	if pageToken != nil {
-		for i, entry := range b.store {
-			if entry.Key == *pageToken {
-				entries = b.store[i+1:]
-				break
+		encodedToken, err := b.codec.EncodeKey(*pageToken)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode page token: %w", err)
+		}
+		i, found := b.search(encodedToken)
+		if listOpts.Descending {
+			// Start at the greatest key that is less than or equal to the token.
+			if found {
+				i++
 			}
+			hi = min(hi, i)
+		} else {
+			lo = max(lo, i)
 		}
-	} else {
-		entries = b.store
+	}
+
+	entries := slices.Clone(b.store[lo:max(lo, hi)])
+	if listOpts.Descending {
+		slices.Reverse(entries)
 	}
 
 	if pageSize != nil && len(entries) > *pageSize {
+		nextPageToken = &entries[*pageSize].Key
 		entries = entries[:*pageSize]
-		nextPageToken = &entries[*pageSize-1].Key
 	}
 
 	return func(yield func(K, V) bool) {
@@ -1,7 +1,9 @@
 package pebble
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"iter"
 
@@ -90,17 +92,50 @@ func (b *Backend[K, V]) Delete(ctx context.Context, key K) error {
 // DefaultListPageSize is the default page size for listing items.
 const DefaultListPageSize = 25
 
-// List retrieves a list of key-value pairs from the storage backend.
-func (b *Backend[K, V]) List(ctx context.Context, pageSize *int, pageToken *K) (iter.Seq2[K, V], *K, error) {
-	iterOpts := &pebble.IterOptions{}
+// List retrieves a list of key-value pairs from the storage backend, in the
+// byte order of their keys encoded by the codec, which is the encoded key
+// order of storage.Backend when the codec is a JSONCodec. Then string
+// keys are in the order of the keys themselves, except where a key is
+// followed by characters that sort before its closing quote or are escaped:
+// "hello again" is listed before "hello".
+func (b *Backend[K, V]) List(ctx context.Context, pageSize *int, pageToken *K, opts ...storage.ListOption) (iter.Seq2[K, V], *K, error) {
+	var (
+		listOpts = storage.NewListOptions(opts...)
+		iterOpts = &pebble.IterOptions{}
+	)
+
+	// Seek to the prefix when the codec can encode it, otherwise every key
+	// is read and those outside of the prefix are skipped.
+	if listOpts.Prefix != "" {
+		var zero K
+		if _, ok := any(zero).(string); !ok {
+			return nil, nil, errors.New("listing a key prefix requires string keys")
+		}
+		if codec, ok := b.codec.(storage.PrefixCodec); ok {
+			prefix, err := codec.EncodeKeyPrefix(listOpts.Prefix)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to encode pebble storage backend key prefix: %w", err)
+			}
+			iterOpts.LowerBound = prefix
+			iterOpts.UpperBound = prefixUpperBound(prefix)
+		}
+	}
 
 	if pageToken != nil {
-		lowerBoundKey, err := b.codec.EncodeKey(*pageToken)
+		boundKey, err := b.codec.EncodeKey(*pageToken)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to encode pebble storage backend lower bound key: %w", err)
+			return nil, nil, fmt.Errorf("failed to encode pebble storage backend bound key: %w", err)
 		}
 
-		iterOpts.LowerBound = lowerBoundKey
+		if listOpts.Descending {
+			// The upper bound is exclusive, so use the key's immediate
+			// successor to include the page token itself.
+			if upper := append(boundKey, 0); iterOpts.UpperBound == nil || bytes.Compare(upper, iterOpts.UpperBound) < 0 {
+				iterOpts.UpperBound = upper
+			}
+		} else if bytes.Compare(boundKey, iterOpts.LowerBound) > 0 {
+			iterOpts.LowerBound = boundKey
+		}
 	}
 
 	listLimit := DefaultListPageSize
@@ -119,7 +154,12 @@ func (b *Backend[K, V]) List(ctx context.Context, pageSize *int, pageToken *K) (
 		nextPageToken *K
 	)
 
-	for iter.First(); iter.Valid(); iter.Next() {
+	first, next := iter.First, iter.Next
+	if listOpts.Descending {
+		first, next = iter.Last, iter.Prev
+	}
+
+	for first(); iter.Valid(); next() {
 		if ctx.Err() != nil {
 			return func(yield func(K, V) bool) {
 				for _, v := range values {
@@ -134,6 +174,9 @@ func (b *Backend[K, V]) List(ctx context.Context, pageSize *int, pageToken *K) (
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to decode key: %w", err)
 		}
+		if !listOpts.HasPrefix(k) {
+			continue
+		}
 
 		v, err := b.codec.DecodeValue(iter.Value())
 		if err != nil {
@@ -143,12 +186,15 @@ func (b *Backend[K, V]) List(ctx context.Context, pageSize *int, pageToken *K) (
 		values = append(values, storage.Entry[K, V]{Key: k, Value: v})
 
 		if len(values) >= listLimit {
-			if iter.Next() {
+			for next() {
 				nextKey, err := b.codec.DecodeKey(iter.Key())
 				if err != nil {
 					return nil, nil, fmt.Errorf("failed to decode next key: %w", err)
 				}
-				nextPageToken = &nextKey
+				if listOpts.HasPrefix(nextKey) {
+					nextPageToken = &nextKey
+					break
+				}
 			}
 			break
 		}
@@ -166,6 +212,19 @@ func (b *Backend[K, V]) List(ctx context.Context, pageSize *int, pageToken *K) (
 	}, nextPageToken, nil
 }
 
+// prefixUpperBound returns the least key greater than every key starting
+// with prefix, or nil if there is none.
+func prefixUpperBound(prefix []byte) []byte {
+	upper := bytes.Clone(prefix)
+	for i := len(upper) - 1; i >= 0; i-- {
+		if upper[i] < 0xff {
+			upper[i]++
+			return upper[:i+1]
+		}
+	}
+	return nil
+}
+
 // Flush flushes the storage backend.
 func (b *Backend[K, V]) Flush(ctx context.Context) error {
 	if err := b.db.Flush(); err != nil {
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"strings"
+)
+
+// Ensure that Prefixed implements the Backend interface.
+var _ Backend[string, any] = (*Prefixed[any])(nil)
+
+// Prefixed is a view of a backend with string keys, limited to the keys that
+// start with a given prefix. The prefix is added to keys passed to the view,
+// and removed from keys returned by it, so several views can share a single
+// backend without seeing each other's entries.
+type Prefixed[V any] struct {
+	backend Backend[string, V]
+	prefix  string
+}
+
+// NewPrefixed returns a view of backend limited to keys starting with prefix.
+func NewPrefixed[V any](backend Backend[string, V], prefix string) *Prefixed[V] {
+	return &Prefixed[V]{backend: backend, prefix: prefix}
+}
+
+// Prefix returns the prefix of the view's keys.
+func (p *Prefixed[V]) Prefix() string {
+	return p.prefix
+}
+
+// Get retrieves a value from the underlying backend by its key.
+func (p *Prefixed[V]) Get(ctx context.Context, key string) (V, bool, error) {
+	return p.backend.Get(ctx, p.prefix+key)
+}
+
+// Set stores a key-value pair in the underlying backend.
+func (p *Prefixed[V]) Set(ctx context.Context, key string, value V) error {
+	return p.backend.Set(ctx, p.prefix+key, value)
+}
+
+// Delete removes a key-value pair from the underlying backend.
+func (p *Prefixed[V]) Delete(ctx context.Context, key string) error {
+	return p.backend.Delete(ctx, p.prefix+key)
+}
+
+// List retrieves the key-value pairs in the view, in the order of the
+// underlying backend. A nil page size lists all remaining entries.
+//
+// The prefix is passed down to the underlying backend with the WithPrefix
+// option, combined with any prefix given in opts.
+func (p *Prefixed[V]) List(ctx context.Context, pageSize *int, pageToken *string, opts ...ListOption) (iter.Seq2[string, V], *string, error) {
+	opts = append(slices.Clip(opts), func(o *ListOptions) {
+		o.Prefix = p.prefix + o.Prefix
+	})
+
+	if pageToken != nil {
+		pageToken = ptr(p.prefix + *pageToken)
+	}
+
+	var (
+		entries       []Entry[string, V]
+		nextPageToken *string
+	)
+	for {
+		page, next, err := p.backend.List(ctx, pageSize, pageToken, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for key, value := range page {
+			entries = append(entries, Entry[string, V]{Key: strings.TrimPrefix(key, p.prefix), Value: value})
+		}
+
+		// Backends limit the size of a page without one, so keep listing
+		// until every remaining entry is collected.
+		if pageSize != nil || next == nil {
+			if next != nil {
+				nextPageToken = ptr(strings.TrimPrefix(*next, p.prefix))
+			}
+			break
+		}
+		pageToken = next
+	}
+
+	return func(yield func(string, V) bool) {
+		for _, entry := range entries {
+			if !yield(entry.Key, entry.Value) {
+				break
+			}
+		}
+	}, nextPageToken, nil
+}
+
+// Flush flushes the underlying backend.
+func (p *Prefixed[V]) Flush(ctx context.Context) error {
+	return p.backend.Flush(ctx)
+}
+
+// Close is a no-op, as the underlying backend is shared and closed by its owner.
+func (p *Prefixed[V]) Close(context.Context) error {
+	return nil
+}
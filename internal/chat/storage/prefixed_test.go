@@ -0,0 +1,39 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/picatz/openai/internal/chat/storage"
+	"github.com/picatz/openai/internal/chat/storage/memory"
+	"github.com/picatz/openai/internal/chat/storage/tests"
+	"github.com/shoenig/test/must"
+)
+
+func TestPrefixed(t *testing.T) {
+	backend := memory.NewBackend[string, string]()
+
+	tests.BackendSuite(t, storage.NewPrefixed(backend, "a/"))
+
+	other := storage.NewPrefixed(backend, "b/")
+	must.NoError(t, other.Set(t.Context(), "hello", "other world"))
+
+	value, ok, err := backend.Get(t.Context(), "b/hello")
+	must.NoError(t, err)
+	must.True(t, ok)
+	must.Eq(t, "other world", value)
+
+	entries, next, err := other.List(t.Context(), nil, nil)
+	must.NoError(t, err)
+	must.Nil(t, next)
+
+	var keys []string
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	must.Eq(t, []string{"hello"}, keys)
+
+	value, ok, err = storage.NewPrefixed(backend, "a/").Get(t.Context(), "hello")
+	must.NoError(t, err)
+	must.True(t, ok)
+	must.Eq(t, "world", value)
+}
@@ -1,6 +1,8 @@
 package tests
 
 import (
+	"fmt"
+	"iter"
 	"testing"
 
 	"github.com/openai/openai-go"
@@ -29,6 +31,9 @@ func BackendSuite(t *testing.T, backend storage.Backend[string, string]) {
 	must.True(t, ok)
 	must.Eq(t, "world2", value)
 
+	// Entries are listed in ascending encoded key order by default, which
+	// need not be the order of the keys themselves: a JSON codec puts "hello
+	// again" before "hello", as its space sorts before the closing quote.
 	entries, next, err := backend.List(t.Context(), storage.PageSize(1), nil)
 	must.NoError(t, err)
 	must.NotNil(t, next)
@@ -46,6 +51,54 @@ func BackendSuite(t *testing.T, backend storage.Backend[string, string]) {
 		must.Eq(t, "hello", key)
 		must.Eq(t, "world", value)
 	}
+
+	// And in descending encoded key order with the Descending option.
+	entries, next, err = backend.List(t.Context(), storage.PageSize(1), nil, storage.Descending())
+	must.NoError(t, err)
+	must.NotNil(t, next)
+	must.Eq(t, []string{"hello=world"}, collect(entries))
+
+	entries, next, err = backend.List(t.Context(), nil, next, storage.Descending())
+	must.NoError(t, err)
+	must.Nil(t, next)
+	must.Eq(t, []string{"hello again=world2"}, collect(entries))
+
+	// Only the keys starting with the WithPrefix option are listed.
+	must.NoError(t, backend.Set(t.Context(), "help", "me"))
+
+	must.Eq(t, []string{"hello again=world2", "hello=world"}, listPages(t, backend, storage.WithPrefix("hello")))
+	must.Eq(t, []string{"hello=world", "hello again=world2"}, listPages(t, backend, storage.WithPrefix("hello"), storage.Descending()))
+	must.Eq(t, []string{"help=me"}, listPages(t, backend, storage.WithPrefix("help")))
+	must.Len(t, 0, listPages(t, backend, storage.WithPrefix("world")))
+}
+
+// listPages lists the entries of backend one at a time, returning them as
+// "key=value" strings.
+func listPages(t *testing.T, backend storage.Backend[string, string], opts ...storage.ListOption) []string {
+	t.Helper()
+
+	var (
+		pairs     []string
+		pageToken *string
+	)
+	for {
+		entries, next, err := backend.List(t.Context(), storage.PageSize(1), pageToken, opts...)
+		must.NoError(t, err)
+		pairs = append(pairs, collect(entries)...)
+		if next == nil {
+			return pairs
+		}
+		pageToken = next
+	}
+}
+
+// collect returns the listed entries as "key=value" strings.
+func collect[V any](entries iter.Seq2[string, V]) []string {
+	var pairs []string
+	for key, value := range entries {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, value))
+	}
+	return pairs
 }
 
 func BackendSuite_openai_chat_messages(t *testing.T, b storage.Backend[string, openai.ChatCompletionMessage]) {
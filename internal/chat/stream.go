@@ -0,0 +1,164 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// keyCtrlC is the byte sent by Ctrl-C when the terminal is in raw mode.
+const keyCtrlC = 3
+
+// interruptReader wraps the session input so it can be watched for Ctrl-C
+// while a response is streaming, without losing anything else the user
+// types in the meantime.
+//
+// A single goroutine reads from the underlying reader, because a blocked
+// Read cannot be abandoned once a response finishes.
+type interruptReader struct {
+	r      io.Reader
+	once   sync.Once
+	chunks chan readChunk
+
+	mu      sync.Mutex
+	pending []byte
+	err     error
+}
+
+type readChunk struct {
+	data []byte
+	err  error
+}
+
+func newInterruptReader(r io.Reader) *interruptReader {
+	return &interruptReader{r: r}
+}
+
+func (ir *interruptReader) start() {
+	ir.once.Do(func() {
+		ir.chunks = make(chan readChunk)
+		go func() {
+			for {
+				buf := make([]byte, 256)
+				n, err := ir.r.Read(buf)
+				ir.chunks <- readChunk{data: buf[:n], err: err}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	})
+}
+
+// Read implements io.Reader for the session's terminal.
+func (ir *interruptReader) Read(p []byte) (int, error) {
+	ir.start()
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+
+	for len(ir.pending) == 0 {
+		if ir.err != nil {
+			return 0, ir.err
+		}
+		chunk := <-ir.chunks
+		ir.pending = append(ir.pending, chunk.data...)
+		ir.err = chunk.err
+	}
+
+	n := copy(p, ir.pending)
+	ir.pending = ir.pending[n:]
+	return n, nil
+}
+
+// watch calls cancel if Ctrl-C is read before ctx is done. Other input is
+// kept for the next Read.
+func (ir *interruptReader) watch(ctx context.Context, cancel context.CancelFunc) {
+	ir.start()
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+
+	for ir.err == nil {
+		select {
+		case <-ctx.Done():
+			return
+		case chunk := <-ir.chunks:
+			ir.err = chunk.err
+			if i := bytes.IndexByte(chunk.data, keyCtrlC); i >= 0 {
+				ir.pending = append(ir.pending, chunk.data[:i]...)
+				cancel()
+				return
+			}
+			ir.pending = append(ir.pending, chunk.data...)
+		}
+	}
+}
+
+// interruptible returns a context for a single request that is cancelled
+// when the user presses Ctrl-C while the terminal is in raw mode, leaving the
+// session itself running. The returned stop function must be called once the
+// request is done.
+func (cs *Session) interruptible(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	if cs.input == nil {
+		return ctx, cancel
+	}
+
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		cs.input.watch(ctx, cancel)
+	}()
+
+	return ctx, func() {
+		cancel()
+		<-watched
+	}
+}
+
+// streamWriter writes response deltas to the terminal as they arrive, and
+// later replaces them with the rendered Markdown.
+type streamWriter struct {
+	cs   *Session
+	text strings.Builder
+}
+
+func (sw *streamWriter) WriteString(s string) {
+	sw.text.WriteString(s)
+	sw.cs.OutWriter.WriteString(s)
+	sw.cs.OutWriter.Flush()
+}
+
+// replace erases the streamed text and writes s in its place. If the text
+// has scrolled past the top of the terminal, the screen is cleared instead.
+func (sw *streamWriter) replace(s string) {
+	if sw.text.Len() > 0 {
+		rows := terminalRows(sw.text.String(), sw.cs.TermWidth)
+		if rows < sw.cs.TermHeight {
+			if rows > 1 {
+				sw.cs.OutWriter.WriteString("\033[" + strconv.Itoa(rows-1) + "A")
+			}
+			sw.cs.OutWriter.WriteString("\r\033[J")
+		} else {
+			sw.cs.OutWriter.WriteString("\033[2J\033[H")
+		}
+	}
+	sw.cs.OutWriter.WriteString(s)
+	sw.cs.OutWriter.Flush()
+}
+
+// terminalRows reports how many rows s occupies when wrapped at width.
+func terminalRows(s string, width int) int {
+	if width <= 0 {
+		width = 80
+	}
+	rows := 0
+	for _, line := range strings.Split(s, "\n") {
+		rows += max(1, (utf8.RuneCountInString(line)+width-1)/width)
+	}
+	return rows
+}
@@ -0,0 +1,285 @@
+package tokenizer
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultURL is where OpenAI publishes its encodings.
+const DefaultURL = "https://openaipublic.blob.core.windows.net/encodings/"
+
+// maxEncodingBytes limits the size of a downloaded encoding.
+const maxEncodingBytes = 32 << 20
+
+// httpClient downloads encodings, giving up on a download that takes longer
+// than a minute.
+var httpClient = &http.Client{Timeout: time.Minute}
+
+// CacheDir returns the directory encodings are cached in, which is set by
+// the OPENAI_TOKENIZER_CACHE_DIR environment variable, or defaults to a
+// directory in the user's cache directory.
+func CacheDir() (string, error) {
+	if dir := os.Getenv("OPENAI_TOKENIZER_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "openai-cli", "tokenizer"), nil
+}
+
+// baseURL returns the URL encodings are downloaded from, which is set by the
+// OPENAI_TOKENIZER_URL environment variable, or defaults to DefaultURL.
+func baseURL() string {
+	return cmp.Or(os.Getenv("OPENAI_TOKENIZER_URL"), DefaultURL)
+}
+
+var (
+	loadedMu sync.Mutex
+	loaded   = map[string]*loadResult{}
+)
+
+type loadResult struct {
+	mu       sync.Mutex
+	done     bool
+	encoding *Encoding
+	err      error
+}
+
+// Get returns the named encoding, reading it from the cache directory, or
+// downloading it into the cache directory if it isn't there yet.
+//
+// Encodings are loaded once per process, and failures are not retried,
+// unless ctx was done before the encoding loaded.
+func Get(ctx context.Context, name string) (*Encoding, error) {
+	if _, ok := patterns[name]; !ok {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+
+	dir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	url := baseURL() + name + ".tiktoken"
+
+	loadedMu.Lock()
+	key := dir + "\x00" + url
+	result, ok := loaded[key]
+	if !ok {
+		result = &loadResult{}
+		loaded[key] = result
+	}
+	loadedMu.Unlock()
+
+	result.mu.Lock()
+	defer result.mu.Unlock()
+
+	if !result.done {
+		encoding, err := load(ctx, name, dir, url)
+		// A load cut short by ctx may succeed with another, so it isn't kept.
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+		result.encoding, result.err, result.done = encoding, err, true
+	}
+	return result.encoding, result.err
+}
+
+// load reads the named encoding from dir, downloading it from url first if needed.
+func load(ctx context.Context, name, dir, url string) (*Encoding, error) {
+	path := filepath.Join(dir, name+".tiktoken")
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return Load(name, bytes.NewReader(data))
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read cached %s encoding: %w", name, err)
+	}
+
+	data, err = download(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s encoding: %w", name, err)
+	}
+
+	encoding, err := Load(name, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the encoding to a temporary file first, so that a partially
+	// written file is never read from the cache.
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create tokenizer cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to cache %s encoding: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to cache %s encoding: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to cache %s encoding: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to cache %s encoding: %w", name, err)
+	}
+
+	return encoding, nil
+}
+
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxEncodingBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxEncodingBytes {
+		return nil, fmt.Errorf("encoding from %s is larger than %d bytes", url, maxEncodingBytes)
+	}
+	return data, nil
+}
+
+// encodingPrefixes map model name prefixes to their encodings. More specific
+// prefixes come first.
+var encodingPrefixes = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-4o", O200kBase},
+	{"chatgpt-4o", O200kBase},
+	{"gpt-4.1", O200kBase},
+	{"gpt-4.5", O200kBase},
+	{"gpt-5", O200kBase},
+	{"gpt-oss", O200kBase},
+	{"o1", O200kBase},
+	{"o3", O200kBase},
+	{"o4", O200kBase},
+	{"gpt-4", Cl100kBase},
+	{"gpt-3.5", Cl100kBase},
+	{"text-embedding-3", Cl100kBase},
+	{"text-embedding-ada-002", Cl100kBase},
+}
+
+// EncodingForModel returns the name of the encoding used by model, which
+// defaults to O200kBase for models that aren't known.
+func EncodingForModel(model string) string {
+	for _, p := range encodingPrefixes {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.encoding
+		}
+	}
+	return O200kBase
+}
+
+// Counter counts the tokens in text.
+type Counter interface {
+	Count(text string) int
+}
+
+// Estimate is a Counter that estimates the number of tokens in text, at
+// about four bytes per token, for when no encoding is available.
+type Estimate struct{}
+
+// Count returns the estimated number of tokens in text.
+func (Estimate) Count(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// ForEncoding returns a Counter for the named encoding, or an Estimate if
+// the encoding can't be loaded.
+func ForEncoding(ctx context.Context, name string) Counter {
+	encoding, err := Get(ctx, name)
+	if err != nil {
+		return Estimate{}
+	}
+	return encoding
+}
+
+// Background is a Counter for an encoding loaded in the background, which
+// estimates the tokens in text until the encoding is loaded, and from then on
+// if it fails to load.
+type Background struct {
+	done     chan struct{}
+	encoding *Encoding
+	err      error
+}
+
+// LoadInBackground starts loading the named encoding with Get, giving up
+// after timeout, and returns a Counter for it.
+func LoadInBackground(ctx context.Context, name string, timeout time.Duration) *Background {
+	b := &Background{done: make(chan struct{})}
+	go func() {
+		defer close(b.done)
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		b.encoding, b.err = Get(ctx, name)
+	}()
+	return b
+}
+
+// Count returns the number of tokens in text, or an estimate if the encoding
+// isn't loaded.
+func (b *Background) Count(text string) int {
+	select {
+	case <-b.done:
+		if b.err == nil {
+			return b.encoding.Count(text)
+		}
+	default:
+	}
+	return Estimate{}.Count(text)
+}
+
+// Err returns the error the encoding failed to load with, or nil if it
+// loaded or is still loading.
+func (b *Background) Err() error {
+	select {
+	case <-b.done:
+		return b.err
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the encoding is loaded, or fails to load.
+func (b *Background) Wait() {
+	<-b.done
+}
+
+// ForModel returns a Counter for the encoding used by model, or an Estimate
+// if the encoding can't be loaded.
+func ForModel(ctx context.Context, model string) Counter {
+	return ForEncoding(ctx, EncodingForModel(model))
+}
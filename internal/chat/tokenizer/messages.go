@@ -0,0 +1,25 @@
+package tokenizer
+
+import "github.com/openai/openai-go"
+
+// Overheads of the chat format, in tokens, which wraps each message with
+// its role and separators, and primes the reply with the assistant role.
+const (
+	tokensPerMessage = 3
+	tokensPerReply   = 3
+)
+
+// CountMessages returns the number of prompt tokens used by messages,
+// including the overhead of each message and of priming the reply. Tool
+// calls are counted by their ID, function name, and arguments, which is an
+// approximation of how they are sent.
+func CountMessages(counter Counter, messages []openai.ChatCompletionMessage) int {
+	n := tokensPerReply
+	for _, m := range messages {
+		n += tokensPerMessage + counter.Count(string(m.Role)) + counter.Count(m.Content)
+		for _, call := range m.ToolCalls {
+			n += counter.Count(call.ID) + counter.Count(call.Function.Name) + counter.Count(call.Function.Arguments)
+		}
+	}
+	return n
+}
@@ -0,0 +1,209 @@
+// Package tokenizer implements the byte pair encodings used by OpenAI models,
+// to count the tokens in text and chat messages before they are sent.
+//
+// The encodings themselves are not part of this package. They are loaded from
+// the files OpenAI publishes for tiktoken, which are downloaded on first use
+// and cached (see Get), so counting needs network access once per encoding.
+// Until an encoding is available, Estimate approximates counts instead, as a
+// Background counter does while it loads or after loading fails.
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Names of the supported encodings.
+const (
+	// O200kBase is the encoding used by GPT-4o, GPT-4.1, GPT-5 and the o-series models.
+	O200kBase = "o200k_base"
+	// Cl100kBase is the encoding used by GPT-4 and GPT-3.5 Turbo.
+	Cl100kBase = "cl100k_base"
+)
+
+// patterns are the regular expressions that split text into the pieces
+// encoded by each encoding, as defined by tiktoken.
+var patterns = map[string]string{
+	O200kBase: strings.Join([]string{
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`\p{N}{1,3}`,
+		` ?[^\s\p{L}\p{N}]+[\r\n/]*`,
+		`\s*[\r\n]+`,
+		`\s+(?!\S)`,
+		`\s+`,
+	}, "|"),
+	Cl100kBase: `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+}
+
+// Encoding is a byte pair encoding, mapping pieces of text to tokens.
+type Encoding struct {
+	name    string
+	ranks   map[string]int
+	tokens  map[int]string
+	pattern *regexp2.Regexp
+}
+
+// Load reads the named encoding from r, in the tiktoken format: one token
+// per line, as its base64 encoded bytes followed by its rank.
+func Load(name string, r io.Reader) (*Encoding, error) {
+	pattern, ok := patterns[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		token, rank, ok := bytes.Cut(text, []byte(" "))
+		if !ok {
+			return nil, fmt.Errorf("invalid %s encoding on line %d: missing rank", name, line)
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(string(token))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s encoding on line %d: %w", name, line, err)
+		}
+
+		n, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s encoding on line %d: %w", name, line, err)
+		}
+		ranks[string(decoded)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s encoding: %w", name, err)
+	}
+
+	// Every byte must be a token, so that any text can be encoded.
+	for b := range 256 {
+		if _, ok := ranks[string([]byte{byte(b)})]; !ok {
+			return nil, fmt.Errorf("invalid %s encoding: missing token for byte %#x", name, b)
+		}
+	}
+
+	tokens := make(map[int]string, len(ranks))
+	for piece, rank := range ranks {
+		tokens[rank] = piece
+	}
+
+	return &Encoding{
+		name:    name,
+		ranks:   ranks,
+		tokens:  tokens,
+		pattern: regexp2.MustCompile(pattern, regexp2.None),
+	}, nil
+}
+
+// Name returns the name of the encoding.
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Encode returns the tokens of text. Special tokens, such as <|endoftext|>,
+// are encoded as ordinary text.
+func (e *Encoding) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range e.split(text) {
+		if rank, ok := e.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+		tokens = append(tokens, e.merge(piece)...)
+	}
+	return tokens
+}
+
+// Decode returns the text of tokens. Unknown tokens are skipped.
+func (e *Encoding) Decode(tokens []int) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteString(e.tokens[token])
+	}
+	return b.String()
+}
+
+// Count returns the number of tokens in text.
+func (e *Encoding) Count(text string) int {
+	return len(e.Encode(text))
+}
+
+// split returns the pieces of text that are encoded separately.
+func (e *Encoding) split(text string) []string {
+	var pieces []string
+
+	// Matching only fails on timeout, and the pattern has none.
+	match, _ := e.pattern.FindStringMatch(text)
+	for match != nil {
+		pieces = append(pieces, match.String())
+		match, _ = e.pattern.FindNextMatch(match)
+	}
+	return pieces
+}
+
+// merge encodes piece by repeatedly merging the adjacent pair of parts with
+// the lowest rank, starting from its bytes, as tiktoken does.
+func (e *Encoding) merge(piece string) []int {
+	type part struct {
+		start int
+		rank  int
+	}
+
+	// parts holds the start of each part, followed by the end of the
+	// piece, and the rank of merging each part with the next.
+	parts := make([]part, len(piece)+1)
+	for i := range parts {
+		parts[i] = part{start: i, rank: math.MaxInt}
+	}
+
+	rank := func(i int) int {
+		if i+2 < len(parts) {
+			if r, ok := e.ranks[piece[parts[i].start:parts[i+2].start]]; ok {
+				return r
+			}
+		}
+		return math.MaxInt
+	}
+
+	for i := range len(parts) - 2 {
+		parts[i].rank = rank(i)
+	}
+
+	for len(parts) > 2 {
+		lowest := -1
+		for i := range len(parts) - 1 {
+			if parts[i].rank != math.MaxInt && (lowest < 0 || parts[i].rank < parts[lowest].rank) {
+				lowest = i
+			}
+		}
+		if lowest < 0 {
+			break
+		}
+
+		parts = slices.Delete(parts, lowest+1, lowest+2)
+		parts[lowest].rank = rank(lowest)
+		if lowest > 0 {
+			parts[lowest-1].rank = rank(lowest - 1)
+		}
+	}
+
+	tokens := make([]int, len(parts)-1)
+	for i := range tokens {
+		tokens[i] = e.ranks[piece[parts[i].start:parts[i+1].start]]
+	}
+	return tokens
+}
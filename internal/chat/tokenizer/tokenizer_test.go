@@ -0,0 +1,174 @@
+package tokenizer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/picatz/openai/internal/chat/tokenizer/tokenizertest"
+	"github.com/shoenig/test/must"
+)
+
+func TestEncoding(t *testing.T) {
+	encoding, err := Load(O200kBase, strings.NewReader(tokenizertest.Encoding("Hello", " world", " wor")))
+	must.NoError(t, err)
+	must.Eq(t, O200kBase, encoding.Name())
+
+	tokens := encoding.Encode("Hello world!")
+	must.Eq(t, []string{"Hello", " world", "!"}, decodeEach(encoding, tokens))
+	must.Eq(t, "Hello world!", encoding.Decode(tokens))
+
+	// Unknown words fall back to the longest merges available.
+	tokens = encoding.Encode("Help worms")
+	must.Eq(t, []string{"Hel", "p", " wor", "m", "s"}, decodeEach(encoding, tokens))
+	must.Eq(t, 5, encoding.Count("Help worms"))
+}
+
+func decodeEach(encoding *Encoding, tokens []int) []string {
+	pieces := make([]string, len(tokens))
+	for i, token := range tokens {
+		pieces[i] = encoding.Decode([]int{token})
+	}
+	return pieces
+}
+
+func TestEncoding_split(t *testing.T) {
+	text := "I'm   testing 12345 ABCword's\n\n  end\t!"
+
+	o200k, err := Load(O200kBase, strings.NewReader(tokenizertest.Encoding()))
+	must.NoError(t, err)
+	must.Eq(t, []string{"I'm", "  ", " testing", " ", "123", "45", " ABCword's", "\n\n", " ", " end", "\t", "!"}, o200k.split(text))
+
+	cl100k, err := Load(Cl100kBase, strings.NewReader(tokenizertest.Encoding()))
+	must.NoError(t, err)
+	must.Eq(t, []string{"I", "'m", "  ", " testing", " ", "123", "45", " ABCword", "'s", "\n\n", " ", " end", "\t", "!"}, cl100k.split(text))
+}
+
+func TestLoad_invalid(t *testing.T) {
+	_, err := Load("p50k_base", strings.NewReader(""))
+	must.ErrorContains(t, err, "unknown encoding")
+
+	_, err = Load(O200kBase, strings.NewReader("aGk=\n"))
+	must.ErrorContains(t, err, "missing rank")
+
+	_, err = Load(O200kBase, strings.NewReader("aGk= 0\n"))
+	must.ErrorContains(t, err, "missing token for byte")
+}
+
+func TestGet(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/"+Cl100kBase+".tiktoken" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, tokenizertest.Encoding(" hello"))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	t.Setenv("OPENAI_TOKENIZER_CACHE_DIR", dir)
+	t.Setenv("OPENAI_TOKENIZER_URL", ts.URL+"/")
+
+	encoding, err := Get(t.Context(), Cl100kBase)
+	must.NoError(t, err)
+	must.Eq(t, 1, encoding.Count(" hello"))
+
+	// The encoding is cached on disk, and in memory.
+	_, err = os.Stat(filepath.Join(dir, Cl100kBase+".tiktoken"))
+	must.NoError(t, err)
+
+	counter := ForModel(t.Context(), "gpt-4-turbo")
+	must.Eq(t, Counter(encoding), counter)
+	must.Eq(t, int32(1), requests.Load())
+
+	// Models using an encoding that can't be loaded get an estimate.
+	must.Eq(t, Counter(Estimate{}), ForModel(t.Context(), "gpt-4o"))
+	must.Eq(t, int32(2), requests.Load())
+}
+
+func TestGet_cancelled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, tokenizertest.Encoding(" hello"))
+	}))
+	defer ts.Close()
+
+	t.Setenv("OPENAI_TOKENIZER_CACHE_DIR", t.TempDir())
+	t.Setenv("OPENAI_TOKENIZER_URL", ts.URL+"/")
+
+	// A load cut short by its context is retried by the next Get.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := Get(ctx, O200kBase)
+	must.ErrorIs(t, err, context.Canceled)
+
+	encoding, err := Get(t.Context(), O200kBase)
+	must.NoError(t, err)
+	must.Eq(t, 1, encoding.Count(" hello"))
+}
+
+func TestLoadInBackground(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		if r.URL.Path != "/"+O200kBase+".tiktoken" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, tokenizertest.Encoding(" hello"))
+	}))
+	defer ts.Close()
+
+	t.Setenv("OPENAI_TOKENIZER_CACHE_DIR", t.TempDir())
+	t.Setenv("OPENAI_TOKENIZER_URL", ts.URL+"/")
+
+	// Tokens are estimated until the encoding is loaded.
+	counter := LoadInBackground(t.Context(), O200kBase, time.Minute)
+	must.Eq(t, 2, counter.Count(" hello"))
+	must.NoError(t, counter.Err())
+
+	close(release)
+	counter.Wait()
+	must.NoError(t, counter.Err())
+	must.Eq(t, 1, counter.Count(" hello"))
+
+	// And from then on if it fails to load.
+	failed := LoadInBackground(t.Context(), Cl100kBase, time.Minute)
+	failed.Wait()
+	must.ErrorContains(t, failed.Err(), "404")
+	must.Eq(t, 2, failed.Count(" hello"))
+}
+
+func TestEncodingForModel(t *testing.T) {
+	for model, want := range map[string]string{
+		"gpt-4o-mini":            O200kBase,
+		"gpt-4.1-nano":           O200kBase,
+		"gpt-5":                  O200kBase,
+		"o3-mini":                O200kBase,
+		"gpt-4":                  Cl100kBase,
+		"gpt-4-turbo-2024-04-09": Cl100kBase,
+		"gpt-3.5-turbo":          Cl100kBase,
+		"granite3.1-dense:2b":    O200kBase,
+	} {
+		must.Eq(t, want, EncodingForModel(model), must.Sprint(model))
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	messages := []openai.ChatCompletionMessage{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hello there"},
+	}
+
+	// 3 to prime the reply, and 3 for each message, plus its role and content.
+	must.Eq(t, 3+(3+2+3)+(3+1+3), CountMessages(Estimate{}, messages))
+}
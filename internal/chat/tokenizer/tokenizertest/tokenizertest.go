@@ -0,0 +1,42 @@
+// Package tokenizertest provides small encodings for testing code built on
+// the tokenizer package without downloading the encodings OpenAI publishes.
+//
+// An encoding is loaded from the text returned by Encoding:
+//
+//	encoding, err := tokenizer.Load(tokenizer.O200kBase, strings.NewReader(tokenizertest.Encoding("Hello", " world")))
+package tokenizertest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Encoding returns an encoding in the tiktoken format with a token for
+// every byte, and for every prefix of the given words, so that each word is
+// merged into a single token.
+func Encoding(words ...string) string {
+	var (
+		b    strings.Builder
+		rank int
+		seen = map[string]bool{}
+	)
+	add := func(token string) {
+		if seen[token] {
+			return
+		}
+		seen[token] = true
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+		rank++
+	}
+
+	for i := range 256 {
+		add(string([]byte{byte(i)}))
+	}
+	for _, word := range words {
+		for i := 2; i <= len(word); i++ {
+			add(word[:i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"cmp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/openai/openai-go"
+	"github.com/picatz/openai/internal/chat/tokenizer"
+)
+
+// DefaultContextWindow is the context window, in tokens, assumed for models
+// missing from contextWindows.
+const DefaultContextWindow = 8192
+
+// tokenizerLoadTimeout bounds how long a session waits for its tokenizer's
+// encoding to load in the background, before settling for estimates.
+const tokenizerLoadTimeout = 30 * time.Second
+
+// summarizeThreshold is the share of the context window a prompt may use
+// before the conversation is summarized, leaving room for the response.
+const summarizeThreshold = 0.75
+
+// contextWindows are the context windows of known models, in tokens, matched
+// by prefix. More specific prefixes come first.
+var contextWindows = []struct {
+	prefix string
+	tokens int64
+}{
+	{"gpt-4o", 128_000},
+	{"chatgpt-4o", 128_000},
+	{"gpt-4.1", 1_047_576},
+	{"gpt-4.5", 128_000},
+	{"gpt-4-turbo", 128_000},
+	{"gpt-4-0125", 128_000},
+	{"gpt-4-1106", 128_000},
+	{"gpt-4-32k", 32_768},
+	{"gpt-4", 8_192},
+	{"gpt-3.5-turbo", 16_385},
+	{"gpt-5", 400_000},
+	{"o1-mini", 128_000},
+	{"o1", 200_000},
+	{"o3", 200_000},
+	{"o4-mini", 200_000},
+}
+
+// ContextWindow returns the context window of model, in tokens, or
+// DefaultContextWindow if the model isn't known.
+func ContextWindow(model string) int64 {
+	for _, w := range contextWindows {
+		if strings.HasPrefix(model, w.prefix) {
+			return w.tokens
+		}
+	}
+	return DefaultContextWindow
+}
+
+// contextWindow returns the context window of the session's model, which
+// SummarizeContextWindowSize overrides if set.
+func (cs *Session) contextWindow() int64 {
+	return cmp.Or(cs.SummarizeContextWindowSize, ContextWindow(cs.ChatModel))
+}
+
+// countTokens returns the number of prompt tokens used by messages.
+func (cs *Session) countTokens(messages []openai.ChatCompletionMessage) int64 {
+	var counter tokenizer.Counter = tokenizer.Estimate{}
+	if cs.Tokenizer != nil {
+		counter = cs.Tokenizer
+	}
+	return int64(tokenizer.CountMessages(counter, messages))
+}
+
+// warnTokenizerFallback tells the user, once, when tokens are estimated
+// because the tokenizer's encoding failed to load.
+func (cs *Session) warnTokenizerFallback() {
+	background, ok := cs.Tokenizer.(*tokenizer.Background)
+	if !ok || cs.tokenizerWarned {
+		return
+	}
+	if err := background.Err(); err != nil {
+		cs.tokenizerWarned = true
+		cs.OutWriter.WriteString(lipgloss.NewStyle().Faint(true).Render("warning: estimating token counts, as the tokenizer failed to load: "+err.Error()) + "\n")
+		cs.OutWriter.Flush()
+	}
+}
@@ -0,0 +1,94 @@
+package chat_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/picatz/openai/internal/chat"
+	"github.com/shoenig/test/must"
+)
+
+func TestContextWindow(t *testing.T) {
+	for model, want := range map[string]int64{
+		"gpt-4o-mini":      128_000,
+		"gpt-4.1-nano":     1_047_576,
+		"gpt-4":            8_192,
+		"gpt-4-32k":        32_768,
+		"gpt-4-turbo":      128_000,
+		"gpt-3.5-turbo":    16_385,
+		"o1-mini":          128_000,
+		"o3":               200_000,
+		"some-local-model": chat.DefaultContextWindow,
+	} {
+		must.Eq(t, want, chat.ContextWindow(model), must.Sprint(model))
+	}
+}
+
+func TestChatSession_summarizesBeforeRequest(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests [][]requestMessage
+	)
+
+	chatSession := newStreamingSession(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stream   bool             `json:"stream"`
+			Messages []requestMessage `json:"messages"`
+		}
+		must.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		mu.Lock()
+		requests = append(requests, body.Messages)
+		mu.Unlock()
+
+		if !body.Stream {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"id": "chatcmpl-summary", "object": "chat.completion", "created": 1, "model": "gpt-4o",
+				"choices": []any{map[string]any{
+					"index": 0, "finish_reason": "stop",
+					"message": map[string]any{"role": "assistant", "content": "They greeted each other."},
+				}},
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, streamChunk("It was long."))
+		io.WriteString(w, "data: [DONE]\n\n")
+	}, strings.NewReader("tell me about the history of the world in great detail\r\n"), io.Discard)
+
+	chatSession.Messages = []openai.ChatCompletionMessage{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "hello"},
+	}
+
+	// The prompt with the next message would use 3 to prime the reply, and
+	// 3 for each message, plus its role and content: 3+5+8+18 = 34 tokens,
+	// which is over 75% of the context window.
+	chatSession.SummarizeContextWindowSize = 40
+
+	done, err := chatSession.RunOnce(t.Context())
+	must.NoError(t, err)
+	must.False(t, done)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// The conversation is summarized before the request, which replaces the
+	// previous messages with the summary.
+	must.Len(t, 2, requests)
+	must.StrContains(t, requests[0][1].Content, "user:\nhi\nassistant:\nhello\n")
+	must.Len(t, 2, requests[1])
+	must.Eq(t, "system", requests[1][0].Role)
+	must.StrContains(t, requests[1][0].Content, "They greeted each other.")
+	must.Eq(t, "tell me about the history of the world in great detail", requests[1][1].Content)
+
+	must.Len(t, 3, chatSession.Messages)
+	must.Eq(t, "It was long.", chatSession.Messages[2].Content)
+}
@@ -0,0 +1,338 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/openai/openai-go"
+)
+
+// maxToolRounds limits how many times the model may call tools while
+// answering a single message. The final round is sent without tools, so
+// the model has to answer with what it has gathered.
+const maxToolRounds = 8
+
+// maxToolResultBytes limits the size of a tool result sent to the model.
+const maxToolResultBytes = 64 << 10
+
+// ToolHandler runs a tool with the JSON encoded arguments chosen by the model,
+// returning the result sent back to it.
+type ToolHandler func(ctx context.Context, arguments string) (string, error)
+
+// Tool is a function the model can call while answering a message.
+type Tool struct {
+	// Name of the tool, as seen by the model.
+	Name string
+
+	// Description of what the tool does, and when to use it.
+	Description string
+
+	// Parameters is the JSON schema for the tool's arguments.
+	Parameters map[string]any
+
+	// Handler runs the tool.
+	Handler ToolHandler
+}
+
+// param returns the tool definition sent with chat requests.
+func (t Tool) param() openai.ChatCompletionToolParam {
+	return openai.ChatCompletionToolParam{
+		Function: openai.FunctionDefinitionParam{
+			Name:        t.Name,
+			Description: openai.String(t.Description),
+			Parameters:  openai.FunctionParameters(t.Parameters),
+		},
+	}
+}
+
+// toolHTTPClient fetches URLs for the fetch_url tool. Redirects are only
+// followed on the same host, since the user approves fetches per host.
+var toolHTTPClient = &http.Client{
+	Timeout: 30 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if req.URL.Host != via[0].URL.Host {
+			return fmt.Errorf("refusing to follow redirect to unapproved host %q", req.URL.Host)
+		}
+		return nil
+	},
+}
+
+// builtinTools are the tools available to the model in the chat session,
+// used to pull in context without it being pasted into a message. Files and
+// directories are confined to the working directory, and calls are only run
+// once the user approves them, see [Session.approve].
+var builtinTools = []Tool{
+	{
+		Name:        "read_file",
+		Description: "Read the contents of a file in the working directory.",
+		Parameters:  stringParameters("path", "Path of the file to read, relative to the working directory."),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Path string `json:"path"`
+			}
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			return readFile(args.Path)
+		},
+	},
+	{
+		Name:        "list_dir",
+		Description: "List the entries of a directory in the working directory. Directories are shown with a trailing slash.",
+		Parameters:  stringParameters("path", "Path of the directory to list, relative to the working directory."),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Path string `json:"path"`
+			}
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			return listDir(args.Path)
+		},
+	},
+	{
+		Name:        "fetch_url",
+		Description: "Fetch the contents of a URL with a GET request.",
+		Parameters:  stringParameters("url", "URL to fetch."),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				URL string `json:"url"`
+			}
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			return fetchURL(ctx, toolHTTPClient, args.URL)
+		},
+	},
+}
+
+// stringParameters returns a JSON schema for an object with a single,
+// required string property.
+func stringParameters(name, description string) map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			name: map[string]any{
+				"type":        "string",
+				"description": description,
+			},
+		},
+		"required":             []string{name},
+		"additionalProperties": false,
+	}
+}
+
+// openWorkingDir opens the working directory, which #file: directives and
+// the file tools are confined to, and returns path relative to it.
+func openWorkingDir(path string) (*os.Root, string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	rel := path
+	if filepath.IsAbs(path) {
+		if rel, err = filepath.Rel(wd, path); err != nil {
+			rel = ""
+		}
+	}
+	if !filepath.IsLocal(rel) {
+		return nil, "", fmt.Errorf("path %q is outside of the working directory", path)
+	}
+
+	root, err := os.OpenRoot(wd)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open working directory: %w", err)
+	}
+	return root, rel, nil
+}
+
+// readFile returns the contents of the file at path in the working directory,
+// for both #file: directives and the read_file tool. Contents are truncated
+// to the size of a tool result, and binary files are refused.
+func readFile(path string) (string, error) {
+	root, rel, err := openWorkingDir(path)
+	if err != nil {
+		return "", err
+	}
+	defer root.Close()
+
+	file, err := root.Open(rel)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %q: %w", path, err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxToolResultBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("error reading file %q: %w", path, err)
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", fmt.Errorf("file %q is binary", path)
+	}
+	if len(data) > maxToolResultBytes {
+		data = append(data[:maxToolResultBytes], "\n[truncated]"...)
+	}
+	return string(data), nil
+}
+
+// listDir returns the names of the entries of the directory at path in the
+// working directory, one per line.
+func listDir(path string) (string, error) {
+	root, path, err := openWorkingDir(path)
+	if err != nil {
+		return "", err
+	}
+	defer root.Close()
+
+	dir, err := root.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open directory %q: %w", path, err)
+	}
+	defer dir.Close()
+
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		return "", fmt.Errorf("failed to read directory %q: %w", path, err)
+	}
+	slices.SortFunc(entries, func(a, b os.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	var b strings.Builder
+	for _, entry := range entries {
+		b.WriteString(entry.Name())
+		if entry.IsDir() {
+			b.WriteString("/")
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// toolParams returns the definitions of the session's tools.
+func (cs *Session) toolParams() []openai.ChatCompletionToolParam {
+	params := make([]openai.ChatCompletionToolParam, len(cs.Tools))
+	for i, tool := range cs.Tools {
+		params[i] = tool.param()
+	}
+	return params
+}
+
+// runTools runs the tool calls requested by the model, returning a tool
+// message with the result of each. Failures, and calls the user declined,
+// are reported to the model as results, so it can recover from them.
+//
+// Pressing Ctrl-C while the approved calls run cancels them.
+func (cs *Session) runTools(ctx context.Context, calls []openai.ChatCompletionMessageToolCall) []openai.ChatCompletionMessage {
+	// Ask for every approval first, since the terminal can't be read while
+	// it is watched for Ctrl-C.
+	approved := make([]bool, len(calls))
+	for i, call := range calls {
+		cs.OutWriter.WriteString(lipgloss.NewStyle().Faint(true).Render(fmt.Sprintf("⚙ %s %s", call.Function.Name, call.Function.Arguments)) + "\n")
+		cs.OutWriter.Flush()
+
+		approved[i] = cs.approve(call)
+	}
+
+	runCtx, stop := cs.interruptible(ctx)
+	defer stop()
+
+	messages := make([]openai.ChatCompletionMessage, 0, len(calls))
+	for i, call := range calls {
+		var (
+			result string
+			err    error
+		)
+		if approved[i] {
+			result, err = cs.runTool(runCtx, call)
+		} else {
+			err = errors.New("the user declined to run the tool")
+		}
+		if err != nil {
+			result = "error: " + err.Error()
+		}
+		if len(result) > maxToolResultBytes {
+			result = result[:maxToolResultBytes] + "\n[truncated]"
+		}
+		messages = append(messages, toolMessage(call, result))
+	}
+	return messages
+}
+
+// approve asks the user whether the model may make call. Fetches are
+// approved once per host for the rest of the session, every other call is
+// approved on its own.
+func (cs *Session) approve(call openai.ChatCompletionMessageToolCall) bool {
+	prompt := fmt.Sprintf("Allow %s? (y/n): ", call.Function.Name)
+
+	var host string
+	if call.Function.Name == "fetch_url" {
+		var args struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+			return false
+		}
+		u, err := url.Parse(httpsURL(args.URL))
+		if err != nil || u.Host == "" {
+			return false
+		}
+		host = u.Host
+		if cs.approvedHosts[host] {
+			return true
+		}
+		prompt = fmt.Sprintf("Allow fetching from %s for the rest of the session? (y/n): ", host)
+	}
+
+	cs.OutWriter.WriteString(prompt)
+	cs.OutWriter.Flush()
+
+	confirmation, err := cs.Terminal.ReadLine()
+	if err != nil || strings.ToLower(strings.TrimSpace(confirmation)) != "y" {
+		return false
+	}
+
+	if host != "" {
+		if cs.approvedHosts == nil {
+			cs.approvedHosts = make(map[string]bool)
+		}
+		cs.approvedHosts[host] = true
+	}
+	return true
+}
+
+func (cs *Session) runTool(ctx context.Context, call openai.ChatCompletionMessageToolCall) (string, error) {
+	for _, tool := range cs.Tools {
+		if tool.Name == call.Function.Name {
+			return tool.Handler(ctx, call.Function.Arguments)
+		}
+	}
+	return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+}
+
+// toolMessage returns a message with the "tool" role holding the result of
+// call. The call itself is kept in the message's ToolCalls, so the result
+// can be matched to it when the conversation is sent again.
+func toolMessage(call openai.ChatCompletionMessageToolCall, result string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role:      "tool",
+		Content:   result,
+		ToolCalls: []openai.ChatCompletionMessageToolCall{call},
+	}
+}
@@ -0,0 +1,261 @@
+package chat_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/picatz/openai/internal/chat"
+	"github.com/picatz/openai/internal/chat/storage"
+	pebbleStorage "github.com/picatz/openai/internal/chat/storage/pebble"
+	"github.com/picatz/openai/internal/chat/tokenizer"
+	"github.com/shoenig/test/must"
+)
+
+// sseData formats v as the data of a server-sent event.
+func sseData(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	must.NoError(t, err)
+	return "data: " + string(b) + "\n\n"
+}
+
+type requestMessage struct {
+	Role       string `json:"role"`
+	Content    string `json:"content"`
+	ToolCallID string `json:"tool_call_id"`
+	ToolCalls  []struct {
+		ID string `json:"id"`
+	} `json:"tool_calls"`
+}
+
+func TestChatSession_tools(t *testing.T) {
+	// The file tools are confined to the working directory.
+	dir := t.TempDir()
+	t.Chdir(dir)
+	notes := filepath.Join(dir, "notes.txt")
+	must.NoError(t, os.WriteFile(notes, []byte("remember the milk\n"), 0o644))
+
+	var (
+		mu       sync.Mutex
+		requests [][]requestMessage
+		tools    [][]string
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []requestMessage `json:"messages"`
+			Tools    []struct {
+				Function struct {
+					Name string `json:"name"`
+				} `json:"function"`
+			} `json:"tools"`
+		}
+		must.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		var names []string
+		for _, tool := range body.Tools {
+			names = append(names, tool.Function.Name)
+		}
+
+		mu.Lock()
+		requests = append(requests, body.Messages)
+		tools = append(tools, names)
+		first := len(requests) == 1
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if first {
+			io.WriteString(w, sseData(t, map[string]any{
+				"id": "chatcmpl-1", "object": "chat.completion.chunk", "created": 1, "model": "gpt-4o",
+				"choices": []any{map[string]any{"index": 0, "delta": map[string]any{
+					"role": "assistant",
+					"tool_calls": []any{map[string]any{
+						"index": 0, "id": "call_1", "type": "function",
+						"function": map[string]any{"name": "read_file", "arguments": ""},
+					}},
+				}}},
+			}))
+			io.WriteString(w, sseData(t, map[string]any{
+				"id": "chatcmpl-1", "object": "chat.completion.chunk", "created": 1, "model": "gpt-4o",
+				"choices": []any{map[string]any{"index": 0, "finish_reason": "tool_calls", "delta": map[string]any{
+					"tool_calls": []any{map[string]any{
+						"index":    0,
+						"function": map[string]any{"arguments": `{"path":` + jsonString(t, notes) + `}`},
+					}},
+				}}},
+			}))
+		} else {
+			io.WriteString(w, streamChunk("You need milk."))
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	client := openai.NewClient(option.WithBaseURL(ts.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+
+	backend, err := pebbleStorage.NewBackend("", &pebble.Options{FS: vfs.NewMem()}, &storage.JSONCodec[string, chat.ReqRespPair]{})
+	must.NoError(t, err)
+	t.Cleanup(func() {
+		must.NoError(t, backend.Close(t.Context()))
+	})
+
+	chatSession, restore, err := chat.NewSession(t.Context(), &client, openai.ChatModelGPT4o, "", strings.NewReader("what do I need?\r\ny\r\n"), io.Discard, backend, tokenizer.Estimate{})
+	must.NoError(t, err)
+	t.Cleanup(restore)
+
+	done, err := chatSession.RunOnce(t.Context())
+	must.NoError(t, err)
+	must.False(t, done)
+
+	must.Len(t, 4, chatSession.Messages)
+	must.Eq(t, "You need milk.", chatSession.Messages[3].Content)
+
+	must.Len(t, 2, requests)
+	must.Eq(t, []string{"read_file", "list_dir", "fetch_url"}, tools[0])
+	must.Len(t, 3, requests[1])
+	must.Eq(t, "call_1", requests[1][1].ToolCalls[0].ID)
+	must.Eq(t, "tool", requests[1][2].Role)
+	must.Eq(t, "call_1", requests[1][2].ToolCallID)
+	must.Eq(t, "remember the milk\n", requests[1][2].Content)
+
+	// A new session loads the tool messages back from storage, and sends
+	// them with the next request.
+	resumed, restore, err := chat.NewSession(t.Context(), &client, openai.ChatModelGPT4o, "", strings.NewReader("thanks\r\n"), io.Discard, backend, tokenizer.Estimate{})
+	must.NoError(t, err)
+	t.Cleanup(restore)
+	must.Len(t, 4, resumed.Messages)
+
+	_, err = resumed.RunOnce(t.Context())
+	must.NoError(t, err)
+
+	must.Len(t, 3, requests)
+	must.Len(t, 5, requests[2])
+	must.Eq(t, "call_1", requests[2][1].ToolCalls[0].ID)
+	must.Eq(t, "call_1", requests[2][2].ToolCallID)
+	must.Eq(t, "You need milk.", requests[2][3].Content)
+}
+
+func jsonString(t *testing.T, s string) string {
+	t.Helper()
+	b, err := json.Marshal(s)
+	must.NoError(t, err)
+	return string(b)
+}
+
+func TestChatSession_toolsDeclined(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	var (
+		mu       sync.Mutex
+		requests [][]requestMessage
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []requestMessage `json:"messages"`
+		}
+		must.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		mu.Lock()
+		requests = append(requests, body.Messages)
+		round := len(requests)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		calls := map[int]struct{ name, arguments string }{
+			1: {"read_file", `{"path":"../secret.txt"}`},
+			2: {"fetch_url", `{"url":"example.com"}`},
+		}
+		if call, ok := calls[round]; ok {
+			io.WriteString(w, sseData(t, map[string]any{
+				"id": "chatcmpl-1", "object": "chat.completion.chunk", "created": 1, "model": "gpt-4o",
+				"choices": []any{map[string]any{"index": 0, "finish_reason": "tool_calls", "delta": map[string]any{
+					"role": "assistant",
+					"tool_calls": []any{map[string]any{
+						"index": 0, "id": "call_1", "type": "function",
+						"function": map[string]any{"name": call.name, "arguments": call.arguments},
+					}},
+				}}},
+			}))
+		} else {
+			io.WriteString(w, streamChunk("I can't."))
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	client := openai.NewClient(option.WithBaseURL(ts.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+
+	backend, err := pebbleStorage.NewBackend("", &pebble.Options{FS: vfs.NewMem()}, &storage.JSONCodec[string, chat.ReqRespPair]{})
+	must.NoError(t, err)
+	t.Cleanup(func() {
+		must.NoError(t, backend.Close(t.Context()))
+	})
+
+	// The read outside of the working directory is approved, but refused,
+	// and the fetch is declined.
+	chatSession, restore, err := chat.NewSession(t.Context(), &client, openai.ChatModelGPT4o, "", strings.NewReader("read my secret\r\ny\r\nn\r\n"), io.Discard, backend, tokenizer.Estimate{})
+	must.NoError(t, err)
+	t.Cleanup(restore)
+
+	_, err = chatSession.RunOnce(t.Context())
+	must.NoError(t, err)
+
+	must.Len(t, 3, requests)
+	must.StrContains(t, requests[1][2].Content, "outside of the working directory")
+	must.Eq(t, "error: the user declined to run the tool", requests[2][4].Content)
+	must.Eq(t, "I can't.", chatSession.Messages[len(chatSession.Messages)-1].Content)
+}
+
+func TestChatSession_fileDirectives(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	must.NoError(t, os.WriteFile("notes.txt", []byte("remember the milk\n"), 0o644))
+	must.NoError(t, os.WriteFile("image.bin", []byte("\x89PNG\x00"), 0o644))
+
+	var (
+		mu       sync.Mutex
+		requests [][]requestMessage
+		output   bytes.Buffer
+	)
+	chatSession := newStreamingSession(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []requestMessage `json:"messages"`
+		}
+		must.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		mu.Lock()
+		requests = append(requests, body.Messages)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, streamChunk("ok"))
+		io.WriteString(w, "data: [DONE]\n\n")
+	}, strings.NewReader("read #file:notes.txt\r\nread #file:image.bin\r\nread #file:../secret.txt\r\n"), &output)
+
+	// #file: directives are read like the read_file tool reads files, and
+	// are left as they are if the file can't be read.
+	for range 3 {
+		_, err := chatSession.RunOnce(t.Context())
+		must.NoError(t, err)
+	}
+
+	must.Len(t, 3, requests)
+	must.Eq(t, "read remember the milk\n", requests[0][0].Content)
+	must.Eq(t, "read #file:image.bin", requests[1][2].Content)
+	must.Eq(t, "read #file:../secret.txt", requests[2][4].Content)
+	must.StrContains(t, output.String(), `file "image.bin" is binary`)
+	must.StrContains(t, output.String(), `path "../secret.txt" is outside of the working directory`)
+}